@@ -1,8 +1,11 @@
 package api
 
 import (
+	"bufio"
 	"database/sql"
-	"sort"
+	"encoding/json"
+	"errors"
+	"io"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -10,12 +13,30 @@ import (
 )
 
 var db *sql.DB
+var config ConfigType
 
-func init() {
-	psqlUrl := GetConfig().PSQLURL
+// Configure sets the configuration used by the handlers and by OpenDB.
+func Configure(cfg ConfigType) {
+	config = cfg
+}
+
+// OpenDB opens the database pool used by the handlers and the command line.
+func OpenDB() error {
 	var err error
-	db, err = sql.Open("postgres", psqlUrl)
-	checkerr(err)
+	db, err = sql.Open("postgres", config.PSQLURL)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(config.DB.MaxOpenConns)
+	db.SetMaxIdleConns(config.DB.MaxIdleConns)
+	db.SetConnMaxLifetime(config.DB.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.DB.ConnMaxIdleTime)
+	return nil
+}
+
+func CloseDB() error {
+	stopCache()
+	return db.Close()
 }
 
 // @Summary		Check service status
@@ -28,96 +49,101 @@ func Index(c *fiber.Ctx) error {
 	return c.Render("index", fiber.Map{"Title": "AxisGTDSync Manage"})
 }
 
-// @Summary		Create a new UID and axisgtd table
-// @Description	Creates a new UID with a generated name and sets up the axisgtd table.
+// @Summary		Create a new UID
+// @Description	Creates a new UID with a generated name.
 // @Tags			id
 // @Accept			json
 // @Produce		json
 // @Success		200	{string}	string	"Create ID successful! Your ID is {uidName}"
-// @Failure		500	{string}	string	"Internal server error"
-// @Router			/create [put]
+// @Failure		500	{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids [post]
 func CreateID(c *fiber.Ctx) error {
-	uidName, err := GetName()
-	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Create ID Failed"})
-	}
-
-	query := `INSERT INTO UID (name, status) VALUES ($1, $2)`
-	_, err = db.Exec(query, uidName, true)
-	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Create ID Failed"})
-	}
-
-	createDataTableQuery := `
-	CREATE TABLE IF NOT EXISTS axisgtd (
-		todolist TEXT NOT NULL,
-		config TEXT NOT NULL,
-		time BIGINT NOT NULL,
-		uid_name CHARACTER VARYING(100) NOT NULL,
-		CONSTRAINT fk_uid_name FOREIGN KEY (uid_name) REFERENCES UID(name)
-	);`
-
-	_, err = db.Exec(createDataTableQuery)
+	uidName, err := CreateUID()
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Create ID Failed"})
+		return storeError(err, "Create ID Failed")
 	}
 
 	return c.Status(200).JSON(fiber.Map{"name": uidName})
 }
 
 // @Summary		Get AxisGTD records by UID name
-// @Description	Retrieves a list of AxisGTD records associated with the given UID name.
+// @Description	Retrieves a list of AxisGTD records associated with the given UID name, oldest first. The array is streamed, a truncated response means the server failed midway.
 // @Tags			id
 // @Accept			json
 // @Produce		json
 // @Param			name	path		string	true	"UID Name"
 // @Success		200		{array}		AxisGTDJsonType
-// @Failure		404		{string}	string	"No records found"
-// @Failure		500		{string}	string	"Internal server error"
-// @Router			/id/{name} [get]
+// @Failure		404		{object}	ErrorType	"No records found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/history [get]
 func GetID(c *fiber.Ctx) error {
+	head, err := LatestSnapshot(c.Params("name"))
+	if err != nil && err != sql.ErrNoRows {
+		return storeError(err, "Get ID information Failed")
+	}
+	if err == sql.ErrNoRows || !head.Status || !head.HasSnapshot {
+		return notFound("no_records", "No records found")
+	}
+
+	name := c.Params("name")
+	logger := requestLog(c)
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := writeHistory(w, name); err != nil {
+			logger.Error("history stream failed", "error", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// writeHistory writes the snapshots of a UID as a JSON array, one row at a
+// time. An error cuts the array short, so the client sees invalid JSON
+// rather than a partial history that looks complete.
+func writeHistory(w io.Writer, uidName string) error {
 	query := `
 		SELECT
-			axisgtd.todolist, 
-			axisgtd.config, 
-			axisgtd.time, 
-			UID.status
+			axisgtd.todolist,
+			axisgtd.config,
+			axisgtd.time
 		FROM
 			axisgtd
-		JOIN 
+		JOIN
 			UID ON axisgtd.uid_name = UID.name
 		WHERE
-			uid_name = $1
+			uid_name = $1 AND UID.status
+		ORDER BY
+			axisgtd.time ASC
 	`
-
-	rows, err := db.Query(query, c.Params("name"))
+	rows, err := db.Query(query, uidName)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Get ID information Failed"})
+		return err
 	}
 	defer rows.Close()
 
-	var dataList []AxisGTDJsonType
-
-	for rows.Next() {
-		var axisgtd AxisGTDType
-		var status bool
-		err := rows.Scan(&axisgtd.Todolist, &axisgtd.Config, &axisgtd.Time, &status)
-		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"Error": "Get ID information Failed"})
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for first := true; rows.Next(); first = false {
+		var data AxisGTDJsonType
+		if err := rows.Scan(&data.Todolist, &data.Config, &data.Time); err != nil {
+			return err
 		}
-		if status {
-			dataList = append(dataList, AxisGTDJsonType{
-				Todolist: axisgtd.Todolist,
-				Config:   axisgtd.Config,
-				Time:     axisgtd.Time,
-			})
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(data); err != nil {
+			return err
 		}
 	}
-
-	if len(dataList) == 0 {
-		return c.Status(404).JSON(fiber.Map{"Error": "No records found"})
+	if err := rows.Err(); err != nil {
+		return err
 	}
-	return c.JSON(dataList)
+	_, err = io.WriteString(w, "]")
+	return err
 }
 
 // @Summary		Delete a UID and associated axisgtd records
@@ -127,13 +153,18 @@ func GetID(c *fiber.Ctx) error {
 // @Produce		json
 // @Param			name	path		string	true	"UID Name"
 // @Success		200		{string}	string	"UID and associated records deleted successfully"
-// @Failure		500		{string}	string	"Internal server error"
-// @Router			/id/{name} [delete]
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name} [delete]
 func DeleteID(c *fiber.Ctx) error {
 	err := DeleteUIDAndAxisGtdByUID(c.Params("name"))
+	if errors.Is(err, ErrNotFound) {
+		return uidNotFound(c.Params("name"))
+	}
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Delete ID Error"})
+		return storeError(err, "Delete ID Error")
 	}
+	uidChanged(c.Params("name"))
 	return c.Status(200).JSON(fiber.Map{"Success": "ID and associated records deleted successfully"})
 }
 
@@ -143,126 +174,101 @@ func DeleteID(c *fiber.Ctx) error {
 // @Accept			json
 // @Produce		json
 // @Success		200	{array}		IDSType
-// @Failure		500	{string}	string	"Internal server error"
-// @Router			/ids [get]
+// @Failure		500	{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids [get]
 func GetAllID(c *fiber.Ctx) error {
-	query := `
-		SELECT
-			UID.id,
-			UID.name,
-			UID.status,
-			COUNT(axisgtd.uid_name) AS axisgtd_count
-		FROM
-			UID
-		LEFT JOIN axisgtd ON UID.name = axisgtd.uid_name
-		GROUP BY
-			UID.id,UID.name, UID.status`
-	rows, err := db.Query(query)
+	ids, err := ListIDs()
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Get ID list Failed"})
-	}
-	defer rows.Close()
-
-	var ids []IDSType
-	for rows.Next() {
-		var preID IDSType
-		err := rows.Scan(&preID.Id, &preID.Name, &preID.Status, &preID.Count)
-		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"Error": "Get ID list Failed"})
-		}
-		ids = append(ids, preID)
+		return storeError(err, "Get ID list Failed")
 	}
-	sort.Slice(ids, func(i, j int) bool {
-		return ids[i].Id < ids[j].Id
-	})
 	return c.JSON(ids)
 }
 
 // @Summary		Toggle the status of a UID
-// @Description	Updates the status field of a UID to the opposite value.
+// @Description	Updates the status field of a UID to the opposite value. Use PATCH /api/v1/ids/{name} instead.
 // @Tags			status
+// @Deprecated
 // @Accept			json
 // @Produce		json
 // @Param			name	path		string	true	"UID Name"
 // @Success		200		{object}	string	"Status toggled successfully"
-// @Failure		404		{string}	string	"UID not found"
-// @Failure		500		{string}	string	"Internal server error"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
 // @Router			/status/{name} [get]
 func ToggleStatus(c *fiber.Ctx) error {
-	var uid UID
-	searchQuery := `SELECT name, status FROM UID WHERE name = $1`
-	err := db.QueryRow(searchQuery, c.Params("name")).Scan(&uid.Name, &uid.Status)
+	uid, err := GetUID(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "UID not found"})
+		return storeError(err, "Change Status Failed")
 	}
 
 	uid.Status = !uid.Status
 
-	updateQuery := `UPDATE UID SET status = $1 WHERE name = $2`
-	_, err = db.Exec(updateQuery, uid.Status, c.Params("name"))
+	err = SetUIDStatus(uid.Name, uid.Status)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Change Status Failed"})
+		return storeError(err, "Change Status Failed")
 	}
+	uidChanged(uid.Name)
 	return c.JSON(fiber.Map{"message": "Status toggled", "new_status": uid.Status})
 }
 
 // @Summary		Get the latest AxisGTD record by UID name
-// @Description	Retrieves the latest AxisGTD record associated with the specified UID name, ordered by time in descending order.
+// @Description	Retrieves the latest AxisGTD record associated with the specified UID name, ordered by time in descending order. With wait, the request blocks until a newer snapshot is stored and answers 304 on timeout.
 // @Tags			sync
 // @Accept			json
 // @Produce		json
 // @Param			name	path		string			true	"UID Name"
+// @Param			If-None-Match		header		string			false	"ETag of the copy the client has"
+// @Param			If-Modified-Since	header		string			false	"Last-Modified of the copy the client has"
+// @Param			wait				query		string			false	"Wait up to this long (e.g. 30s) for a snapshot newer than after"
+// @Param			after				query		string			false	"Time or ETag of the snapshot the client has, defaults to If-None-Match"
 // @Success		200		{object}	AxisGTDJsonType	"The latest AxisGTD record"
-// @Failure		404		{string}	string			"UID not found or no records available"
-// @Failure		500		{string}	string			"Internal server error"
-// @Router			/sync/{name} [get]
+// @Success		304		{string}	string			"The client's copy is current"
+// @Failure		403		{object}	ErrorType			"UID is disabled"
+// @Failure		404		{object}	ErrorType			"UID not found or no records available"
+// @Failure		500		{object}	ErrorType			"Internal server error"
+// @Failure		503		{object}	ErrorType			"Database unavailable"
+// @Router			/api/v1/ids/{name}/snapshots/latest [get]
 func SyncGet(c *fiber.Ctx) error {
-	query := `SELECT 
-            axisgtd.*, 
-            UID.name,
-			UID.status
-        FROM 
-            axisgtd 
-        JOIN 
-            UID 
-        ON 
-            axisgtd.uid_name = UID.name
-		WHERE
-			UID.name =$1
-		ORDER BY
-			time DESC
-		LIMIT 1;`
-	rows, err := db.Query(query, c.Params("name"))
-	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Get sync data Failed"})
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var axisgtd AxisGTDType
-		var uid UID
-		err := rows.Scan(&axisgtd.Todolist,
-			&axisgtd.Config,
-			&axisgtd.Time,
-			&axisgtd.UIDName,
-			&uid.Name,
-			&uid.Status)
+	if c.Query("wait") != "" {
+		changed, err := waitForChange(c)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"Error": "Get sync data Failed"})
+			return err
 		}
-		if uid.Status {
-			data := AxisGTDJsonType{
-				Todolist: axisgtd.Todolist,
-				Config:   axisgtd.Config,
-				Time:     axisgtd.Time,
-			}
-			return c.JSON(data)
-		} else {
-			return c.Status(404).JSON(fiber.Map{"Error": c.Params("name") + " not found"})
+		if !changed {
+			return c.SendStatus(fiber.StatusNotModified)
 		}
+	}
 
+	head, err := LatestSnapshot(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Get sync data Failed")
+	}
+	if !head.Status {
+		return uidDisabled(head.Name)
+	}
+	if !head.HasSnapshot {
+		return notFound("no_records", "No records available")
 	}
-	return c.Status(404).JSON(fiber.Map{"Error": "No records available"})
 
+	current := head.revision()
+	setRevisionHeaders(c, current)
+	if notModified(c, current) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	data := AxisGTDJsonType{
+		Todolist: head.Todolist,
+		Config:   head.Config,
+		Time:     head.Time,
+	}
+	observePayload("pull", data.Todolist, data.Config)
+	return c.JSON(data)
 }
 
 // @Summary		Create a new AxisGTD record
@@ -273,42 +279,45 @@ func SyncGet(c *fiber.Ctx) error {
 // @Param			name		path		string		true	"UID Name"
 // @Param			todo_data	body		AxisGTDType	true	"AxisGTD record to create"
 // @Success		200			{string}	string		"Record created successfully"
-// @Failure		404			{string}	string		"UID not found or UID is disabled"
-// @Failure		400			{string}	string		"Invalid request body"
-// @Failure		500			{string}	string		"Internal server error"
-// @Router			/sync/{name} [post]
+// @Failure		400			{object}	ErrorType		"Invalid request body"
+// @Failure		403			{object}	ErrorType		"UID is disabled"
+// @Failure		404			{object}	ErrorType		"UID not found"
+// @Param			Content-Encoding	header	string	false	"gzip, deflate, br or zstd"
+// @Failure		413			{object}	ErrorType		"A field or the UID's quota is too large"
+// @Failure		415			{object}	ErrorType		"Unsupported Content-Encoding"
+// @Failure		422			{object}	ErrorType		"todolist or config is not valid JSON, or time is out of range"
+// @Failure		500			{object}	ErrorType		"Internal server error"
+// @Failure		503			{object}	ErrorType		"Database unavailable"
+// @Router			/api/v1/ids/{name}/snapshots [post]
 func SyncPost(c *fiber.Ctx) error {
 
-	var exists bool
-	existsQuery := `SELECT EXISTS(SELECT 1 FROM UID WHERE name = $1)`
-	err := db.QueryRow(existsQuery, c.Params("name")).Scan(&exists)
-	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Post sync data Failed"})
-	}
-	if !exists {
-		return c.Status(404).JSON(fiber.Map{"Error": c.Params("name") + " not found"})
+	uid, err := GetUID(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
 	}
-
-	var status bool
-	statusQuery := `SELECT status FROM uid WHERE name=$1`
-	err = db.QueryRow(statusQuery, c.Params("name")).Scan(&status)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Post sync data Failed"})
+		return storeError(err, "Post sync data Failed")
 	}
-	if !status {
-		return c.Status(404).JSON(fiber.Map{"Error": c.Params("name") + " is disabled"})
+	if !uid.Status {
+		return uidDisabled(uid.Name)
 	}
 
 	todo_data := new(AxisGTDType)
 	if err := c.BodyParser(todo_data); err != nil {
+		return &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body", Message: "Invalid request body", Err: err}
+	}
+	observePayload("push", todo_data.Todolist, todo_data.Config)
+	if err := checkUpload(todo_data); err != nil {
 		return err
 	}
 
-	query := `INSERT INTO axisgtd (todolist,config,time,uid_name) VALUES ($1,$2,$3,$4)`
-	_, err = db.Exec(query, todo_data.Todolist, todo_data.Config, todo_data.Time, c.Params("name"))
-	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Post sync data Failed"})
+	if todo_data.Device == "" {
+		todo_data.Device = requestDevice(c)
 	}
+	if err := InsertSnapshot(uid.Name, *todo_data); err != nil {
+		return storeError(err, "Post sync data Failed")
+	}
+	uidChanged(uid.Name)
 
 	return c.SendStatus(200)
 }
@@ -321,17 +330,189 @@ func SyncPost(c *fiber.Ctx) error {
 // @Param			name	path		string	true	"UID Name"
 // @Param			time	path		int		true	"The record's time"
 // @Success		200		{string}	string	"Record deleted successfully"
-// @Failure		404		{string}	string	"Record not found"
-// @Failure		500		{string}	string	"Internal server error"
-// @Router			/delete/{name}/{time} [delete]
+// @Failure		400		{object}	ErrorType	"Invalid time"
+// @Failure		404		{object}	ErrorType	"Record not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/snapshots/{time} [delete]
 func DeleteRecord(c *fiber.Ctx) error {
 	timeVal, err := strconv.ParseInt(c.Params("time"), 10, 64)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Delete Record Failed"})
+		return badRequest("invalid_time", "time must be an integer, got %q", c.Params("time"))
 	}
 	err = DeleteDBRecord(c.Params("name"), timeVal)
+	if errors.Is(err, ErrNotFound) {
+		return notFound("record_not_found", "Record not found")
+	}
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"Error": "Record not found"})
+		return storeError(err, "Delete Record Failed")
 	}
+	uidChanged(c.Params("name"))
 	return c.SendStatus(200)
 }
+
+// @Summary		Get a UID
+// @Description	Retrieves the status and snapshot count of a UID.
+// @Tags			id
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Success		200		{object}	IDSType
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name} [get]
+func GetIDInfo(c *fiber.Ctx) error {
+	id, err := LookupID(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Get ID Failed")
+	}
+	return c.JSON(id)
+}
+
+// @Summary		Enable or disable a UID
+// @Description	Sets the status of a UID, disabled UIDs cannot sync.
+// @Tags			status
+// @Accept			json
+// @Produce		json
+// @Param			name	path		string		true	"UID Name"
+// @Param			patch	body		UIDPatch	true	"New status"
+// @Success		200		{object}	IDSType
+// @Failure		400		{object}	ErrorType	"Invalid request body"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name} [patch]
+func UpdateID(c *fiber.Ctx) error {
+	var patch UIDPatch
+	if err := c.BodyParser(&patch); err != nil {
+		return &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body", Message: "Invalid request body", Err: err}
+	}
+	if patch.Status == nil {
+		return badRequest("invalid_body", "status is required")
+	}
+
+	err := SetUIDStatus(c.Params("name"), *patch.Status)
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Change Status Failed")
+	}
+	uidChanged(c.Params("name"))
+	return GetIDInfo(c)
+}
+
+// @Summary		List the snapshots of a UID
+// @Description	Lists the time and size of every snapshot of a UID, newest first.
+// @Tags			snapshot
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Success		200		{array}		SnapshotInfo
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/snapshots [get]
+func GetSnapshots(c *fiber.Ctx) error {
+	if _, err := GetUID(c.Params("name")); err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	} else if err != nil {
+		return storeError(err, "Get snapshots Failed")
+	}
+
+	snapshots, err := ListSnapshots(c.Params("name"))
+	if err != nil {
+		return storeError(err, "Get snapshots Failed")
+	}
+	if snapshots == nil {
+		snapshots = []SnapshotInfo{}
+	}
+	return c.JSON(snapshots)
+}
+
+// @Summary		Get one snapshot
+// @Description	Retrieves the snapshot of a UID stored at the given time.
+// @Tags			snapshot
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Param			time	path		int		true	"The snapshot's time"
+// @Success		200		{object}	AxisGTDJsonType
+// @Failure		400		{object}	ErrorType	"Invalid time"
+// @Failure		404		{object}	ErrorType	"Snapshot not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/snapshots/{time} [get]
+func GetSnapshotByTime(c *fiber.Ctx) error {
+	timeVal, err := strconv.ParseInt(c.Params("time"), 10, 64)
+	if err != nil {
+		return badRequest("invalid_time", "time must be an integer, got %q", c.Params("time"))
+	}
+	snapshot, err := GetSnapshot(c.Params("name"), timeVal)
+	if err == sql.ErrNoRows {
+		return notFound("record_not_found", "Record not found")
+	}
+	if err != nil {
+		return storeError(err, "Get snapshot Failed")
+	}
+	if !snapshot.Status {
+		return uidDisabled(snapshot.Name)
+	}
+	return c.JSON(snapshot)
+}
+
+// @Summary		Restore a snapshot
+// @Description	Stores a copy of an old snapshot as the newest one, so clients pick it up on their next sync.
+// @Tags			snapshot
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Param			time	path		int		true	"The snapshot's time"
+// @Success		200		{object}	SnapshotInfo	"The restored copy"
+// @Failure		400		{object}	ErrorType		"Invalid time"
+// @Failure		404		{object}	ErrorType		"Snapshot not found"
+// @Failure		500		{object}	ErrorType		"Internal server error"
+// @Router			/api/v1/ids/{name}/snapshots/{time}/restore [post]
+func RestoreRecord(c *fiber.Ctx) error {
+	timeVal, err := strconv.ParseInt(c.Params("time"), 10, 64)
+	if err != nil {
+		return badRequest("invalid_time", "time must be an integer, got %q", c.Params("time"))
+	}
+	restored, err := RestoreSnapshot(c.Params("name"), timeVal, requestDevice(c))
+	if err == sql.ErrNoRows {
+		return notFound("record_not_found", "Record not found")
+	}
+	if err != nil {
+		return storeError(err, "Restore Record Failed")
+	}
+	uidChanged(c.Params("name"))
+	return c.JSON(SnapshotInfo{Time: restored})
+}
+
+// @Summary		Set the quota of a UID
+// @Description	Limits the bytes and snapshots a UID may store, 0 uses the server defaults. Needs the admin token.
+// @Tags			status
+// @Accept			json
+// @Produce		json
+// @Security		APIKeyAuth
+// @Param			name	path		string		true	"UID Name"
+// @Param			quota	body		QuotaType	true	"New quota"
+// @Success		200		{object}	IDSType
+// @Failure		400		{object}	ErrorType	"Invalid quota"
+// @Failure		401		{object}	ErrorType	"Invalid admin token"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/quota [put]
+func SetQuota(c *fiber.Ctx) error {
+	var quota QuotaType
+	if err := c.BodyParser(&quota); err != nil {
+		return &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body", Message: "Invalid request body", Err: err}
+	}
+	if quota.MaxBytes < 0 || quota.MaxSnapshots < 0 {
+		return badRequest("invalid_quota", "max_bytes and max_snapshots must not be negative")
+	}
+
+	err := SetUIDQuota(c.Params("name"), quota)
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Set quota Failed")
+	}
+	return GetIDInfo(c)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminAuth only lets requests through that carry the configured adminToken
+// as "Authorization: Bearer <token>". Without an adminToken every admin
+// route is refused.
+func AdminAuth(c *fiber.Ctx) error {
+	if config.AdminToken == "" {
+		return newError(fiber.StatusForbidden, "admin_disabled", "Admin access is disabled, set adminToken to enable it")
+	}
+	token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) != 1 {
+		return newError(fiber.StatusUnauthorized, "unauthorized", "Unauthorized")
+	}
+	return c.Next()
+}
+
+// DAVAuth lets CalDAV requests through that carry the CalDAV secret of the
+// UID in the path as the password of HTTP Basic auth, the user name is not
+// checked. A UID that doesn't exist or has no secret is refused the same
+// way, so the answer doesn't tell whether a name is taken. OPTIONS passes,
+// clients ask for it before authenticating.
+func DAVAuth(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodOptions {
+		return c.Next()
+	}
+	password := ""
+	if encoded, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Basic "); ok {
+		if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			_, password, _ = strings.Cut(string(decoded), ":")
+		}
+	}
+
+	secret, err := GetDAVSecret(c.Params("name"))
+	if err != nil && err != sql.ErrNoRows {
+		return storeError(err, "Check CalDAV secret Failed")
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(password), []byte(secret)) != 1 {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="AxisGTD", charset="UTF-8"`)
+		return newError(fiber.StatusUnauthorized, "unauthorized", "Unauthorized")
+	}
+	return c.Next()
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"container/list"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// changesChannel is the NOTIFY channel the triggers of migration 4 send the
+// name of every changed UID to, from this or any other instance, the
+// command line or plain SQL.
+const changesChannel = "axisgtd_changes"
+
+// headSnapshot is what SyncGet needs of a UID: its status and its newest
+// snapshot, if it has one, with its revision hashed once when loaded.
+type headSnapshot struct {
+	AxisGTDJsonType
+	HasSnapshot bool
+	rev         revision
+}
+
+func (h headSnapshot) revision() revision {
+	return h.rev
+}
+
+// heads caches headSnapshot by UID name. It stays disabled (nil) unless
+// cache.size is set and the listener keeping it in sync with the database is
+// running.
+var heads *headCache
+
+var cacheRequests *prometheus.CounterVec
+
+type headEntry struct {
+	name string
+	head headSnapshot
+}
+
+// headCache is an LRU bounded by entries and by the bytes of their todolists
+// and configs, maxBytes 0 is no byte limit. gen is bumped by every
+// invalidation, so a load that raced with one is not stored.
+type headCache struct {
+	mu       sync.Mutex
+	size     int
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*list.Element
+	order    *list.List
+	gen      uint64
+	online   bool
+}
+
+func newHeadCache(size int, maxBytes int) *headCache {
+	return &headCache{
+		size:     size,
+		maxBytes: int64(maxBytes),
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (h headSnapshot) bytes() int64 {
+	return int64(len(h.Todolist) + len(h.Config))
+}
+
+func (hc *headCache) get(name string) (headSnapshot, uint64, bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if !hc.online {
+		return headSnapshot{}, hc.gen, false
+	}
+	elem, ok := hc.entries[name]
+	if !ok {
+		return headSnapshot{}, hc.gen, false
+	}
+	hc.order.MoveToFront(elem)
+	return elem.Value.(*headEntry).head, hc.gen, true
+}
+
+// put stores a copy of name, the handlers pass route parameters whose memory
+// Fiber reuses for the next request.
+func (hc *headCache) put(name string, head headSnapshot, gen uint64) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if !hc.online || gen != hc.gen {
+		return
+	}
+	name = strings.Clone(name)
+	if elem, ok := hc.entries[name]; ok {
+		hc.remove(elem)
+	}
+	// A snapshot over the whole budget would only evict everything else.
+	if hc.maxBytes > 0 && head.bytes() > hc.maxBytes {
+		return
+	}
+	hc.entries[name] = hc.order.PushFront(&headEntry{name: name, head: head})
+	hc.bytes += head.bytes()
+	for hc.order.Len() > hc.size || (hc.maxBytes > 0 && hc.bytes > hc.maxBytes) {
+		hc.remove(hc.order.Back())
+	}
+}
+
+func (hc *headCache) remove(elem *list.Element) {
+	entry := hc.order.Remove(elem).(*headEntry)
+	delete(hc.entries, entry.name)
+	hc.bytes -= entry.head.bytes()
+}
+
+func (hc *headCache) invalidate(name string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.gen++
+	if elem, ok := hc.entries[name]; ok {
+		hc.remove(elem)
+	}
+}
+
+// reset empties the cache and sets whether it may be used. It is taken
+// offline while the listener is disconnected, notifications are lost then.
+func (hc *headCache) reset(online bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.gen++
+	hc.online = online
+	hc.entries = make(map[string]*list.Element)
+	hc.order.Init()
+	hc.bytes = 0
+}
+
+func (hc *headCache) len() int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.order.Len()
+}
+
+func (hc *headCache) usage() int64 {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.bytes
+}
+
+var listener *pq.Listener
+
+// StartCache enables the head cache and listens for changes, call it after
+// InitDB so the triggers exist. It does nothing when cache.size is 0.
+func StartCache() error {
+	if config.Cache.Size <= 0 {
+		return nil
+	}
+	cache := newHeadCache(config.Cache.Size, config.Cache.MaxBytes)
+
+	listener = pq.NewListener(config.PSQLURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			slog.Warn("cache: lost the change listener, bypassing the cache until it reconnects", "error", err)
+			cache.reset(false)
+		case pq.ListenerEventConnectionAttemptFailed:
+			slog.Debug("cache: reconnecting the change listener failed", "error", err)
+		}
+	})
+	if err := listener.Listen(changesChannel); err != nil {
+		listener.Close()
+		listener = nil
+		return err
+	}
+
+	cache.reset(true)
+	heads = cache
+	go func() {
+		for notification := range listener.NotificationChannel() {
+			if notification == nil {
+				// Reconnected, whatever changed in between is unknown.
+				slog.Info("cache: change listener reconnected")
+				cache.reset(true)
+				hub.publishAll()
+				continue
+			}
+			cache.invalidate(notification.Extra)
+			hub.publish(notification.Extra)
+		}
+	}()
+	return nil
+}
+
+func stopCache() {
+	if listener != nil {
+		listener.Close()
+	}
+}
+
+// LatestSnapshot returns the status and newest snapshot of a UID, from the
+// cache when possible. A missing UID is sql.ErrNoRows.
+func LatestSnapshot(uidName string) (headSnapshot, error) {
+	var gen uint64
+	if heads != nil {
+		head, g, ok := heads.get(uidName)
+		observeCache(ok)
+		if ok {
+			return head, nil
+		}
+		gen = g
+	}
+
+	var head headSnapshot
+	var todolist, cfg sql.NullString
+	var t sql.NullInt64
+	query := `
+		SELECT UID.name, UID.status, latest.todolist, latest.config, latest.time
+		FROM UID
+		LEFT JOIN LATERAL (
+			SELECT todolist, config, time
+			FROM axisgtd
+			WHERE axisgtd.uid_name = UID.name
+			ORDER BY time DESC
+			LIMIT 1
+		) latest ON true
+		WHERE UID.name = $1`
+	err := db.QueryRow(query, uidName).Scan(&head.Name, &head.Status, &todolist, &cfg, &t)
+	if err != nil {
+		return head, err
+	}
+	head.Todolist, head.Config, head.Time = todolist.String, cfg.String, t.Int64
+	head.HasSnapshot = t.Valid
+	if head.HasSnapshot {
+		head.rev = snapshotRevision(head.Todolist, head.Config, head.Time)
+	}
+
+	if heads != nil {
+		heads.put(uidName, head, gen)
+	}
+	return head, nil
+}
+
+func observeCache(hit bool) {
+	if cacheRequests == nil {
+		return
+	}
+	if hit {
+		cacheRequests.WithLabelValues("hit").Inc()
+	} else {
+		cacheRequests.WithLabelValues("miss").Inc()
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestHeadCache(t *testing.T) {
+	head := func(name string) headSnapshot {
+		return headSnapshot{AxisGTDJsonType: AxisGTDJsonType{Name: name}, HasSnapshot: true}
+	}
+	sized := func(name string, n int) headSnapshot {
+		h := head(name)
+		h.Todolist = strings.Repeat("x", n)
+		return h
+	}
+	put := func(hc *headCache, h headSnapshot) {
+		_, gen, _ := hc.get(h.Name)
+		hc.put(h.Name, h, gen)
+	}
+
+	tests := []struct {
+		name string
+		run  func(hc *headCache)
+		want []string
+	}{
+		{"offline", func(hc *headCache) {
+			hc.reset(false)
+			_, gen, _ := hc.get("a")
+			hc.put("a", head("a"), gen)
+		}, nil},
+		{"put", func(hc *headCache) {
+			_, gen, _ := hc.get("a")
+			hc.put("a", head("a"), gen)
+		}, []string{"a"}},
+		{"evicts the least recently used", func(hc *headCache) {
+			for _, name := range []string{"a", "b"} {
+				_, gen, _ := hc.get(name)
+				hc.put(name, head(name), gen)
+			}
+			hc.get("a")
+			_, gen, _ := hc.get("c")
+			hc.put("c", head("c"), gen)
+		}, []string{"a", "c"}},
+		{"invalidate", func(hc *headCache) {
+			_, gen, _ := hc.get("a")
+			hc.put("a", head("a"), gen)
+			hc.invalidate("a")
+		}, nil},
+		// A load that started before an invalidation may hold the old
+		// snapshot, so it must not be stored.
+		{"stale load", func(hc *headCache) {
+			_, gen, _ := hc.get("a")
+			hc.invalidate("b")
+			hc.put("a", head("a"), gen)
+		}, nil},
+		// The cache holds up to 10 bytes.
+		{"evicts by bytes", func(hc *headCache) {
+			put(hc, sized("a", 6))
+			put(hc, sized("b", 6))
+		}, []string{"b"}},
+		{"replaced entry", func(hc *headCache) {
+			put(hc, sized("a", 6))
+			put(hc, sized("a", 3))
+			put(hc, sized("b", 6))
+		}, []string{"a", "b"}},
+		{"too large", func(hc *headCache) {
+			put(hc, sized("a", 2))
+			put(hc, sized("b", 11))
+		}, []string{"a"}},
+		{"reset", func(hc *headCache) {
+			_, gen, _ := hc.get("a")
+			hc.put("a", head("a"), gen)
+			hc.reset(true)
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := newHeadCache(2, 10)
+			hc.reset(true)
+			tt.run(hc)
+			if hc.len() != len(tt.want) {
+				t.Errorf("len = %d, want %d", hc.len(), len(tt.want))
+			}
+			var bytes int64
+			for _, name := range tt.want {
+				got, _, ok := hc.get(name)
+				if !ok || got.Name != name {
+					t.Errorf("get(%s) = %+v, %t", name, got, ok)
+				}
+				bytes += got.bytes()
+			}
+			if hc.usage() != bytes {
+				t.Errorf("usage = %d, want %d", hc.usage(), bytes)
+			}
+		})
+	}
+}
+
+// The names come from route parameters, the cache must not keep their memory.
+func TestHeadCacheCopiesName(t *testing.T) {
+	hc := newHeadCache(2, 0)
+	hc.reset(true)
+	buf := []byte("a")
+	name := utils.UnsafeString(buf)
+	_, gen, _ := hc.get(name)
+	hc.put(name, headSnapshot{AxisGTDJsonType: AxisGTDJsonType{Name: "a"}}, gen)
+	buf[0] = 'b'
+
+	if _, _, ok := hc.get("a"); !ok {
+		t.Error("entry of a lost once the request memory was reused")
+	}
+}
@@ -0,0 +1,876 @@
+package api
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CalDAV (RFC 4791) access to the tasks of a UID, enough for task apps like
+// Thunderbird or tasks.org through DAVx5. /dav/{name}/ is the principal and
+// calendar home, /dav/{name}/tasks/ its only collection and
+// /dav/{name}/tasks/{id}.ics one task as a VTODO. Like the task API, every
+// PUT or DELETE is stored as a new snapshot.
+
+const (
+	davNS    = "DAV:"
+	caldavNS = "urn:ietf:params:xml:ns:caldav"
+	csNS     = "http://calendarserver.org/ns/"
+)
+
+// DAVMethods are the request methods CalDAV uses beyond the standard ones.
+var DAVMethods = []string{"PROPFIND", "REPORT"}
+
+// icalUIDKey keeps the UID a CalDAV client gave a task it created, clients
+// expect to get it back unchanged.
+const icalUIDKey = "icalUID"
+
+// Sync tokens name the snapshot a client has seen, changes since then are
+// found by comparing its tasks with the newest ones.
+const syncTokenPrefix = "urn:axisgtdsync:sync:"
+
+var errNoVTodo = errors.New("no VTODO")
+
+type davKind int
+
+const (
+	davHome davKind = iota
+	davCollection
+	davTask
+)
+
+type davResource struct {
+	kind davKind
+	href string
+	head headSnapshot
+	task TaskType
+	data string
+	etag string
+}
+
+func davHomeHref(name string) string {
+	return "/dav/" + url.PathEscape(name) + "/"
+}
+
+func davCollectionHref(name string) string {
+	return davHomeHref(name) + "tasks/"
+}
+
+// taskResource renders a task with the snapshot time as its DTSTAMP. That
+// changes with every sync, so the ETag is taken from the task rendered
+// without it and only changes with the task.
+func taskResource(head headSnapshot, task TaskType) davResource {
+	data := writeCalendar(head.Name, "VTODO", head.Time, []TaskType{task})
+	sum := md5.Sum([]byte(writeCalendar(head.Name, "VTODO", 0, []TaskType{task})))
+	return davResource{
+		kind: davTask,
+		href: davCollectionHref(head.Name) + url.PathEscape(task.ID) + ".ics",
+		head: head,
+		task: task,
+		data: data,
+		etag: `"` + hex.EncodeToString(sum[:]) + `"`,
+	}
+}
+
+// taskResources returns the tasks of list that have an id, list may be nil.
+func taskResources(head headSnapshot, list *taskList) []davResource {
+	if list == nil {
+		return nil
+	}
+	resources := make([]davResource, 0, len(list.items))
+	for i, id := range list.ids {
+		if id != "" {
+			resources = append(resources, taskResource(head, list.task(i)))
+		}
+	}
+	return resources
+}
+
+// findResource returns the task resource with the id, or nil.
+func findResource(head headSnapshot, list *taskList, id string) *davResource {
+	if list == nil {
+		return nil
+	}
+	if i := list.find(id); i >= 0 && id != "" {
+		r := taskResource(head, list.task(i))
+		return &r
+	}
+	return nil
+}
+
+func davName(space string, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
+
+// davAllProps answer an allprop or propname PROPFIND.
+var davAllProps = []xml.Name{
+	davName(davNS, "resourcetype"),
+	davName(davNS, "displayname"),
+	davName(davNS, "getetag"),
+	davName(davNS, "getcontenttype"),
+	davName(davNS, "getlastmodified"),
+}
+
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// prop returns the XML content of a property, false if the resource has
+// no such property.
+func (r davResource) prop(name xml.Name) (string, bool) {
+	home := "<d:href>" + xmlText(davHomeHref(r.head.Name)) + "</d:href>"
+	switch name {
+	case davName(davNS, "resourcetype"):
+		switch r.kind {
+		case davHome:
+			return "<d:collection/><d:principal/>", true
+		case davCollection:
+			return "<d:collection/><c:calendar/>", true
+		}
+		return "", true
+	case davName(davNS, "displayname"):
+		switch r.kind {
+		case davHome:
+			return xmlText(r.head.Name), true
+		case davCollection:
+			return "AxisGTD", true
+		}
+		return xmlText(r.task.Title), true
+	case davName(davNS, "current-user-principal"):
+		return home, true
+	case davName(davNS, "principal-URL"), davName(caldavNS, "calendar-home-set"):
+		return home, r.kind == davHome
+	case davName(davNS, "current-user-privilege-set"):
+		return "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>", true
+	case davName(caldavNS, "supported-calendar-component-set"):
+		return `<c:comp name="VTODO"/>`, r.kind == davCollection
+	case davName(davNS, "supported-report-set"):
+		return "<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+			"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>" +
+			"<d:supported-report><d:report><d:sync-collection/></d:report></d:supported-report>", r.kind == davCollection
+	case davName(davNS, "sync-token"), davName(csNS, "getctag"):
+		return syncTokenPrefix + strconv.FormatInt(r.head.Time, 10), r.kind == davCollection
+	case davName(davNS, "getetag"):
+		switch r.kind {
+		case davCollection:
+			return xmlText(r.head.revision().ETag()), r.head.HasSnapshot
+		case davTask:
+			return xmlText(r.etag), true
+		}
+	case davName(davNS, "getcontenttype"):
+		return "text/calendar; charset=utf-8; component=VTODO", r.kind == davTask
+	case davName(davNS, "getlastmodified"):
+		return time.UnixMilli(r.head.Time).UTC().Format(http.TimeFormat), r.kind == davCollection && r.head.HasSnapshot
+	case davName(caldavNS, "calendar-data"):
+		return xmlText(r.data), r.kind == davTask
+	}
+	return "", false
+}
+
+// multistatus builds a 207 Multi-Status response.
+type multistatus struct {
+	b strings.Builder
+}
+
+func newMultistatus() *multistatus {
+	m := new(multistatus)
+	m.b.WriteString(xml.Header)
+	m.b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + caldavNS + `" xmlns:cs="` + csNS + `">`)
+	return m
+}
+
+// response writes the requested properties of r, the ones it lacks as 404.
+// With all, props is ignored and every property of davAllProps r has is
+// written, without values if names is set.
+func (m *multistatus) response(r davResource, props []xml.Name, all bool, names bool) {
+	var found, missing strings.Builder
+	if all {
+		props = davAllProps
+	}
+	for _, name := range props {
+		value, ok := r.prop(name)
+		switch {
+		case ok && names:
+			fmt.Fprintf(&found, `<%s xmlns="%s"/>`, name.Local, xmlText(name.Space))
+		case ok:
+			fmt.Fprintf(&found, `<%s xmlns="%s">%s</%s>`, name.Local, xmlText(name.Space), value, name.Local)
+		case !all:
+			fmt.Fprintf(&missing, `<%s xmlns="%s"/>`, name.Local, xmlText(name.Space))
+		}
+	}
+
+	m.b.WriteString("<d:response><d:href>" + xmlText(r.href) + "</d:href>")
+	if found.Len() > 0 || missing.Len() == 0 {
+		m.b.WriteString("<d:propstat><d:prop>" + found.String() + "</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+	}
+	if missing.Len() > 0 {
+		m.b.WriteString("<d:propstat><d:prop>" + missing.String() + "</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+	}
+	m.b.WriteString("</d:response>")
+}
+
+// missing reports a resource that doesn't exist (anymore).
+func (m *multistatus) missing(href string) {
+	m.b.WriteString("<d:response><d:href>" + xmlText(href) + "</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>")
+}
+
+func (m *multistatus) send(c *fiber.Ctx, syncToken string) error {
+	if syncToken != "" {
+		m.b.WriteString("<d:sync-token>" + xmlText(syncToken) + "</d:sync-token>")
+	}
+	m.b.WriteString("</d:multistatus>")
+	c.Set(fiber.HeaderContentType, "application/xml; charset=utf-8")
+	return c.Status(fiber.StatusMultiStatus).SendString(m.b.String())
+}
+
+// davPrecondition answers with a DAV:error body naming the failed
+// precondition, like valid-sync-token.
+func davPrecondition(c *fiber.Ctx, status int, condition string) error {
+	c.Set(fiber.HeaderContentType, "application/xml; charset=utf-8")
+	return c.Status(status).SendString(xml.Header + `<d:error xmlns:d="DAV:" xmlns:c="` + caldavNS + `">` + condition + "</d:error>")
+}
+
+type davAny struct {
+	XMLName xml.Name
+}
+
+type davProp struct {
+	Names []davAny `xml:",any"`
+}
+
+func (p davProp) names() []xml.Name {
+	names := make([]xml.Name, len(p.Names))
+	for i, n := range p.Names {
+		names[i] = n.XMLName
+	}
+	return names
+}
+
+type davPropfind struct {
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     davProp   `xml:"DAV: prop"`
+}
+
+type davCompFilter struct {
+	Name  string          `xml:"name,attr"`
+	Comps []davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type davReport struct {
+	XMLName   xml.Name
+	Prop      davProp  `xml:"DAV: prop"`
+	Hrefs     []string `xml:"DAV: href"`
+	SyncToken string   `xml:"DAV: sync-token"`
+	Filter    struct {
+		Comp davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// davHead loads the newest snapshot of the UID in the request like
+// headTasks does, but a UID without snapshots is an empty collection.
+func davHead(c *fiber.Ctx) (headSnapshot, *taskList, error) {
+	head, err := LatestSnapshot(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return head, nil, uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return head, nil, storeError(err, "Get tasks Failed")
+	}
+	if !head.Status {
+		return head, nil, uidDisabled(head.Name)
+	}
+	if !head.HasSnapshot {
+		return head, nil, nil
+	}
+	list, err := parseTaskList(head.Todolist)
+	if err != nil {
+		return head, nil, newError(fiber.StatusUnprocessableEntity, "unsupported_todolist", "%v", err)
+	}
+	return head, list, nil
+}
+
+// davTaskID returns the task id of the :file parameter, which must end in
+// .ics.
+func davTaskID(c *fiber.Ctx) (string, error) {
+	file, err := url.PathUnescape(c.Params("file"))
+	if err != nil {
+		return "", badRequest("invalid_path", "invalid resource name %q", c.Params("file"))
+	}
+	id, ok := strings.CutSuffix(file, ".ics")
+	if !ok || id == "" {
+		return "", notFound("task_not_found", "%s not found", file)
+	}
+	return id, nil
+}
+
+func davPropfindBody(c *fiber.Ctx) (davPropfind, error) {
+	var body davPropfind
+	if len(c.Body()) == 0 {
+		body.AllProp = &struct{}{}
+		return body, nil
+	}
+	if err := xml.Unmarshal(c.Body(), &body); err != nil {
+		return body, &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body", Message: "Invalid PROPFIND body", Err: err}
+	}
+	return body, nil
+}
+
+// @Summary		Create a CalDAV secret
+// @Description	Creates or replaces the password CalDAV clients sign in with, with any user name. Replacing it signs out the clients that use the old one.
+// @Tags			caldav
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Success		200		{object}	DAVSecretType
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/caldav-secret [post]
+func CreateDAVSecret(c *fiber.Ctx) error {
+	secret, err := GenerateRandomHex(32)
+	if err != nil {
+		return err
+	}
+	err = SetDAVSecret(c.Params("name"), secret)
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Create CalDAV secret Failed")
+	}
+	return c.JSON(DAVSecretType{Secret: secret, URL: c.BaseURL() + davHomeHref(c.Params("name"))})
+}
+
+// @Summary		Revoke the CalDAV secret
+// @Description	Removes the CalDAV password of a UID, which signs out every CalDAV client.
+// @Tags			caldav
+// @Param			name	path		string	true	"UID Name"
+// @Success		204		"Revoked"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/caldav-secret [delete]
+func DeleteDAVSecret(c *fiber.Ctx) error {
+	err := SetDAVSecret(c.Params("name"), "")
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Revoke CalDAV secret Failed")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DAVOptions announces the CalDAV support of every DAV route.
+func DAVOptions(c *fiber.Ctx) error {
+	c.Set("DAV", "1, 3, calendar-access")
+	c.Set(fiber.HeaderAllow, "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// PropfindHome describes the principal and calendar home of a UID, with
+// Depth: 1 also its task collection.
+func PropfindHome(c *fiber.Ctx) error {
+	body, err := davPropfindBody(c)
+	if err != nil {
+		return err
+	}
+	head, _, err := davHead(c)
+	if err != nil {
+		return err
+	}
+	m := newMultistatus()
+	m.response(davResource{kind: davHome, href: davHomeHref(head.Name), head: head}, body.Prop.names(), body.AllProp != nil, body.PropName != nil)
+	if c.Get("Depth") != "0" {
+		m.response(davResource{kind: davCollection, href: davCollectionHref(head.Name), head: head}, body.Prop.names(), body.AllProp != nil, body.PropName != nil)
+	}
+	return m.send(c, "")
+}
+
+// PropfindTasks describes the task collection, with Depth: 1 also every
+// task in it.
+func PropfindTasks(c *fiber.Ctx) error {
+	body, err := davPropfindBody(c)
+	if err != nil {
+		return err
+	}
+	head, list, err := davHead(c)
+	if err != nil {
+		return err
+	}
+	m := newMultistatus()
+	m.response(davResource{kind: davCollection, href: davCollectionHref(head.Name), head: head}, body.Prop.names(), body.AllProp != nil, body.PropName != nil)
+	if c.Get("Depth") != "0" {
+		for _, r := range taskResources(head, list) {
+			m.response(r, body.Prop.names(), body.AllProp != nil, body.PropName != nil)
+		}
+	}
+	return m.send(c, "")
+}
+
+// PropfindTask describes one task.
+func PropfindTask(c *fiber.Ctx) error {
+	id, err := davTaskID(c)
+	if err != nil {
+		return err
+	}
+	body, err := davPropfindBody(c)
+	if err != nil {
+		return err
+	}
+	head, list, err := davHead(c)
+	if err != nil {
+		return err
+	}
+	r := findResource(head, list, id)
+	if r == nil {
+		return notFound("task_not_found", "task %s not found", id)
+	}
+	m := newMultistatus()
+	m.response(*r, body.Prop.names(), body.AllProp != nil, body.PropName != nil)
+	return m.send(c, "")
+}
+
+// ReportTasks answers the calendar-query, calendar-multiget and
+// sync-collection reports on the task collection. calendar-query only
+// looks at the component filter, time ranges and property filters are left
+// to the client.
+func ReportTasks(c *fiber.Ctx) error {
+	var report davReport
+	if err := xml.Unmarshal(c.Body(), &report); err != nil {
+		return &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body", Message: "Invalid REPORT body", Err: err}
+	}
+	head, list, err := davHead(c)
+	if err != nil {
+		return err
+	}
+	props := report.Prop.names()
+	m := newMultistatus()
+
+	switch report.XMLName {
+	case davName(caldavNS, "calendar-query"):
+		filter := report.Filter.Comp
+		if filter.Name != "" && (filter.Name != "VCALENDAR" || (len(filter.Comps) > 0 && filter.Comps[0].Name != "VTODO")) {
+			return m.send(c, "")
+		}
+		for _, r := range taskResources(head, list) {
+			m.response(r, props, false, false)
+		}
+		return m.send(c, "")
+
+	case davName(caldavNS, "calendar-multiget"):
+		collection := davCollectionHref(head.Name)
+		for _, href := range report.Hrefs {
+			var r *davResource
+			if u, err := url.Parse(strings.TrimSpace(href)); err == nil {
+				if file, ok := strings.CutPrefix(u.Path, collection); ok {
+					if id, ok := strings.CutSuffix(file, ".ics"); ok {
+						r = findResource(head, list, id)
+					}
+				}
+			}
+			if r == nil {
+				m.missing(href)
+				continue
+			}
+			m.response(*r, props, false, false)
+		}
+		return m.send(c, "")
+
+	case davName(davNS, "sync-collection"):
+		token := syncTokenPrefix + strconv.FormatInt(head.Time, 10)
+		current := taskResources(head, list)
+		if report.SyncToken == "" {
+			for _, r := range current {
+				m.response(r, props, false, false)
+			}
+			return m.send(c, token)
+		}
+
+		since, err := davSyncSince(head, report.SyncToken)
+		if errors.Is(err, errInvalidSyncToken) {
+			return davPrecondition(c, fiber.StatusForbidden, "<d:valid-sync-token/>")
+		}
+		if err != nil {
+			return storeError(err, "Sync collection Failed")
+		}
+		seen := make(map[string]bool, len(since))
+		for _, r := range current {
+			seen[r.task.ID] = true
+			if since[r.task.ID] != r.etag {
+				m.response(r, props, false, false)
+			}
+		}
+		for id := range since {
+			if !seen[id] {
+				m.missing(davCollectionHref(head.Name) + url.PathEscape(id) + ".ics")
+			}
+		}
+		return m.send(c, token)
+	}
+	return davPrecondition(c, fiber.StatusForbidden, "<d:supported-report/>")
+}
+
+var errInvalidSyncToken = errors.New("invalid sync token")
+
+// davSyncSince returns the ETags of the tasks by id as of a sync token. A
+// token of a snapshot that was deleted or pruned meanwhile is invalid, the
+// client then syncs from scratch.
+func davSyncSince(head headSnapshot, token string) (map[string]string, error) {
+	value, ok := strings.CutPrefix(token, syncTokenPrefix)
+	if !ok {
+		return nil, errInvalidSyncToken
+	}
+	t, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || t > head.Time {
+		return nil, errInvalidSyncToken
+	}
+
+	etags := map[string]string{}
+	if t == 0 {
+		return etags, nil
+	}
+	snapshot, err := GetSnapshot(head.Name, t)
+	if err == sql.ErrNoRows {
+		return nil, errInvalidSyncToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	list, err := parseTaskList(snapshot.Todolist)
+	if err != nil {
+		return nil, errInvalidSyncToken
+	}
+	for _, r := range taskResources(headSnapshot{AxisGTDJsonType: snapshot, HasSnapshot: true}, list) {
+		etags[r.task.ID] = r.etag
+	}
+	return etags, nil
+}
+
+// GetDAVTask serves one task as an iCalendar object with one VTODO.
+func GetDAVTask(c *fiber.Ctx) error {
+	id, err := davTaskID(c)
+	if err != nil {
+		return err
+	}
+	head, list, err := davHead(c)
+	if err != nil {
+		return err
+	}
+	r := findResource(head, list, id)
+	if r == nil {
+		return notFound("task_not_found", "task %s not found", id)
+	}
+	c.Set(fiber.HeaderETag, r.etag)
+	if strings.Contains(c.Get(fiber.HeaderIfNoneMatch), r.etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return c.SendString(r.data)
+}
+
+// PutDAVTask creates or replaces a task from the VTODO in the body.
+func PutDAVTask(c *fiber.Ctx) error {
+	id, err := davTaskID(c)
+	if err != nil {
+		return err
+	}
+	todo, err := parseVTodo(string(c.Body()))
+	if errors.Is(err, errNoVTodo) {
+		return davPrecondition(c, fiber.StatusForbidden, "<c:supported-calendar-component/>")
+	}
+	if err != nil {
+		return badRequest("invalid_calendar", "%v", err)
+	}
+
+	created := false
+	err = changeDAVTask(c, func(head headSnapshot, list *taskList) error {
+		r := findResource(head, list, id)
+		if err := davPreconditions(c, r); err != nil {
+			return err
+		}
+		var item map[string]any
+		var current *TaskType
+		created = r == nil
+		if created {
+			item = list.newItem(id)
+			list.setTime(item, "createdAt", time.Now().UnixMilli())
+			if todo.uid != "" {
+				item[icalUIDKey] = todo.uid
+			}
+		} else {
+			item = list.items[list.find(id)]
+			current = &r.task
+		}
+
+		patch := todo.patch(current)
+		if err := list.apply(item, patch); err != nil {
+			return badRequest("invalid_task", "%v", err)
+		}
+		if patch.Done != nil && todo.done && todo.completed != 0 {
+			list.setTime(item, "completedAt", todo.completed)
+		}
+		if created {
+			list.add(id, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if created {
+		return c.SendStatus(fiber.StatusCreated)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DeleteDAVTask removes a task from the todolist.
+func DeleteDAVTask(c *fiber.Ctx) error {
+	id, err := davTaskID(c)
+	if err != nil {
+		return err
+	}
+	err = changeDAVTask(c, func(head headSnapshot, list *taskList) error {
+		r := findResource(head, list, id)
+		if r == nil {
+			return notFound("task_not_found", "task %s not found", id)
+		}
+		if err := davPreconditions(c, r); err != nil {
+			return err
+		}
+		list.remove(list.find(id))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// davPreconditions checks If-Match and If-None-Match against the task r,
+// nil if it doesn't exist yet.
+func davPreconditions(c *fiber.Ctx, r *davResource) error {
+	if match := c.Get(fiber.HeaderIfMatch); match != "" && (r == nil || (match != "*" && !strings.Contains(match, r.etag))) {
+		return newError(fiber.StatusPreconditionFailed, "precondition_failed", "the task changed since %s", match)
+	}
+	if c.Get(fiber.HeaderIfNoneMatch) == "*" && r != nil {
+		return newError(fiber.StatusPreconditionFailed, "precondition_failed", "the task already exists")
+	}
+	return nil
+}
+
+// changeDAVTask applies change to the newest todolist and stores it. When
+// an app synced meanwhile, the change is applied again to its snapshot: the
+// preconditions of a CalDAV client are about one task, not the todolist.
+func changeDAVTask(c *fiber.Ctx, change func(head headSnapshot, list *taskList) error) error {
+	for attempt := 1; ; attempt++ {
+		head, list, err := davHead(c)
+		if err != nil {
+			return err
+		}
+		if list == nil {
+			return newError(fiber.StatusConflict, "no_records", "%s has no snapshot yet, sync from the app first", head.Name)
+		}
+		if err := change(head, list); err != nil {
+			return err
+		}
+		_, err = storeTasks(head, list, requestDevice(c))
+		if errors.Is(err, ErrHeadChanged) && attempt < 3 {
+			continue
+		}
+		return err
+	}
+}
+
+// vtodo holds the properties of a VTODO the todolist has fields for.
+type vtodo struct {
+	uid         string
+	summary     string
+	description string
+	categories  []string
+	project     string
+	hasProject  bool
+	due         int64
+	done        bool
+	completed   int64
+}
+
+// patch turns the VTODO into the changes to the task current, nil for a new
+// one. Fields the todolist doesn't have yet are only added when set.
+func (v vtodo) patch(current *TaskType) TaskPatch {
+	patch := TaskPatch{Title: &v.summary}
+	if current == nil || current.Notes != "" || v.description != "" {
+		patch.Notes = &v.description
+	}
+	if current == nil || len(current.Tags) > 0 || len(v.categories) > 0 {
+		tags := append([]string{}, v.categories...)
+		patch.Tags = &tags
+	}
+	if v.hasProject {
+		patch.Project = &v.project
+	}
+	due := ""
+	if v.due != 0 {
+		due = strconv.FormatInt(v.due, 10)
+	}
+	if current == nil || current.Due != v.due {
+		patch.Due = &due
+	}
+	if current == nil || current.Done != v.done {
+		patch.Done = &v.done
+	}
+	return patch
+}
+
+// parseVTodo reads the first VTODO of an iCalendar object, ignoring the
+// components nested in it like VALARM.
+func parseVTodo(data string) (vtodo, error) {
+	var v vtodo
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.NewReplacer("\n ", "", "\n\t", "").Replace(data)
+
+	found, inside, depth := false, false, 0
+	hasStatus := false
+	for _, line := range strings.Split(data, "\n") {
+		name, params, value := splitContentLine(line)
+		switch {
+		case !inside && name == "BEGIN" && strings.EqualFold(value, "VTODO") && !found:
+			found, inside = true, true
+		case !inside:
+		case name == "BEGIN":
+			depth++
+		case name == "END" && depth > 0:
+			depth--
+		case name == "END":
+			inside = false
+		case depth > 0:
+		case name == "UID":
+			v.uid = value
+		case name == "SUMMARY":
+			v.summary = unescapeText(value)
+		case name == "DESCRIPTION":
+			v.description = unescapeText(value)
+		case name == "CATEGORIES":
+			v.categories = append(v.categories, splitText(value)...)
+		case name == "X-AXISGTD-PROJECT":
+			v.project, v.hasProject = unescapeText(value), true
+		case name == "DUE":
+			due, err := parseICSTime(value, params)
+			if err != nil {
+				return v, err
+			}
+			v.due = due
+		case name == "STATUS":
+			v.done, hasStatus = strings.EqualFold(value, "COMPLETED"), true
+		case name == "COMPLETED":
+			completed, err := parseICSTime(value, params)
+			if err != nil {
+				return v, err
+			}
+			v.completed = completed
+		}
+	}
+	if !found {
+		return v, errNoVTodo
+	}
+	if !hasStatus && v.completed != 0 {
+		v.done = true
+	}
+	return v, nil
+}
+
+// splitContentLine splits "NAME;PARAM=x:value" into its upper-cased name,
+// its parameters and the value, a colon in a quoted parameter doesn't end
+// the name.
+func splitContentLine(line string) (string, map[string]string, string) {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ':' && !quoted:
+			parts := strings.Split(line[:i], ";")
+			params := map[string]string{}
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(param, "=")
+				params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+			}
+			return strings.ToUpper(parts[0]), params, line[i+1:]
+		}
+	}
+	return strings.ToUpper(line), nil, ""
+}
+
+// parseICSTime reads a DATE or DATE-TIME value as Unix milliseconds. Dates
+// are midnight UTC like the all-day due dates of the feed, times without a
+// zone or with a TZID the server doesn't know are taken as UTC.
+func parseICSTime(value string, params map[string]string) (int64, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid date %q", value)
+		}
+		return t.UnixMilli(), nil
+	}
+	location := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if loc, err := time.LoadLocation(tzid); err == nil {
+			location = loc
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", strings.TrimSuffix(value, "Z"), location)
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid date-time %q", value)
+	}
+	return t.UnixMilli(), nil
+}
+
+func unescapeText(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped && (r == 'n' || r == 'N'):
+			b.WriteRune('\n')
+		case escaped:
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			continue
+		default:
+			b.WriteRune(r)
+		}
+		escaped = false
+	}
+	return b.String()
+}
+
+// splitText splits a list of TEXT values on the commas that aren't escaped.
+func splitText(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	values = append(values, unescapeText(s[start:]))
+	return values
+}
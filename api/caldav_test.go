@@ -0,0 +1,110 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVTodo(t *testing.T) {
+	ics := func(lines ...string) string {
+		return strings.Join(append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...), "END:VCALENDAR"), "\r\n")
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want vtodo
+		err  string
+	}{
+		{
+			name: "properties",
+			data: ics("BEGIN:VTODO", "UID:abc@client", `SUMMARY:Call the bank\, then pay`, `DESCRIPTION:one\ntwo`,
+				`CATEGORIES:phone,a\,b`, "CATEGORIES:work", "X-AXISGTD-PROJECT:finance", "DUE:20240902T150000Z", "END:VTODO"),
+			want: vtodo{uid: "abc@client", summary: "Call the bank, then pay", description: "one\ntwo",
+				categories: []string{"phone", "a,b", "work"}, project: "finance", hasProject: true, due: 1725289200000},
+		},
+		{
+			name: "folded lines",
+			data: ics("BEGIN:VTODO", "SUMMARY:Call", "  the bank", "END:VTODO"),
+			want: vtodo{summary: "Call the bank"},
+		},
+		{
+			name: "all-day due",
+			data: ics("BEGIN:VTODO", "DUE;VALUE=DATE:20240902", "END:VTODO"),
+			want: vtodo{due: 1725235200000},
+		},
+		{
+			name: "due with a zone",
+			data: ics("BEGIN:VTODO", `DUE;TZID="Europe/Berlin":20240902T170000`, "END:VTODO"),
+			want: vtodo{due: 1725289200000},
+		},
+		{
+			name: "floating due",
+			data: ics("BEGIN:VTODO", "DUE:20240902T150000", "END:VTODO"),
+			want: vtodo{due: 1725289200000},
+		},
+		{
+			name: "completed without a status",
+			data: ics("BEGIN:VTODO", "COMPLETED:20240831T102640Z", "END:VTODO"),
+			want: vtodo{done: true, completed: 1725100000000},
+		},
+		{
+			name: "status wins over completed",
+			data: ics("BEGIN:VTODO", "STATUS:NEEDS-ACTION", "COMPLETED:20240831T102640Z", "END:VTODO"),
+			want: vtodo{completed: 1725100000000},
+		},
+		{
+			name: "alarm is skipped",
+			data: ics("BEGIN:VTODO", "SUMMARY:a", "BEGIN:VALARM", "DESCRIPTION:alarm", "END:VALARM", "STATUS:completed", "END:VTODO"),
+			want: vtodo{summary: "a", done: true},
+		},
+		{
+			name: "only the first",
+			data: ics("BEGIN:VTODO", "SUMMARY:a", "END:VTODO", "BEGIN:VTODO", "SUMMARY:b", "END:VTODO"),
+			want: vtodo{summary: "a"},
+		},
+		{name: "no to-do", data: ics("BEGIN:VEVENT", "SUMMARY:a", "END:VEVENT"), err: errNoVTodo.Error()},
+		{name: "invalid due", data: ics("BEGIN:VTODO", "DUE:next-week", "END:VTODO"), err: `invalid date-time "next-week"`},
+		{name: "invalid date", data: ics("BEGIN:VTODO", "DUE;VALUE=DATE:2024-9-2", "END:VTODO"), err: `invalid date "2024-9-2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVTodo(tt.data)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("parseVTodo = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseVTodo = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVTodo = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A to-do the feed writes must read back as the same task.
+func TestParseVTodoRoundTrip(t *testing.T) {
+	tasks := []TaskType{
+		{ID: "1", Title: "Call the bank; then pay", Notes: "a\\b\nc", Tags: []string{"phone", "a,b"}, Project: "finance",
+			Due: 1725289200000, Done: true, CompletedAt: 1725100000000},
+		{ID: "2", Title: strings.Repeat("long title ", 20), Due: 1725235200000, Tags: []string{}},
+	}
+	for _, task := range tasks {
+		got, err := parseVTodo(writeCalendar("abc", "VTODO", 1725000000000, []TaskType{task}))
+		if err != nil {
+			t.Fatalf("parseVTodo = %v", err)
+		}
+		if got.summary != task.Title || got.description != task.Notes || got.project != task.Project ||
+			got.due != task.Due || got.done != task.Done || got.completed != task.CompletedAt {
+			t.Errorf("parseVTodo = %+v, want %+v", got, task)
+		}
+		if len(got.categories) != len(task.Tags) || (len(task.Tags) > 0 && !reflect.DeepEqual(got.categories, task.Tags)) {
+			t.Errorf("categories = %q, want %q", got.categories, task.Tags)
+		}
+	}
+}
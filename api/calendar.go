@@ -0,0 +1,274 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary		Create a read token
+// @Description	Creates or replaces the read token of a UID, which the calendar feed requires. Replacing it stops the old feed URLs.
+// @Tags			calendar
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Success		200		{object}	ReadTokenType
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/read-token [post]
+func CreateReadToken(c *fiber.Ctx) error {
+	token, err := GenerateRandomHex(32)
+	if err != nil {
+		return err
+	}
+	err = SetReadToken(c.Params("name"), token)
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Create read token Failed")
+	}
+	return c.JSON(ReadTokenType{
+		Token:    token,
+		Calendar: fmt.Sprintf("%s/feeds/%s.ics", c.BaseURL(), token),
+	})
+}
+
+// @Summary		Revoke the read token
+// @Description	Removes the read token of a UID, which disables its calendar feed.
+// @Tags			calendar
+// @Param			name	path		string	true	"UID Name"
+// @Success		204		"Revoked"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/read-token [delete]
+func DeleteReadToken(c *fiber.Ctx) error {
+	err := SetReadToken(c.Params("name"), "")
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Revoke read token Failed")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// tokenOf is lookup for the routes that have the UID name in the path as
+// well: the token of another UID is as unknown as a revoked one.
+func tokenOf(name string, lookup func(token string) (string, error)) func(token string) (string, error) {
+	return func(token string) (string, error) {
+		owner, err := lookup(token)
+		if err == nil && owner != name {
+			return "", sql.ErrNoRows
+		}
+		return owner, err
+	}
+}
+
+// tokenTasks loads the parsed todolist of the newest snapshot of the UID a
+// token belongs to. Whoever holds the token must not learn the UID name,
+// which grants write access, so none of the errors mention it.
+func tokenTasks(token string, lookup func(token string) (string, error)) (headSnapshot, *taskList, error) {
+	var head headSnapshot
+	name := ""
+	err := sql.ErrNoRows
+	if token != "" {
+		name, err = lookup(token)
+	}
+	if err == nil {
+		head, err = LatestSnapshot(name)
+	}
+	if err == sql.ErrNoRows {
+		return head, nil, notFound("invalid_token", "Unknown or revoked token")
+	}
+	if err != nil {
+		return head, nil, storeError(err, "Get tasks Failed")
+	}
+	if !head.Status {
+		return head, nil, newError(fiber.StatusForbidden, "uid_disabled", "The tasks are disabled")
+	}
+	if !head.HasSnapshot {
+		return head, nil, notFound("no_records", "No records available")
+	}
+	list, err := parseTaskList(head.Todolist)
+	if err != nil {
+		return head, nil, newError(fiber.StatusUnprocessableEntity, "unsupported_todolist", "%v", err)
+	}
+	return head, list, nil
+}
+
+// @Summary		Calendar feed
+// @Description	Serves the tasks with a due date as an iCalendar feed for calendar apps to subscribe to. The URL only holds the read token, so it can be shared without the UID name. Tasks are events by default, type=vtodo makes them to-dos.
+// @Tags			calendar
+// @Produce		text/calendar
+// @Param			token			path		string	true	"Read token"
+// @Param			type			query		string	false	"vevent (default) or vtodo"
+// @Param			include_done	query		bool	false	"Include completed tasks"
+// @Success		200				{string}	string		"iCalendar feed"
+// @Failure		403				{object}	ErrorType	"UID is disabled"
+// @Failure		404				{object}	ErrorType	"Unknown read token or no records available"
+// @Failure		422				{object}	ErrorType	"The todolist format is not understood"
+// @Router			/feeds/{token}.ics [get]
+func Calendar(c *fiber.Ctx) error {
+	return calendarFeed(c, c.Params("token"), UIDByReadToken)
+}
+
+// @Summary		Calendar feed of a UID
+// @Description	The calendar feed under the UID name, for apps that were set up with it. The read token is still required, without it or with another UID's token the answer is the same 404 as for an unknown token.
+// @Tags			calendar
+// @Produce		text/calendar
+// @Param			name			path		string	true	"UID Name"
+// @Param			token			query		string	true	"Read token"
+// @Param			type			query		string	false	"vevent (default) or vtodo"
+// @Param			include_done	query		bool	false	"Include completed tasks"
+// @Success		200				{string}	string		"iCalendar feed"
+// @Failure		403				{object}	ErrorType	"UID is disabled"
+// @Failure		404				{object}	ErrorType	"Unknown read token or no records available"
+// @Failure		422				{object}	ErrorType	"The todolist format is not understood"
+// @Router			/id/{name}/calendar.ics [get]
+func UIDCalendar(c *fiber.Ctx) error {
+	return calendarFeed(c, c.Query("token"), tokenOf(c.Params("name"), UIDByReadToken))
+}
+
+func calendarFeed(c *fiber.Ctx, token string, lookup func(token string) (string, error)) error {
+	component := strings.ToUpper(c.Query("type", "vevent"))
+	if component != "VEVENT" && component != "VTODO" {
+		return badRequest("invalid_type", "type must be vevent or vtodo, got %q", c.Query("type"))
+	}
+	head, list, err := tokenTasks(token, lookup)
+	if err != nil {
+		return err
+	}
+
+	current := head.revision()
+	setRevisionHeaders(c, current)
+	if notModified(c, current) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	var tasks []TaskType
+	for i := range list.items {
+		task := list.task(i)
+		if task.Due != 0 && task.ID != "" && (!task.Done || c.QueryBool("include_done")) {
+			tasks = append(tasks, task)
+		}
+	}
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set("X-Robots-Tag", "noindex")
+	return c.SendString(writeCalendar(head.Name, component, head.Time, tasks))
+}
+
+// writeCalendar renders tasks as an RFC 5545 calendar. The UIDs of the
+// entries are derived from the task ids and a hash of the UID name, so they
+// stay the same across snapshots without revealing the name, unless a CalDAV
+// client created the task with a UID of its own.
+func writeCalendar(uidName string, component string, stamp int64, tasks []TaskType) string {
+	sum := sha256.Sum256([]byte(uidName))
+	domain := hex.EncodeToString(sum[:6]) + ".axisgtdsync"
+
+	var b strings.Builder
+	line := func(name string, value string) {
+		writeContentLine(&b, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//AxisGTDSync//Tasks "+Version+"//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("X-WR-CALNAME", "AxisGTD")
+	line("REFRESH-INTERVAL;VALUE=DURATION", "PT15M")
+
+	for _, task := range tasks {
+		line("BEGIN", component)
+		if uid, ok := task.Raw[icalUIDKey].(string); ok && uid != "" {
+			line("UID", escapeText(uid))
+		} else {
+			line("UID", escapeText(task.ID)+"@"+domain)
+		}
+		line("DTSTAMP", icsTime(stamp))
+		line("SUMMARY", escapeText(task.Title))
+		if task.Notes != "" {
+			line("DESCRIPTION", escapeText(task.Notes))
+		}
+		if len(task.Tags) > 0 {
+			tags := make([]string, len(task.Tags))
+			for i, tag := range task.Tags {
+				tags[i] = escapeText(tag)
+			}
+			line("CATEGORIES", strings.Join(tags, ","))
+		}
+		if task.Project != "" {
+			line("X-AXISGTD-PROJECT", escapeText(task.Project))
+		}
+		if task.CreatedAt != 0 {
+			line("CREATED", icsTime(task.CreatedAt))
+		}
+
+		due := icsTime(task.Due)
+		allDay := task.Due%(24*60*60*1000) == 0
+		if allDay {
+			due = time.UnixMilli(task.Due).UTC().Format("20060102")
+		}
+		switch {
+		case task.Due == 0:
+		case component == "VTODO" && allDay:
+			line("DUE;VALUE=DATE", due)
+		case component == "VTODO":
+			line("DUE", due)
+		case allDay:
+			line("DTSTART;VALUE=DATE", due)
+			line("DTEND;VALUE=DATE", time.UnixMilli(task.Due).UTC().AddDate(0, 0, 1).Format("20060102"))
+		default:
+			line("DTSTART", due)
+			line("DURATION", "PT30M")
+		}
+
+		if component == "VTODO" {
+			if task.Done {
+				line("STATUS", "COMPLETED")
+				if task.CompletedAt != 0 {
+					line("COMPLETED", icsTime(task.CompletedAt))
+				}
+			} else {
+				line("STATUS", "NEEDS-ACTION")
+			}
+		} else {
+			line("TRANSP", "TRANSPARENT")
+		}
+		line("END", component)
+	}
+	line("END", "VCALENDAR")
+	return b.String()
+}
+
+func icsTime(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format("20060102T150405Z")
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escapeText(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// writeContentLine folds lines longer than 75 octets as RFC 5545 requires,
+// without splitting a UTF-8 sequence, and ends them with CRLF.
+func writeContentLine(b *strings.Builder, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestWriteCalendar(t *testing.T) {
+	const stamp = 1725000000000
+	task := TaskType{ID: "42", Title: "Call the bank", Due: 1725289200000, Tags: []string{}}
+
+	tests := []struct {
+		name      string
+		component string
+		task      TaskType
+		want      []string
+		not       []string
+	}{
+		{
+			name:      "event",
+			component: "VEVENT",
+			task:      task,
+			want: []string{"BEGIN:VEVENT", "DTSTAMP:20240830T064000Z", "SUMMARY:Call the bank",
+				"DTSTART:20240902T150000Z", "DURATION:PT30M", "TRANSP:TRANSPARENT"},
+			not: []string{"STATUS:", "DESCRIPTION:", "CATEGORIES:"},
+		},
+		{
+			name:      "all-day event",
+			component: "VEVENT",
+			task:      TaskType{ID: "1", Title: "a", Due: 1725235200000},
+			want:      []string{"DTSTART;VALUE=DATE:20240902", "DTEND;VALUE=DATE:20240903"},
+		},
+		{
+			name:      "to-do",
+			component: "VTODO",
+			task: TaskType{ID: "1", Title: "a", Due: 1725235200000, Done: true, CompletedAt: 1725100000000,
+				Notes: "line one\nline two", Tags: []string{"phone", "a,b"}, Project: "finance; home"},
+			want: []string{"BEGIN:VTODO", "DUE;VALUE=DATE:20240902", "STATUS:COMPLETED", "COMPLETED:20240831T102640Z",
+				`DESCRIPTION:line one\nline two`, `CATEGORIES:phone,a\,b`, `X-AXISGTD-PROJECT:finance\; home`},
+			not: []string{"TRANSP:"},
+		},
+		{
+			name:      "open to-do",
+			component: "VTODO",
+			task:      task,
+			want:      []string{"DUE:20240902T150000Z", "STATUS:NEEDS-ACTION"},
+		},
+		{
+			name:      "client uid",
+			component: "VTODO",
+			task:      TaskType{ID: "1", Title: "a", Raw: map[string]any{icalUIDKey: "abc@client"}},
+			want:      []string{"UID:abc@client"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := writeCalendar("secret-name", tt.component, stamp, []TaskType{tt.task})
+			if !strings.HasPrefix(got, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n") || !strings.HasSuffix(got, "END:VCALENDAR\r\n") {
+				t.Errorf("calendar is not wrapped in VCALENDAR:\n%s", got)
+			}
+			if strings.Contains(got, "secret-name") {
+				t.Errorf("calendar reveals the UID name:\n%s", got)
+			}
+			lines := strings.Split(got, "\r\n")
+			for _, want := range tt.want {
+				if !hasLine(lines, want) {
+					t.Errorf("missing %s in:\n%s", want, got)
+				}
+			}
+			for _, prefix := range tt.not {
+				for _, line := range lines {
+					if strings.HasPrefix(line, prefix) {
+						t.Errorf("unexpected %s", line)
+					}
+				}
+			}
+		})
+	}
+}
+
+func hasLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
+
+// The UIDs of the entries must not change between snapshots, or calendar
+// apps would see every task as new on each refresh.
+func TestWriteCalendarStableUID(t *testing.T) {
+	uid := func(name string, stamp int64) string {
+		for _, line := range strings.Split(writeCalendar(name, "VEVENT", stamp, []TaskType{{ID: "1", Title: "a", Due: 1}}), "\r\n") {
+			if strings.HasPrefix(line, "UID:") {
+				return line
+			}
+		}
+		return ""
+	}
+	if a, b := uid("abc", 1), uid("abc", 2); a == "" || a != b {
+		t.Errorf("UID changed from %q to %q", a, b)
+	}
+	if a, b := uid("abc", 1), uid("abd", 1); a == b {
+		t.Errorf("two UIDs share the entry UID %q", a)
+	}
+}
+
+func TestWriteContentLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"SUMMARY:short", "SUMMARY:short\r\n"},
+		{strings.Repeat("a", 75), strings.Repeat("a", 75) + "\r\n"},
+		{strings.Repeat("a", 76), strings.Repeat("a", 75) + "\r\n a\r\n"},
+		{strings.Repeat("a", 75+74+1), strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n a\r\n"},
+		// A two byte rune across the limit moves to the next line whole.
+		{strings.Repeat("a", 74) + "é", strings.Repeat("a", 74) + "\r\n é\r\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		writeContentLine(&b, tt.line)
+		if b.String() != tt.want {
+			t.Errorf("writeContentLine(%q) = %q, want %q", tt.line, b.String(), tt.want)
+		}
+	}
+}
+
+// Both are refused before the database is asked for the token.
+func TestUIDCalendar(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/id/:name/calendar.ics", UIDCalendar)
+
+	tests := []struct {
+		url    string
+		status int
+		code   string
+	}{
+		{"/id/abc/calendar.ics", 404, "invalid_token"},
+		{"/id/abc/calendar.ics?token=3f9c&type=vjournal", 400, "invalid_type"},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body ErrorType
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != tt.status || body.Code != tt.code {
+			t.Errorf("GET %s = %d %+v, want %d %s", tt.url, resp.StatusCode, body, tt.status, tt.code)
+		}
+	}
+}
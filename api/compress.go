@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gofiber/fiber/v2"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+var errBodyTooLarge = errors.New("decompressed body too large")
+
+// DecompressBody decodes a request body sent with Content-Encoding gzip,
+// deflate, br or zstd, or several of them in the order applied. The decoded
+// body is held to body_limit, so a small compressed upload can't expand
+// without bound.
+func DecompressBody(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderContentEncoding)
+	if header == "" {
+		return c.Next()
+	}
+
+	body := c.Request().Body()
+	encodings := strings.Split(header, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		if encoding == "identity" || encoding == "" {
+			continue
+		}
+		decoded, err := decompress(encoding, body, config.BodyLimit)
+		switch {
+		case errors.Is(err, errors.ErrUnsupported):
+			return newError(fiber.StatusUnsupportedMediaType, "unsupported_encoding",
+				"Content-Encoding %q is not supported, use gzip, deflate, br or zstd", encoding)
+		case errors.Is(err, errBodyTooLarge):
+			return newError(fiber.StatusRequestEntityTooLarge, "payload_too_large",
+				"the decompressed body is larger than %d bytes", config.BodyLimit)
+		case err != nil:
+			return &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body",
+				Message: "Body is not valid " + encoding, Err: err}
+		}
+		body = decoded
+	}
+
+	c.Request().SetBodyRaw(body)
+	c.Request().Header.Del(fiber.HeaderContentEncoding)
+	return c.Next()
+}
+
+func decompress(encoding string, body []byte, limit int) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case "deflate":
+		zr := flate.NewReader(bytes.NewReader(body))
+		defer zr.Close()
+		r = zr
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		// RFC 8878 caps the window of the zstd content coding at 8 MB.
+		zr, err := zstd.NewReader(bytes.NewReader(body), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(8<<20))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, errors.ErrUnsupported
+	}
+
+	decoded, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) > limit {
+		return nil, errBodyTooLarge
+	}
+	return decoded, nil
+}
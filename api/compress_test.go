@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gofiber/fiber/v2"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func compressed(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		var err error
+		if w, err = zstd.NewWriter(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	body := []byte(`{"todolist":"[]","config":"{}","time":1}`)
+	large := bytes.Repeat([]byte("a"), 1000)
+
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+		limit    int
+		want     []byte
+		err      error
+	}{
+		{"gzip", "gzip", compressed(t, "gzip", body), 100, body, nil},
+		{"x-gzip", "x-gzip", compressed(t, "gzip", body), 100, body, nil},
+		{"deflate", "deflate", compressed(t, "deflate", body), 100, body, nil},
+		{"brotli", "br", compressed(t, "br", body), 100, body, nil},
+		{"zstd", "zstd", compressed(t, "zstd", body), 100, body, nil},
+		{"at the limit", "gzip", compressed(t, "gzip", large), 1000, large, nil},
+		{"gzip bomb", "gzip", compressed(t, "gzip", large), 999, nil, errBodyTooLarge},
+		{"zstd bomb", "zstd", compressed(t, "zstd", large), 999, nil, errBodyTooLarge},
+		{"unsupported", "compress", body, 100, nil, errors.ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decompress(tt.encoding, tt.data, tt.limit)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("decompress = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decompress = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decompress = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := decompress("gzip", body, 100); err == nil {
+		t.Error("decompress of plain data as gzip succeeded")
+	}
+}
+
+func TestDecompressBody(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BodyLimit = 100
+	useConfig(t, cfg)
+	body := []byte(`{"time":1}`)
+	twice := compressed(t, "br", compressed(t, "gzip", body))
+
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+		status   int
+	}{
+		{"plain", "", body, 200},
+		{"identity", "identity", body, 200},
+		{"gzip", "gzip", compressed(t, "gzip", body), 200},
+		{"in the order applied", "gzip, br", twice, 200},
+		{"unsupported", "compress", body, 415},
+		{"too large", "gzip", compressed(t, "gzip", bytes.Repeat([]byte(" "), 101)), 413},
+		{"invalid", "gzip", body, 400},
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/", DecompressBody, func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderContentEncoding) != "" {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return c.Send(c.Body())
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.data))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, got)
+			}
+			if tt.status == 200 && !bytes.Equal(got, body) {
+				t.Errorf("body = %s, want %s", got, body)
+			}
+		})
+	}
+}
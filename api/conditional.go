@@ -0,0 +1,62 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// revision identifies the content of a snapshot. Hash is the hex MD5 of the
+// todolist followed by the config.
+type revision struct {
+	Time int64
+	Hash string
+}
+
+func snapshotRevision(todolist string, cfg string, t int64) revision {
+	sum := md5.Sum([]byte(todolist + cfg))
+	return revision{Time: t, Hash: hex.EncodeToString(sum[:])}
+}
+
+// ETag is strong: the time alone is not enough because an import in
+// overwrite mode replaces a snapshot and keeps its time.
+func (r revision) ETag() string {
+	return `"` + strconv.FormatInt(r.Time, 10) + "-" + r.Hash + `"`
+}
+
+func (r revision) lastModified() time.Time {
+	return time.UnixMilli(r.Time).UTC().Truncate(time.Second)
+}
+
+// setRevisionHeaders sets ETag and Last-Modified, and asks clients to
+// revalidate on every poll instead of trusting a cached copy.
+func setRevisionHeaders(c *fiber.Ctx, r revision) {
+	c.Set(fiber.HeaderETag, r.ETag())
+	c.Set(fiber.HeaderLastModified, r.lastModified().Format(http.TimeFormat))
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is
+// no If-None-Match, against the current revision.
+func notModified(c *fiber.Ctx, r revision) bool {
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" {
+		etag := r.ETag()
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+	return !r.lastModified().After(since)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSnapshotRevision(t *testing.T) {
+	r := snapshotRevision(`[]`, `{}`, 1725000000123)
+	if want := `"1725000000123-2a8a43b4f13c0cbe9693f8a7d8b98a9b"`; r.ETag() != want {
+		t.Errorf("ETag = %s, want %s", r.ETag(), want)
+	}
+	// The config counts as well, a change to it alone is a new revision.
+	if other := snapshotRevision(`[]`, `{"a":1}`, 1725000000123); other.ETag() == r.ETag() {
+		t.Errorf("ETag %s doesn't change with the config", r.ETag())
+	}
+	if got := r.lastModified(); !got.Equal(time.Unix(1725000000, 0)) {
+		t.Errorf("lastModified = %s, want the time truncated to seconds", got)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	r := snapshotRevision(`[]`, `{}`, 1725000000123)
+	modified := time.Unix(1725000000, 0).UTC()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"no conditions", nil, false},
+		{"matching etag", map[string]string{"If-None-Match": r.ETag()}, true},
+		{"weak etag", map[string]string{"If-None-Match": "W/" + r.ETag()}, true},
+		{"etag in a list", map[string]string{"If-None-Match": `"1-abc", ` + r.ETag()}, true},
+		{"any etag", map[string]string{"If-None-Match": "*"}, true},
+		{"other etag", map[string]string{"If-None-Match": `"1725000000123-abc"`}, false},
+		{"same second", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, true},
+		{"later", map[string]string{"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)}, true},
+		{"earlier", map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)}, false},
+		{"invalid date", map[string]string{"If-Modified-Since": "yesterday"}, false},
+		// If-None-Match takes precedence over If-Modified-Since.
+		{"both", map[string]string{
+			"If-None-Match":     `"1-abc"`,
+			"If-Modified-Since": modified.Format(http.TimeFormat),
+		}, false},
+	}
+
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		setRevisionHeaders(c, r)
+		if notModified(c, r) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		return c.SendString("[]")
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for header, value := range tt.headers {
+				req.Header.Set(header, value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.StatusCode == fiber.StatusNotModified; got != tt.want {
+				t.Errorf("status = %d, want not modified %t", resp.StatusCode, tt.want)
+			}
+			if resp.Header.Get("ETag") != r.ETag() || resp.Header.Get("Last-Modified") != modified.Format(http.TimeFormat) {
+				t.Errorf("ETag = %s, Last-Modified = %s", resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"))
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfig is the configuration before the config file, environment
+// variables and flags are applied, in that order.
+func DefaultConfig() ConfigType {
+	return ConfigType{
+		Listen:          ":8080",
+		BodyLimit:       4 * 1024 * 1024,
+		ShutdownTimeout: 30 * time.Second,
+		CORS: CORSConfig{
+			Origins: []string{"https://www.axisgtd.work", "http://localhost:3000", "http://127.0.0.1:8080"},
+		},
+		DB: DBConfig{
+			MaxOpenConns:    10,
+			MaxIdleConns:    2,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Paths: PathsConfig{
+			Views:   "./views",
+			Public:  "./public",
+			Swagger: "./docs/swagger.json",
+		},
+		Features: FeaturesConfig{
+			ManagePage:  true,
+			Swagger:     true,
+			Export:      true,
+			Import:      true,
+			Compression: true,
+			Webhooks:    true,
+		},
+		Limits: LimitsConfig{
+			MaxTodolistBytes: 3 * 1024 * 1024,
+			MaxConfigBytes:   512 * 1024,
+			MaxWait:          60 * time.Second,
+			MaxClockSkew:     24 * time.Hour,
+		},
+		Cache: CacheConfig{
+			Size:     10000,
+			MaxBytes: 256 * 1024 * 1024,
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:       10 * time.Second,
+			MaxAttempts:   8,
+			RetryDelay:    30 * time.Second,
+			MaxRetryDelay: 6 * time.Hour,
+			Interval:      5 * time.Second,
+			Retention:     30 * 24 * time.Hour,
+		},
+	}
+}
+
+// legacyEnv maps the environment variables of earlier releases to their
+// config keys. Every key can also be set as AXISGTD_<KEY>, e.g.
+// AXISGTD_DB_MAX_OPEN_CONNS for db.max_open_conns. corsURL isn't one of
+// them, see addLegacyOrigins.
+var legacyEnv = map[string]string{
+	"psqlURL":    "psql_url",
+	"adminToken": "admin_token",
+	"listenAddr": "listen",
+	"logLevel":   "log.level",
+}
+
+// LoadConfig builds the configuration from the defaults, the YAML file at
+// path (skipped when empty) and the environment. Flags are applied on top
+// with Set, then the result should be checked with Validate.
+func LoadConfig(path string) (ConfigType, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("error reading config file: %v", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+			return cfg, fmt.Errorf("error parsing config file %s: %v", path, err)
+		}
+	}
+
+	for env, key := range legacyEnv {
+		if value, ok := os.LookupEnv(env); ok && value != "" {
+			if err := cfg.Set(key, value); err != nil {
+				return cfg, fmt.Errorf("environment variable %s: %v", env, err)
+			}
+		}
+	}
+	cfg.addLegacyOrigins(os.Getenv("corsURL"))
+	for _, key := range ConfigKeys() {
+		env := "AXISGTD_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if value, ok := os.LookupEnv(env); ok {
+			if err := cfg.Set(key, value); err != nil {
+				return cfg, fmt.Errorf("environment variable %s: %v", env, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// addLegacyOrigins adds the comma separated origins of the corsURL variable
+// to cors.origins. Earlier releases always allowed the built-in origins and
+// corsURL only added to them, so unlike AXISGTD_CORS_ORIGINS it doesn't
+// replace the list.
+func (cfg *ConfigType) addLegacyOrigins(value string) {
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin != "" && !slices.Contains(cfg.CORS.Origins, origin) {
+			cfg.CORS.Origins = append(cfg.CORS.Origins, origin)
+		}
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (cfg ConfigType) Validate() error {
+	var errs []error
+	if cfg.Listen == "" {
+		errs = append(errs, errors.New("listen must not be empty"))
+	}
+	if cfg.PSQLURL == "" {
+		errs = append(errs, errors.New("psql_url is required, e.g. export psqlURL=\"user='youruser' password='yourpassword' dbname='yourdbname' sslmode='require'\""))
+	}
+	if cfg.BodyLimit <= 0 {
+		errs = append(errs, fmt.Errorf("body_limit must be positive, got %d", cfg.BodyLimit))
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout must be positive, got %s", cfg.ShutdownTimeout))
+	}
+	for _, origin := range cfg.CORS.Origins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, fmt.Errorf("cors.origins: %q is not an origin like https://example.com", origin))
+		}
+	}
+	if cfg.DB.MaxOpenConns < 0 || cfg.DB.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("db.max_open_conns and db.max_idle_conns must not be negative"))
+	}
+	if cfg.DB.ConnMaxLifetime < 0 || cfg.DB.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("db.conn_max_lifetime and db.conn_max_idle_time must not be negative"))
+	}
+	if cfg.Limits.MaxTodolistBytes < 0 || cfg.Limits.MaxConfigBytes < 0 || cfg.Limits.MaxUIDBytes < 0 || cfg.Limits.MaxUIDSnapshots < 0 || cfg.Limits.MaxWait < 0 || cfg.Limits.MaxClockSkew < 0 {
+		errs = append(errs, errors.New("limits must not be negative, use 0 to disable one (or long polling, for max_wait)"))
+	}
+	if cfg.Cache.Size < 0 {
+		errs = append(errs, fmt.Errorf("cache.size must not be negative, got %d", cfg.Cache.Size))
+	}
+	if cfg.Cache.MaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("cache.max_bytes must not be negative, got %d", cfg.Cache.MaxBytes))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", cfg.Log.Level))
+	}
+	switch strings.ToLower(cfg.Log.Format) {
+	case "json", "text", "logfmt":
+	default:
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", cfg.Log.Format))
+	}
+	if cfg.Metrics.PerUIDLabels && !cfg.Metrics.Auth {
+		errs = append(errs, errors.New("metrics.per_uid_labels exposes UID names, which grant access to their data, enable metrics.auth as well"))
+	}
+	if cfg.Metrics.Auth && cfg.AdminToken == "" {
+		errs = append(errs, errors.New("metrics.auth needs admin_token"))
+	}
+	if cfg.Webhooks.Timeout <= 0 || cfg.Webhooks.RetryDelay <= 0 || cfg.Webhooks.MaxRetryDelay <= 0 || cfg.Webhooks.Interval <= 0 {
+		errs = append(errs, errors.New("webhooks.timeout, webhooks.retry_delay, webhooks.max_retry_delay and webhooks.interval must be positive"))
+	}
+	if cfg.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.max_attempts must be at least 1, got %d", cfg.Webhooks.MaxAttempts))
+	}
+	if cfg.Webhooks.Retention < 0 {
+		errs = append(errs, errors.New("webhooks.retention must not be negative, use 0 to keep every delivery"))
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy with secrets masked, for printing.
+func (cfg ConfigType) Redacted() ConfigType {
+	if cfg.PSQLURL != "" {
+		cfg.PSQLURL = "********"
+	}
+	if cfg.AdminToken != "" {
+		cfg.AdminToken = "********"
+	}
+	return cfg
+}
+
+// ConfigKeys lists every key accepted by Set, e.g. "db.max_open_conns".
+func ConfigKeys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := prefix + field.Tag.Get("yaml")
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, key+".")
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	walk(reflect.TypeOf(ConfigType{}), "")
+	return keys
+}
+
+// Set changes the setting named by a dotted key from its string form. Lists
+// are comma separated and replace the previous value.
+func (cfg *ConfigType) Set(key, value string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Tag.Get("yaml") == part {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+	}
+
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", key, value)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", key, value)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigSet(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		got   func(cfg ConfigType) any
+		want  any
+		err   string
+	}{
+		{"listen", ":9090", func(cfg ConfigType) any { return cfg.Listen }, ":9090", ""},
+		{"body_limit", "1024", func(cfg ConfigType) any { return cfg.BodyLimit }, 1024, ""},
+		{"db.max_open_conns", "3", func(cfg ConfigType) any { return cfg.DB.MaxOpenConns }, 3, ""},
+		{"limits.max_wait", "90s", func(cfg ConfigType) any { return cfg.Limits.MaxWait }, 90 * time.Second, ""},
+		{"features.caldav", "true", func(cfg ConfigType) any { return cfg.Features.CalDAV }, true, ""},
+		{"cors.origins", " https://a.example , ,https://b.example", func(cfg ConfigType) any { return cfg.CORS.Origins },
+			[]string{"https://a.example", "https://b.example"}, ""},
+		{"cors.origins", "", func(cfg ConfigType) any { return cfg.CORS.Origins }, []string(nil), ""},
+		{"body_limit", "4MB", nil, nil, `body_limit: "4MB" is not a number`},
+		{"limits.max_wait", "60", nil, nil, `limits.max_wait: time: missing unit in duration "60"`},
+		{"features.caldav", "maybe", nil, nil, `features.caldav: "maybe" is not a boolean`},
+		{"db.nope", "1", nil, nil, `unknown config key "db.nope"`},
+		{"listen.port", "1", nil, nil, `unknown config key "listen.port"`},
+		{"db", "1", nil, nil, `unknown config key "db"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			cfg := DefaultConfig()
+			err := cfg.Set(tt.key, tt.value)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Set = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set = %v", err)
+			}
+			if got := tt.got(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %#v, want %#v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// Every key ConfigKeys lists must be accepted by Set, or it couldn't be
+// set from the environment.
+func TestConfigKeys(t *testing.T) {
+	for _, key := range ConfigKeys() {
+		cfg := DefaultConfig()
+		if err := cfg.Set(key, ""); err != nil && strings.Contains(err.Error(), "unknown config key") {
+			t.Errorf("Set(%q) = %v", key, err)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *ConfigType)
+		err    string
+	}{
+		{"defaults", func(cfg *ConfigType) {}, ""},
+		{"no database", func(cfg *ConfigType) { cfg.PSQLURL = "" }, "psql_url is required"},
+		{"no listen", func(cfg *ConfigType) { cfg.Listen = "" }, "listen must not be empty"},
+		{"body limit", func(cfg *ConfigType) { cfg.BodyLimit = 0 }, "body_limit must be positive"},
+		{"any origin", func(cfg *ConfigType) { cfg.CORS.Origins = []string{"*"} }, ""},
+		{"origin with path", func(cfg *ConfigType) { cfg.CORS.Origins = []string{"https://a.example/app"} }, "cors.origins"},
+		{"origin without scheme", func(cfg *ConfigType) { cfg.CORS.Origins = []string{"a.example"} }, "cors.origins"},
+		{"negative limit", func(cfg *ConfigType) { cfg.Limits.MaxUIDBytes = -1 }, "limits must not be negative"},
+		{"no long polling", func(cfg *ConfigType) { cfg.Limits.MaxWait = 0 }, ""},
+		{"cache size", func(cfg *ConfigType) { cfg.Cache.Size = -1 }, "cache.size must not be negative"},
+		{"cache bytes", func(cfg *ConfigType) { cfg.Cache.MaxBytes = -1 }, "cache.max_bytes must not be negative"},
+		{"log level", func(cfg *ConfigType) { cfg.Log.Level = "loud" }, "log.level"},
+		{"log format", func(cfg *ConfigType) { cfg.Log.Format = "xml" }, "log.format"},
+		{"per uid labels", func(cfg *ConfigType) { cfg.Metrics.PerUIDLabels = true }, "enable metrics.auth"},
+		{"metrics auth", func(cfg *ConfigType) { cfg.Metrics.Auth = true }, "metrics.auth needs admin_token"},
+		{"webhook attempts", func(cfg *ConfigType) { cfg.Webhooks.MaxAttempts = 0 }, "webhooks.max_attempts"},
+		{"webhook interval", func(cfg *ConfigType) { cfg.Webhooks.Interval = 0 }, "webhooks.interval must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.PSQLURL = "dbname=axisgtd"
+			tt.change(&cfg)
+			err := cfg.Validate()
+			if tt.err == "" && err != nil {
+				t.Errorf("Validate = %v, want nil", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Validate = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	defaults := DefaultConfig().CORS.Origins
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want func(cfg ConfigType) any
+		is   any
+		err  string
+	}{
+		{
+			name: "legacy variable",
+			env:  map[string]string{"psqlURL": "dbname=old"},
+			want: func(cfg ConfigType) any { return cfg.PSQLURL },
+			is:   "dbname=old",
+		},
+		{
+			name: "new variable wins",
+			env:  map[string]string{"listenAddr": ":1", "AXISGTD_LISTEN": ":2"},
+			want: func(cfg ConfigType) any { return cfg.Listen },
+			is:   ":2",
+		},
+		{
+			name: "corsURL adds to the defaults",
+			env:  map[string]string{"corsURL": "https://a.example, http://localhost:3000"},
+			want: func(cfg ConfigType) any { return cfg.CORS.Origins },
+			is:   append(append([]string{}, defaults...), "https://a.example"),
+		},
+		{
+			name: "AXISGTD_CORS_ORIGINS replaces them",
+			env:  map[string]string{"corsURL": "https://a.example", "AXISGTD_CORS_ORIGINS": "https://b.example"},
+			want: func(cfg ConfigType) any { return cfg.CORS.Origins },
+			is:   []string{"https://b.example"},
+		},
+		{
+			name: "file",
+			file: "limits:\n  max_wait: 5s\n",
+			env:  map[string]string{"AXISGTD_LIMITS_MAX_CLOCK_SKEW": "1h"},
+			want: func(cfg ConfigType) any { return []time.Duration{cfg.Limits.MaxWait, cfg.Limits.MaxClockSkew} },
+			is:   []time.Duration{5 * time.Second, time.Hour},
+		},
+		{name: "unknown file key", file: "limit:\n  max_wait: 5s\n", err: "field limit not found"},
+		{name: "invalid variable", env: map[string]string{"AXISGTD_BODY_LIMIT": "big"}, err: "environment variable AXISGTD_BODY_LIMIT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg, err := LoadConfig(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadConfig = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig = %v", err)
+			}
+			if got := tt.want(cfg); !reflect.DeepEqual(got, tt.is) {
+				t.Errorf("got %#v, want %#v", got, tt.is)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+)
+
+// ErrNotFound is wrapped by storage functions when the UID or snapshot they
+// were asked for does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrHeadChanged is returned by AppendSnapshot when another snapshot was
+// stored since the one a change was based on.
+var ErrHeadChanged = errors.New("newest snapshot changed")
+
+// APIError is returned by handlers and turned into an ErrorType response by
+// ErrorHandler. Err is the underlying cause, it is logged but never sent.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// statusCodes are the codes used for errors that carry only a status, like
+// the ones raised by Fiber itself.
+var statusCodes = map[int]string{
+	fiber.StatusBadRequest:            "bad_request",
+	fiber.StatusUnauthorized:          "unauthorized",
+	fiber.StatusForbidden:             "forbidden",
+	fiber.StatusNotFound:              "not_found",
+	fiber.StatusMethodNotAllowed:      "method_not_allowed",
+	fiber.StatusConflict:              "conflict",
+	fiber.StatusRequestEntityTooLarge: "payload_too_large",
+	fiber.StatusUnsupportedMediaType:  "unsupported_media_type",
+	fiber.StatusUnprocessableEntity:   "unprocessable_entity",
+	fiber.StatusTooManyRequests:       "too_many_requests",
+	fiber.StatusInternalServerError:   "internal_error",
+	fiber.StatusServiceUnavailable:    "service_unavailable",
+}
+
+func newError(status int, code string, format string, args ...any) *APIError {
+	return &APIError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func badRequest(code string, format string, args ...any) *APIError {
+	return newError(fiber.StatusBadRequest, code, format, args...)
+}
+
+func notFound(code string, format string, args ...any) *APIError {
+	return newError(fiber.StatusNotFound, code, format, args...)
+}
+
+func uidNotFound(name string) *APIError {
+	return notFound("uid_not_found", "%s not found", name)
+}
+
+func uidDisabled(name string) *APIError {
+	return newError(fiber.StatusForbidden, "uid_disabled", "%s is disabled", name)
+}
+
+// storeError classifies an error returned by the database: missing rows
+// become 404, unique violations 409, an unreachable database 503 and
+// everything else 500. msg describes what failed.
+func storeError(err error, msg string) *APIError {
+	var apiErr *APIError
+	var pqErr *pq.Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr):
+		// Raised by the store itself, like a quota check in a transaction.
+		return apiErr
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, ErrNotFound):
+		return &APIError{Status: fiber.StatusNotFound, Code: "not_found", Message: msg + ": not found", Err: err}
+	case errors.Is(err, ErrHeadChanged):
+		return &APIError{Status: fiber.StatusConflict, Code: "head_changed", Message: msg + ": a client synced meanwhile, retry", Err: err}
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return &APIError{Status: fiber.StatusConflict, Code: "conflict", Message: msg + ": already exists", Err: err}
+	case errors.As(err, &pqErr) && (pqErr.Code.Class() == "08" || pqErr.Code.Class() == "57"),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr):
+		return &APIError{Status: fiber.StatusServiceUnavailable, Code: "database_unavailable", Message: msg + ": database unavailable", Err: err}
+	}
+	return &APIError{Status: fiber.StatusInternalServerError, Code: "internal_error", Message: msg, Err: err}
+}
+
+// ErrorHandler is the Fiber ErrorHandler writing every error as ErrorType
+// and logging its cause.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var apiErr *APIError
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &fiberErr):
+		apiErr = &APIError{Status: fiberErr.Code, Code: statusCodes[fiberErr.Code], Message: fiberErr.Message}
+		if apiErr.Code == "" {
+			apiErr.Code = "error"
+		}
+	default:
+		apiErr = &APIError{Status: fiber.StatusInternalServerError, Code: "internal_error", Message: "Internal server error", Err: err}
+	}
+
+	// The cause of a client error is worth seeing too, a 404 or 409 from the
+	// database may well be a bug.
+	if apiErr.Err != nil {
+		if apiErr.Status >= fiber.StatusInternalServerError {
+			logError(c, apiErr.Message, apiErr.Err)
+		} else {
+			requestLog(c).Info(apiErr.Message, "code", apiErr.Code, "error", apiErr.Err, "method", c.Method(), "route", c.Route().Path)
+		}
+	}
+
+	requestID, _ := c.Locals("requestid").(string)
+	return c.Status(apiErr.Status).JSON(ErrorType{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		RequestID: requestID,
+	})
+}
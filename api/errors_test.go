@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+)
+
+func TestStoreError(t *testing.T) {
+	quota := newError(fiber.StatusForbidden, "quota_exceeded", "quota exceeded")
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"no rows", sql.ErrNoRows, 404, "not_found"},
+		{"wrapped not found", fmt.Errorf("no UID: %w", ErrNotFound), 404, "not_found"},
+		{"head changed", ErrHeadChanged, 409, "head_changed"},
+		{"unique violation", &pq.Error{Code: "23505"}, 409, "conflict"},
+		{"connection exception", &pq.Error{Code: "08006"}, 503, "database_unavailable"},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, 503, "database_unavailable"},
+		{"bad connection", driver.ErrBadConn, 503, "database_unavailable"},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), 503, "database_unavailable"},
+		{"syntax error", &pq.Error{Code: "42601"}, 500, "internal_error"},
+		{"other", errors.New("boom"), 500, "internal_error"},
+		{"api error", fmt.Errorf("tx: %w", quota), 403, "quota_exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := storeError(tt.err, "Get Failed")
+			if got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("storeError = %d %s, want %d %s", got.Status, got.Code, tt.status, tt.code)
+			}
+			if got.Code != "quota_exceeded" && !errors.Is(got, tt.err) {
+				t.Errorf("storeError does not wrap %v", tt.err)
+			}
+			if !strings.HasPrefix(got.Message, "Get Failed") && got != quota {
+				t.Errorf("message = %q", got.Message)
+			}
+		})
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := &APIError{Status: 503, Code: "database_unavailable", Message: "Get Failed", Err: cause}
+	if got := err.Error(); got != "Get Failed: connection refused" {
+		t.Errorf("Error = %q", got)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is does not find the cause")
+	}
+	if got := uidNotFound("a").Error(); got != "a not found" {
+		t.Errorf("Error without a cause = %q", got)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(RequestID)
+	app.Get("/api", func(c *fiber.Ctx) error {
+		return uidDisabled("a")
+	})
+	app.Get("/fiber", func(c *fiber.Ctx) error {
+		return fiber.ErrTooManyRequests
+	})
+	app.Get("/teapot", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusTeapot, "short and stout")
+	})
+	app.Get("/plain", func(c *fiber.Ctx) error {
+		return errors.New("password=hunter2")
+	})
+
+	tests := []struct {
+		url     string
+		status  int
+		code    string
+		message string
+	}{
+		{"/api", 403, "uid_disabled", "a is disabled"},
+		{"/fiber", 429, "too_many_requests", "Too Many Requests"},
+		{"/teapot", 418, "error", "short and stout"},
+		{"/plain", 500, "internal_error", "Internal server error"},
+		{"/missing", 404, "not_found", "Cannot GET /missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			buf := captureLog(t)
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("X-Request-ID", "req-1")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			var body ErrorType
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			want := ErrorType{Code: tt.code, Message: tt.message, RequestID: "req-1"}
+			if body != want {
+				t.Errorf("body = %+v, want %+v", body, want)
+			}
+			if tt.status == 500 && !strings.Contains(buf.String(), "hunter2") {
+				t.Errorf("cause not logged:\n%s", buf)
+			}
+		})
+	}
+}
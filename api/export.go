@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+)
+
+// ExportFormat and ExportVersion identify the archive written by WriteExport.
+// The archive is NDJSON: a header line, then for every UID a "uid" line
+// followed by its "snapshot" lines in time order, and a closing "end" line.
+// An archive without the "end" line was truncated.
+const (
+	ExportFormat  = "axisgtdsync-export"
+	ExportVersion = 1
+)
+
+// @Summary		Export a UID
+// @Description	Streams the UID metadata and every snapshot as an NDJSON archive.
+// @Tags			export
+// @Produce		application/x-ndjson
+// @Param			name	path		string	true	"UID Name"
+// @Success		200		{string}	string	"NDJSON archive"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/export [get]
+func ExportID(c *fiber.Ctx) error {
+	uid, err := GetUID(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Export ID Failed")
+	}
+	return streamExport(c, "axisgtd-"+uid.Name, []string{uid.Name})
+}
+
+// @Summary		Export every UID
+// @Description	Streams all UIDs and their snapshots as a single NDJSON archive.
+// @Tags			export
+// @Produce		application/x-ndjson
+// @Security		APIKeyAuth
+// @Success		200	{string}	string	"NDJSON archive"
+// @Failure		401	{object}	ErrorType	"Unauthorized"
+// @Failure		500	{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/export [get]
+func ExportAll(c *fiber.Ctx) error {
+	return streamExport(c, "axisgtd-server", nil)
+}
+
+func streamExport(c *fiber.Ctx, filename string, names []string) error {
+	// The transaction is started before the response, so a database that is
+	// down still fails the request rather than an empty archive.
+	tx, err := beginExport()
+	if err != nil {
+		return storeError(err, "Export Failed")
+	}
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s-%d.ndjson"`, filename, time.Now().Unix()))
+	logger := requestLog(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer tx.Rollback()
+		if err := writeExport(w, tx, names); err != nil {
+			logger.Error("export stream failed", "error", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// WriteExport writes the archive of the named UIDs to w, or of every UID
+// when names is empty. Everything is read in one read-only transaction, so
+// the archive is a consistent copy even while clients sync, and snapshots
+// are read one row at a time so large histories are never held in memory.
+func WriteExport(w io.Writer, names []string) error {
+	tx, err := beginExport()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return writeExport(w, tx, names)
+}
+
+func beginExport() (*sql.Tx, error) {
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	return tx, nil
+}
+
+func writeExport(w io.Writer, tx *sql.Tx, names []string) error {
+	uids, err := exportUIDs(tx, names)
+	if err != nil {
+		return err
+	}
+	archive, err := newArchiveWriter(w)
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		if err := archive.uid(uid); err != nil {
+			return err
+		}
+		if err := writeSnapshots(tx, archive, uid.Name); err != nil {
+			return fmt.Errorf("error exporting %s: %v", uid.Name, err)
+		}
+	}
+	return archive.close()
+}
+
+// exportUIDs reads the UIDs with their quota and tokens, which an import
+// restores along with the snapshots.
+func exportUIDs(tx *sql.Tx, names []string) ([]ExportUID, error) {
+	rows, err := tx.Query(`SELECT name, status, quota_bytes, quota_snapshots,
+		COALESCE(read_token, ''), COALESCE(share_token, ''), COALESCE(caldav_secret, '')
+		FROM UID WHERE cardinality($1::text[]) = 0 OR name = ANY($1) ORDER BY id`, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uids []ExportUID
+	for rows.Next() {
+		uid := ExportUID{Type: "uid"}
+		err := rows.Scan(&uid.Name, &uid.Status, &uid.Quota.MaxBytes, &uid.Quota.MaxSnapshots,
+			&uid.ReadToken, &uid.ShareToken, &uid.DAVSecret)
+		if err != nil {
+			return nil, err
+		}
+		uids = append(uids, uid)
+	}
+	return uids, rows.Err()
+}
+
+func writeSnapshots(tx *sql.Tx, archive *archiveWriter, uidName string) error {
+	query := `SELECT todolist, config, time, COALESCE(device, '') FROM axisgtd WHERE uid_name = $1 ORDER BY time ASC`
+	rows, err := tx.Query(query, uidName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		snapshot := ExportSnapshot{Type: "snapshot", Name: uidName}
+		err := rows.Scan(&snapshot.Todolist, &snapshot.Config, &snapshot.Time, &snapshot.Device)
+		if err != nil {
+			return err
+		}
+		if err := archive.snapshot(snapshot); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// archiveWriter writes the lines of an archive and counts them for the end
+// line.
+type archiveWriter struct {
+	enc    *json.Encoder
+	footer ExportFooter
+}
+
+func newArchiveWriter(w io.Writer) (*archiveWriter, error) {
+	archive := &archiveWriter{enc: json.NewEncoder(w), footer: ExportFooter{Type: "end"}}
+	err := archive.enc.Encode(ExportHeader{
+		Type:       "header",
+		Format:     ExportFormat,
+		Version:    ExportVersion,
+		ExportedAt: time.Now().UnixMilli(),
+	})
+	return archive, err
+}
+
+func (a *archiveWriter) uid(uid ExportUID) error {
+	a.footer.UIDs++
+	return a.enc.Encode(uid)
+}
+
+func (a *archiveWriter) snapshot(snapshot ExportSnapshot) error {
+	a.footer.Snapshots++
+	return a.enc.Encode(snapshot)
+}
+
+func (a *archiveWriter) close() error {
+	return a.enc.Encode(a.footer)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArchiveWriter(t *testing.T) {
+	uids := []ExportUID{
+		{Type: "uid", Name: "abc", Status: true, Quota: QuotaType{MaxBytes: 1000, MaxSnapshots: 10},
+			ReadToken: "read", ShareToken: "share", DAVSecret: "secret"},
+		{Type: "uid", Name: "def"},
+	}
+	snapshots := map[string][]ExportSnapshot{
+		"abc": {
+			{Type: "snapshot", Name: "abc", Todolist: "[]", Config: "{}", Time: 1, Device: "phone"},
+			{Type: "snapshot", Name: "abc", Todolist: "[1]", Config: "{}", Time: 2},
+		},
+	}
+
+	var buf bytes.Buffer
+	archive, err := newArchiveWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uid := range uids {
+		if err := archive.uid(uid); err != nil {
+			t.Fatal(err)
+		}
+		for _, snapshot := range snapshots[uid.Name] {
+			if err := archive.snapshot(snapshot); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := archive.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var footer ExportFooter
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &footer); err != nil {
+		t.Fatal(err)
+	}
+	if footer != (ExportFooter{Type: "end", UIDs: 2, Snapshots: 2}) {
+		t.Errorf("end line = %+v, want 2 UIDs and 2 snapshots", footer)
+	}
+
+	// What an export writes, an import reads back.
+	imported, err := parseImport(&buf)
+	if err != nil {
+		t.Fatalf("parseImport = %v", err)
+	}
+	if len(imported) != len(uids) {
+		t.Fatalf("parseImport = %d UIDs, want %d", len(imported), len(uids))
+	}
+	for i, uid := range imported {
+		if !reflect.DeepEqual(uid.ExportUID, uids[i]) {
+			t.Errorf("uid %d = %+v, want %+v", i, uid.ExportUID, uids[i])
+		}
+		if !reflect.DeepEqual(uid.Snapshots, snapshots[uid.Name]) {
+			t.Errorf("snapshots of %s = %+v, want %+v", uid.Name, uid.Snapshots, snapshots[uid.Name])
+		}
+	}
+}
+
+func TestParseArchiveUID(t *testing.T) {
+	const header = `{"type":"header","format":"axisgtdsync-export","version":1}` + "\n"
+	const end = "\n" + `{"type":"end","uids":1,"snapshots":0}`
+
+	tests := []struct {
+		name string
+		uid  string
+		want ExportUID
+		err  string
+	}{
+		// Archives of earlier releases have neither quota nor tokens.
+		{"earlier release", `{"type":"uid","name":"abc","status":true}`, ExportUID{Type: "uid", Name: "abc", Status: true}, ""},
+		{"quota", `{"type":"uid","name":"abc","quota":{"max_bytes":5,"max_snapshots":2}}`,
+			ExportUID{Type: "uid", Name: "abc", Quota: QuotaType{MaxBytes: 5, MaxSnapshots: 2}}, ""},
+		{"negative quota", `{"type":"uid","name":"abc","quota":{"max_bytes":-1}}`, ExportUID{}, "must not be negative"},
+		{"long token", `{"type":"uid","name":"abc","read_token":"` + strings.Repeat("a", 65) + `"}`, ExportUID{}, "at most 64 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uids, err := parseImport(strings.NewReader(header + tt.uid + end))
+			if tt.err != "" {
+				if !errors.Is(err, ErrInvalidImport) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseImport = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseImport = %v", err)
+			}
+			if len(uids) != 1 || uids[0].ExportUID != tt.want {
+				t.Errorf("parseImport = %+v, want %+v", uids, tt.want)
+			}
+		})
+	}
+}
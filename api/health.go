@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Version and Commit are set at build time with
+// -ldflags "-X AxisGTDSync/api.Version=... -X AxisGTDSync/api.Commit=...".
+// Commit falls back to the VCS revision recorded by the Go toolchain.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// @Summary		Liveness probe
+// @Description	Reports that the process is up, without touching the database.
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	ReadyType
+// @Router			/healthz [get]
+func Healthz(c *fiber.Ctx) error {
+	return c.JSON(ReadyType{Status: "ok", Checks: map[string]string{}})
+}
+
+// @Summary		Readiness probe
+// @Description	Pings the database and checks that every migration has been applied.
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	ReadyType
+// @Failure		503	{object}	ReadyType
+// @Router			/readyz [get]
+func Readyz(c *fiber.Ctx) error {
+	ready := ReadyType{Status: "ok", Checks: map[string]string{"database": "ok", "migrations": "ok"}}
+
+	// The probe is unauthenticated, so the errors, which may name the
+	// database host or user, are only logged.
+	ctx, cancel := context.WithTimeout(c.UserContext(), 2*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		ready.Status = "unavailable"
+		ready.Checks["database"] = "unreachable"
+		ready.Checks["migrations"] = "unknown"
+		requestLog(c).Warn("not ready", "error", err)
+		return c.Status(503).JSON(ready)
+	}
+
+	version, err := SchemaVersion()
+	if err != nil {
+		ready.Status = "unavailable"
+		ready.Checks["migrations"] = "unknown"
+		requestLog(c).Warn("not ready", "error", err)
+		return c.Status(503).JSON(ready)
+	}
+	if version != LatestSchemaVersion() {
+		ready.Status = "unavailable"
+		ready.Checks["migrations"] = fmt.Sprintf("schema version %d, expected %d", version, LatestSchemaVersion())
+		requestLog(c).Warn("not ready", "migrations", ready.Checks["migrations"])
+		return c.Status(503).JSON(ready)
+	}
+	return c.JSON(ready)
+}
+
+// @Summary		Build and schema version
+// @Description	Reports the build version, commit and database schema version.
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	VersionType
+// @Router			/version [get]
+func GetVersion(c *fiber.Ctx) error {
+	version := VersionType{
+		Version:             Version,
+		Commit:              buildCommit(),
+		GoVersion:           runtime.Version(),
+		SchemaVersion:       -1,
+		LatestSchemaVersion: LatestSchemaVersion(),
+	}
+	if current, err := SchemaVersion(); err == nil {
+		version.SchemaVersion = current
+	}
+	return c.JSON(version)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestHealthz(t *testing.T) {
+	app := fiber.New()
+	app.Get("/healthz", Healthz)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ready ReadyType
+	json.NewDecoder(resp.Body).Decode(&ready)
+	if resp.StatusCode != 200 || ready.Status != "ok" {
+		t.Errorf("GET /healthz = %d %+v, want 200 ok", resp.StatusCode, ready)
+	}
+}
+
+// The probe answers without credentials, so the reason a database is out of
+// reach goes to the log only.
+func TestReadyzUnreachable(t *testing.T) {
+	unreachable, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=secretuser dbname=axisgtd sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unreachable.Close()
+	old := db
+	db = unreachable
+	t.Cleanup(func() { db = old })
+	logs := captureLog(t)
+
+	app := fiber.New()
+	app.Get("/readyz", Readyz)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var ready ReadyType
+	if err := json.Unmarshal(body, &ready); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"database": "unreachable", "migrations": "unknown"}
+	if resp.StatusCode != 503 || ready.Status != "unavailable" || len(ready.Checks) != 2 ||
+		ready.Checks["database"] != want["database"] || ready.Checks["migrations"] != want["migrations"] {
+		t.Errorf("GET /readyz = %d %+v, want 503 with %v", resp.StatusCode, ready, want)
+	}
+	if strings.Contains(string(body), "127.0.0.1") || strings.Contains(string(body), "secretuser") {
+		t.Errorf("response holds the database error: %s", body)
+	}
+	if !strings.Contains(logs.String(), "not ready") || !strings.Contains(logs.String(), "127.0.0.1") {
+		t.Errorf("log = %s, want the database error", logs)
+	}
+}
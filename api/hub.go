@@ -0,0 +1,186 @@
+package api
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hub wakes the long-polling pulls waiting on a UID when a snapshot of it is
+// stored, so they never poll the database in a loop.
+var hub = newHub()
+
+type syncHub struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan struct{}]struct{}
+	count  int
+	done   chan struct{}
+	closed bool
+}
+
+func newHub() *syncHub {
+	return &syncHub{
+		subs: make(map[string]map[chan struct{}]struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// subscribe returns a channel that receives after every publish for name,
+// coalescing publishes the subscriber has not consumed yet, and the
+// function to unsubscribe.
+func (h *syncHub) subscribe(name string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[name] == nil {
+		h.subs[name] = make(map[chan struct{}]struct{})
+	}
+	h.subs[name][ch] = struct{}{}
+	h.count++
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[name][ch]; !ok {
+			return
+		}
+		delete(h.subs[name], ch)
+		if len(h.subs[name]) == 0 {
+			delete(h.subs, name)
+		}
+		h.count--
+	}
+}
+
+// publish wakes every subscriber of name.
+func (h *syncHub) publish(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[name] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// publishAll wakes every subscriber.
+func (h *syncHub) publishAll() {
+	h.mu.Lock()
+	names := make([]string, 0, len(h.subs))
+	for name := range h.subs {
+		names = append(names, name)
+	}
+	h.mu.Unlock()
+	for _, name := range names {
+		h.publish(name)
+	}
+}
+
+// closing is closed by CloseHub, waiters should give up when it is.
+func (h *syncHub) closing() <-chan struct{} {
+	return h.done
+}
+
+func (h *syncHub) subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// CloseHub releases every waiting pull, call it before shutting the server
+// down so they don't hold up the shutdown until their timeout.
+func CloseHub() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if !hub.closed {
+		hub.closed = true
+		close(hub.done)
+	}
+}
+
+// uidChanged is called after a snapshot of name is stored or deleted.
+// The triggers notify every instance as well, this only saves the round
+// trip here.
+func uidChanged(name string) {
+	if heads != nil {
+		heads.invalidate(name)
+	}
+	hub.publish(name)
+}
+
+// waitForChange implements ?wait= on the sync pull: it blocks until the head
+// snapshot differs from ?after= (or If-None-Match), or until the wait is
+// over. It reports false when it timed out and set the headers for a 304,
+// true when SyncGet should answer as usual, which also covers unknown and
+// disabled UIDs.
+func waitForChange(c *fiber.Ctx) (bool, error) {
+	wait, err := parseWait(c.Query("wait"))
+	if err != nil {
+		return false, badRequest("invalid_wait", "wait must be a duration like 30s, got %q", c.Query("wait"))
+	}
+	// Unlike the other limits a max_wait of 0 isn't unlimited, it caps
+	// every wait at 0 and so disables long polling.
+	if wait > config.Limits.MaxWait {
+		wait = config.Limits.MaxWait
+	}
+	after := c.Query("after", c.Get(fiber.HeaderIfNoneMatch))
+	if after == "" || wait <= 0 {
+		return true, nil
+	}
+
+	name := c.Params("name")
+	changed, cancel := hub.subscribe(name)
+	defer cancel()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		head, err := LatestSnapshot(name)
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		if err != nil {
+			return false, storeError(err, "Get sync data Failed")
+		}
+		if !head.Status || (head.HasSnapshot && newerThan(head.revision(), after)) {
+			return true, nil
+		}
+
+		select {
+		case <-changed:
+			continue
+		case <-timer.C:
+		case <-hub.closing():
+		}
+		if head.HasSnapshot {
+			setRevisionHeaders(c, head.revision())
+		}
+		return false, nil
+	}
+}
+
+// parseWait accepts a Go duration or a number of seconds.
+func parseWait(s string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(s); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// newerThan compares the head with the revision the client has, given as
+// its time in milliseconds or as its ETag.
+func newerThan(current revision, after string) bool {
+	if t, err := strconv.ParseInt(after, 10, 64); err == nil {
+		return current.Time > t
+	}
+	after = strings.TrimPrefix(strings.TrimSpace(after), "W/")
+	if !strings.HasPrefix(after, `"`) {
+		after = `"` + after + `"`
+	}
+	return current.ETag() != after
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// received reports whether ch has something to receive right away.
+func received(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHub(t *testing.T) {
+	h := newHub()
+	a, cancelA := h.subscribe("a")
+	b, cancelB := h.subscribe("b")
+	if got := h.subscribers(); got != 2 {
+		t.Fatalf("subscribers = %d, want 2", got)
+	}
+
+	h.publish("a")
+	h.publish("a")
+	if !received(a) {
+		t.Error("subscriber of a not woken")
+	}
+	if received(a) {
+		t.Error("publishes not coalesced")
+	}
+	if received(b) {
+		t.Error("subscriber of b woken by a")
+	}
+
+	h.publishAll()
+	if !received(a) || !received(b) {
+		t.Error("publishAll did not wake every subscriber")
+	}
+
+	cancelA()
+	cancelA()
+	if got := h.subscribers(); got != 1 {
+		t.Errorf("subscribers after cancel = %d, want 1", got)
+	}
+	h.publish("a")
+	if received(a) {
+		t.Error("canceled subscriber woken")
+	}
+	cancelB()
+	if len(h.subs) != 0 {
+		t.Errorf("subs = %v, want none", h.subs)
+	}
+}
+
+func TestParseWait(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"30", 30 * time.Second, false},
+		{"30s", 30 * time.Second, false},
+		{"1m30s", 90 * time.Second, false},
+		{"500ms", 500 * time.Millisecond, false},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseWait(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseWait(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewerThan(t *testing.T) {
+	current := snapshotRevision("[]", "{}", 2000)
+	tests := []struct {
+		after string
+		want  bool
+	}{
+		{"1000", true},
+		{"2000", false},
+		{"3000", false},
+		{current.ETag(), false},
+		{"W/" + current.ETag(), false},
+		{current.ETag()[1 : len(current.ETag())-1], false},
+		{`"other"`, true},
+	}
+	for _, tt := range tests {
+		if got := newerThan(current, tt.after); got != tt.want {
+			t.Errorf("newerThan(%q) = %v, want %v", tt.after, got, tt.want)
+		}
+	}
+}
+
+// cacheHead puts a snapshot of name into the head cache, so the waits below
+// never reach the database.
+func cacheHead(name string, t int64) headSnapshot {
+	head := headSnapshot{
+		AxisGTDJsonType: AxisGTDJsonType{Name: name, Status: true, Todolist: "[]", Config: "{}", Time: t},
+		HasSnapshot:     true,
+		rev:             snapshotRevision("[]", "{}", t),
+	}
+	heads.invalidate(name)
+	_, gen, _ := heads.get(name)
+	heads.put(name, head, gen)
+	return head
+}
+
+func TestWaitForChange(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Limits.MaxWait = 2 * time.Second
+	useConfig(t, cfg)
+	oldHub, oldHeads := hub, heads
+	t.Cleanup(func() { hub, heads = oldHub, oldHeads })
+	hub = newHub()
+	heads = newHeadCache(10, 0)
+	heads.reset(true)
+	head := cacheHead("a", 1000)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/:name", func(c *fiber.Ctx) error {
+		changed, err := waitForChange(c)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		return c.SendString("changed")
+	})
+	get := func(url string) (*http.Response, time.Duration) {
+		t.Helper()
+		start := time.Now()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, time.Since(start)
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"no after", "/a?wait=1", 200},
+		{"older after", "/a?wait=1&after=500", 200},
+		{"other etag", `/a?wait=1&after="other"`, 200},
+		{"timed out", "/a?wait=50ms&after=1000", 304},
+		{"invalid wait", "/a?wait=soon&after=1000", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := get(tt.url)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == 304 && resp.Header.Get(fiber.HeaderETag) != head.revision().ETag() {
+				t.Errorf("ETag = %q, want %q", resp.Header.Get(fiber.HeaderETag), head.revision().ETag())
+			}
+		})
+	}
+
+	t.Run("woken by a snapshot", func(t *testing.T) {
+		go func() {
+			for hub.subscribers() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			cacheHead("a", 2000)
+			hub.publish("a")
+		}()
+		resp, took := get("/a?wait=10s&after=1000")
+		if resp.StatusCode != 200 {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		if took >= cfg.Limits.MaxWait {
+			t.Errorf("took %v, not woken", took)
+		}
+	})
+
+	t.Run("capped by max_wait", func(t *testing.T) {
+		cfg.Limits.MaxWait = 50 * time.Millisecond
+		useConfig(t, cfg)
+		resp, took := get("/a?wait=10s&after=2000")
+		if resp.StatusCode != 304 {
+			t.Fatalf("status = %d, want 304", resp.StatusCode)
+		}
+		if took >= time.Second {
+			t.Errorf("took %v, want max_wait", took)
+		}
+	})
+
+	t.Run("released on close", func(t *testing.T) {
+		cfg.Limits.MaxWait = 10 * time.Second
+		useConfig(t, cfg)
+		go func() {
+			for hub.subscribers() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			CloseHub()
+		}()
+		resp, took := get("/a?wait=10s&after=2000")
+		if resp.StatusCode != 304 {
+			t.Fatalf("status = %d, want 304", resp.StatusCode)
+		}
+		if took >= time.Second {
+			t.Errorf("took %v, not released", took)
+		}
+	})
+}
@@ -0,0 +1,444 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Conflict modes for ImportArchive.
+const (
+	// ImportSkip keeps snapshots already stored with the same time.
+	ImportSkip = "skip"
+	// ImportOverwrite replaces snapshots already stored with the same time.
+	ImportOverwrite = "overwrite"
+	// ImportNew stores every imported UID under a freshly generated name.
+	ImportNew = "new"
+)
+
+// ErrInvalidImport is wrapped by every error caused by the payload itself
+// rather than by the database.
+var ErrInvalidImport = errors.New("invalid import")
+
+type ImportOptions struct {
+	Mode string
+	// Name is the UID to import into. When empty the names stored in the
+	// archive are used.
+	Name string
+	// Create allows UIDs that do not exist yet to be created.
+	Create bool
+}
+
+type importUID struct {
+	ExportUID
+	Snapshots []ExportSnapshot
+}
+
+// backupSnapshot is one entry of a plain AxisGTD client backup. The client
+// stores todolist and config as JSON documents, so both are accepted either
+// as a JSON string (as sent to /sync) or as the raw document.
+type backupSnapshot struct {
+	Todolist json.RawMessage `json:"todolist"`
+	Config   json.RawMessage `json:"config"`
+	Time     int64           `json:"time"`
+}
+
+// @Summary		Import into a UID
+// @Description	Imports an export archive or an AxisGTD client backup into an existing UID in one transaction.
+// @Tags			import
+// @Accept			json
+// @Produce		json
+// @Param			name	path		string			true	"UID Name"
+// @Param			mode	query		string			false	"Conflict mode: skip (default) or overwrite"
+// @Success		200		{object}	ImportResult
+// @Failure		400		{object}	ErrorType	"Invalid archive or backup"
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/import [post]
+func ImportID(c *fiber.Ctx) error {
+	mode := c.Query("mode", ImportSkip)
+	if mode != ImportSkip && mode != ImportOverwrite {
+		return badRequest("invalid_mode", "mode must be skip or overwrite")
+	}
+
+	uid, err := GetUID(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Import Failed")
+	}
+	if !uid.Status {
+		return uidDisabled(uid.Name)
+	}
+
+	return importResponse(c, ImportOptions{Mode: mode, Name: uid.Name})
+}
+
+// @Summary		Import archives and backups
+// @Description	Imports an export archive, creating or merging every UID it contains in one transaction. A client backup needs a target name or mode=new.
+// @Tags			import
+// @Accept			json
+// @Produce		json
+// @Security		APIKeyAuth
+// @Param			mode	query		string	false	"Conflict mode: skip (default), overwrite or new"
+// @Param			name	query		string	false	"Target UID, required for client backups unless mode=new"
+// @Success		200		{object}	ImportResult
+// @Failure		400		{object}	ErrorType	"Invalid archive or backup"
+// @Failure		401		{object}	ErrorType	"Unauthorized"
+// @Failure		403		{object}	ErrorType	"A UID of the archive is disabled"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/import [post]
+func ImportAll(c *fiber.Ctx) error {
+	mode := c.Query("mode", ImportSkip)
+	if mode != ImportSkip && mode != ImportOverwrite && mode != ImportNew {
+		return badRequest("invalid_mode", "mode must be skip, overwrite or new")
+	}
+	return importResponse(c, ImportOptions{Mode: mode, Name: c.Query("name"), Create: true})
+}
+
+func importResponse(c *fiber.Ctx, opts ImportOptions) error {
+	result, err := ImportArchive(bytes.NewReader(c.Body()), opts)
+	if errors.Is(err, ErrInvalidImport) {
+		return badRequest("invalid_import", "%s", err.Error())
+	}
+	if err != nil {
+		return storeError(err, "Import Failed")
+	}
+	for _, name := range result.UIDs {
+		uidChanged(name)
+	}
+	wakeWebhooks()
+	return c.JSON(result)
+}
+
+// ImportArchive reads an export archive or a client backup from r and stores
+// it in a single transaction, so a failed import leaves nothing behind.
+func ImportArchive(r io.Reader, opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+
+	uids, err := parseImport(r)
+	if err != nil {
+		return result, err
+	}
+
+	if err := importNames(uids, opts, GetName); err != nil {
+		return result, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, uid := range uids {
+		err := importUIDTx(tx, uid, opts, &result)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		result.UIDs = append(result.UIDs, uid.Name)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ImportResult{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return result, nil
+}
+
+// importNames sets the names the UIDs are stored under. GetName only knows
+// the stored UIDs, so the names mode=new generates are also kept apart from
+// each other.
+func importNames(uids []importUID, opts ImportOptions, newName func() (string, error)) error {
+	if opts.Name != "" {
+		if len(uids) != 1 {
+			return fmt.Errorf("%w: archive contains %d UIDs, expected one", ErrInvalidImport, len(uids))
+		}
+		uids[0].Name = opts.Name
+	}
+	chosen := map[string]bool{}
+	for i := range uids {
+		// The tokens stay with the UID they were made for, a copy under
+		// another name gets none.
+		if opts.Name != "" || opts.Mode == ImportNew {
+			uids[i].ReadToken, uids[i].ShareToken, uids[i].DAVSecret = "", "", ""
+		}
+		if opts.Mode == ImportNew {
+			uids[i].Name = ""
+			for uids[i].Name == "" || chosen[uids[i].Name] {
+				name, err := newName()
+				if err != nil {
+					return err
+				}
+				uids[i].Name = name
+			}
+			chosen[uids[i].Name] = true
+		}
+		if uids[i].Name == "" {
+			return fmt.Errorf("%w: a target UID name is required for client backups", ErrInvalidImport)
+		}
+	}
+	return nil
+}
+
+func importUIDTx(tx *sql.Tx, uid importUID, opts ImportOptions, result *ImportResult) error {
+	var status bool
+	err := tx.QueryRow(`SELECT status FROM UID WHERE name = $1`, uid.Name).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error checking UID %s: %w", uid.Name, err)
+	}
+	// A disabled UID takes no snapshots, from a sync or an import.
+	if err == nil && !status {
+		return uidDisabled(uid.Name)
+	}
+	if err == sql.ErrNoRows {
+		if !opts.Create {
+			return fmt.Errorf("%w: %s not found", ErrInvalidImport, uid.Name)
+		}
+		// A token another UID has taken meanwhile is left out, the owner
+		// can create a new one. CalDAV passwords may repeat.
+		_, err = tx.Exec(`INSERT INTO UID (name, status, quota_bytes, quota_snapshots, read_token, share_token, caldav_secret)
+			VALUES ($1, $2, $3, $4,
+				(SELECT NULLIF($5, '') WHERE NOT EXISTS (SELECT 1 FROM UID WHERE read_token = $5)),
+				(SELECT NULLIF($6, '') WHERE NOT EXISTS (SELECT 1 FROM UID WHERE share_token = $6)),
+				NULLIF($7, ''))`,
+			uid.Name, uid.Status, uid.Quota.MaxBytes, uid.Quota.MaxSnapshots, uid.ReadToken, uid.ShareToken, uid.DAVSecret)
+		if err != nil {
+			return fmt.Errorf("error creating UID %s: %w", uid.Name, err)
+		}
+	}
+
+	// The quota is checked for the whole batch at the end, a snapshot that
+	// is overwritten only adds the bytes it grew by.
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, uid.Name); err != nil {
+		return fmt.Errorf("error locking UID %s: %w", uid.Name, err)
+	}
+	usage, err := lookupID(tx, uid.Name)
+	if err != nil {
+		return fmt.Errorf("error reading the usage of %s: %w", uid.Name, err)
+	}
+	var size int64
+	added := 0
+
+	var imported []int64
+	for _, snapshot := range uid.Snapshots {
+		// The same limits as for a sync, an import must not store what
+		// /sync would refuse.
+		upload := AxisGTDType{Todolist: snapshot.Todolist, Config: snapshot.Config, Time: snapshot.Time}
+		if err := checkUpload(&upload); err != nil {
+			return fmt.Errorf("%w: snapshot %d of %s: %s", ErrInvalidImport, snapshot.Time, uid.Name, err.Message)
+		}
+
+		var stored bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM axisgtd WHERE uid_name = $1 AND time = $2)`,
+			uid.Name, snapshot.Time).Scan(&stored)
+		if err != nil {
+			return fmt.Errorf("error checking snapshot %d of %s: %w", snapshot.Time, uid.Name, err)
+		}
+		if stored && opts.Mode != ImportOverwrite {
+			result.Skipped++
+			continue
+		}
+		if stored {
+			var replaced int64
+			err = tx.QueryRow(`DELETE FROM axisgtd WHERE uid_name = $1 AND time = $2
+				RETURNING octet_length(todolist) + octet_length(config)`, uid.Name, snapshot.Time).Scan(&replaced)
+			if err != nil {
+				return fmt.Errorf("error overwriting snapshot %d of %s: %w", snapshot.Time, uid.Name, err)
+			}
+			size -= replaced
+			added--
+			result.Overwritten++
+		}
+
+		query := `INSERT INTO axisgtd (todolist,config,time,uid_name,device) VALUES ($1,$2,$3,$4,NULLIF($5, ''))`
+		_, err = tx.Exec(query, snapshot.Todolist, snapshot.Config, snapshot.Time, uid.Name, deviceName(snapshot.Device))
+		if err != nil {
+			return fmt.Errorf("error importing snapshot %d of %s: %w", snapshot.Time, uid.Name, err)
+		}
+		result.Imported++
+		imported = append(imported, snapshot.Time)
+		size += int64(len(snapshot.Todolist) + len(snapshot.Config))
+		added++
+	}
+	if err := quotaError(usage, size, added); err != nil {
+		return err
+	}
+	if len(imported) > 0 {
+		if err := queueEvent(tx, uid.Name, EventSnapshotCreated, snapshotEvent("", imported...)); err != nil {
+			return fmt.Errorf("error queueing webhooks of %s: %w", uid.Name, err)
+		}
+	}
+	// An overwritten snapshot keeps its time, so the cached statistics can't
+	// tell it changed.
+	if result.Overwritten > 0 {
+		if _, err := tx.Exec(`DELETE FROM axisgtd_stats WHERE uid_name = $1`, uid.Name); err != nil {
+			return fmt.Errorf("error resetting statistics of %s: %w", uid.Name, err)
+		}
+	}
+	return nil
+}
+
+// parseImport detects whether r holds an export archive or a client backup
+// and validates it completely before anything is written.
+func parseImport(r io.Reader) ([]importUID, error) {
+	dec := json.NewDecoder(r)
+	var first json.RawMessage
+	if err := dec.Decode(&first); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	var header ExportHeader
+	if json.Unmarshal(first, &header) == nil && header.Type == "header" {
+		return parseArchive(dec, header)
+	}
+	return parseBackup(first)
+}
+
+func parseArchive(dec *json.Decoder, header ExportHeader) ([]importUID, error) {
+	if header.Format != ExportFormat {
+		return nil, fmt.Errorf("%w: unknown archive format %q", ErrInvalidImport, header.Format)
+	}
+	if header.Version < 1 || header.Version > ExportVersion {
+		return nil, fmt.Errorf("%w: unsupported archive version %d", ErrInvalidImport, header.Version)
+	}
+
+	var uids []importUID
+	snapshots := 0
+	for {
+		var line struct {
+			ExportSnapshot
+			Status     bool      `json:"status"`
+			Quota      QuotaType `json:"quota"`
+			ReadToken  string    `json:"read_token"`
+			ShareToken string    `json:"share_token"`
+			DAVSecret  string    `json:"caldav_secret"`
+			UIDs       int       `json:"uids"`
+			Snapshots  int       `json:"snapshots"`
+		}
+		if err := dec.Decode(&line); err == io.EOF {
+			return nil, fmt.Errorf("%w: archive is truncated, missing end line", ErrInvalidImport)
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+
+		switch line.Type {
+		case "uid":
+			if err := validateUIDName(line.Name); err != nil {
+				return nil, err
+			}
+			if line.Quota.MaxBytes < 0 || line.Quota.MaxSnapshots < 0 {
+				return nil, fmt.Errorf("%w: quota of %s must not be negative", ErrInvalidImport, line.Name)
+			}
+			for _, token := range []string{line.ReadToken, line.ShareToken, line.DAVSecret} {
+				if len(token) > 64 {
+					return nil, fmt.Errorf("%w: tokens of %s must be at most 64 characters", ErrInvalidImport, line.Name)
+				}
+			}
+			uids = append(uids, importUID{ExportUID: ExportUID{
+				Type:       line.Type,
+				Name:       line.Name,
+				Status:     line.Status,
+				Quota:      line.Quota,
+				ReadToken:  line.ReadToken,
+				ShareToken: line.ShareToken,
+				DAVSecret:  line.DAVSecret,
+			}})
+		case "snapshot":
+			if len(uids) == 0 || uids[len(uids)-1].Name != line.Name {
+				return nil, fmt.Errorf("%w: snapshot %d does not follow its uid line", ErrInvalidImport, line.Time)
+			}
+			if err := validateSnapshot(line.ExportSnapshot); err != nil {
+				return nil, err
+			}
+			uids[len(uids)-1].Snapshots = append(uids[len(uids)-1].Snapshots, line.ExportSnapshot)
+			snapshots++
+		case "end":
+			if line.UIDs != len(uids) || line.Snapshots != snapshots {
+				return nil, fmt.Errorf("%w: archive lists %d UIDs and %d snapshots but contains %d and %d",
+					ErrInvalidImport, line.UIDs, line.Snapshots, len(uids), snapshots)
+			}
+			return uids, nil
+		default:
+			return nil, fmt.Errorf("%w: unknown line type %q", ErrInvalidImport, line.Type)
+		}
+	}
+}
+
+// parseBackup accepts a single client backup object or an array of them,
+// which also covers the output of GET /id/{name}.
+func parseBackup(data json.RawMessage) ([]importUID, error) {
+	var backups []backupSnapshot
+	if len(bytes.TrimSpace(data)) > 0 && bytes.TrimSpace(data)[0] == '[' {
+		if err := json.Unmarshal(data, &backups); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+	} else {
+		var backup backupSnapshot
+		if err := json.Unmarshal(data, &backup); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		backups = append(backups, backup)
+	}
+
+	// Entries without a time are stored from now on, one millisecond apart in
+	// their order, so none of them collides with another entry and is skipped.
+	used := map[int64]bool{}
+	for _, backup := range backups {
+		used[backup.Time] = true
+	}
+	next := time.Now().UnixMilli()
+
+	uid := importUID{ExportUID: ExportUID{Status: true}}
+	for _, backup := range backups {
+		snapshot := ExportSnapshot{
+			Todolist: backupDocument(backup.Todolist),
+			Config:   backupDocument(backup.Config),
+			Time:     backup.Time,
+		}
+		if snapshot.Time == 0 {
+			for used[next] {
+				next++
+			}
+			snapshot.Time = next
+			used[next] = true
+		}
+		if err := validateSnapshot(snapshot); err != nil {
+			return nil, err
+		}
+		uid.Snapshots = append(uid.Snapshots, snapshot)
+	}
+	return []importUID{uid}, nil
+}
+
+func backupDocument(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func validateUIDName(name string) error {
+	if name == "" || len(name) > 100 {
+		return fmt.Errorf("%w: UID name must be between 1 and 100 characters", ErrInvalidImport)
+	}
+	return nil
+}
+
+func validateSnapshot(snapshot ExportSnapshot) error {
+	if snapshot.Todolist == "" || snapshot.Config == "" {
+		return fmt.Errorf("%w: snapshot %d is missing todolist or config", ErrInvalidImport, snapshot.Time)
+	}
+	if snapshot.Time <= 0 {
+		return fmt.Errorf("%w: snapshot time must be positive, got %d", ErrInvalidImport, snapshot.Time)
+	}
+	return nil
+}
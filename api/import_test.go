@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseArchive(t *testing.T) {
+	const header = `{"type":"header","format":"axisgtdsync-export","version":1,"exported_at":1725000000000}` + "\n"
+	const uid = `{"type":"uid","name":"abc","status":true}` + "\n"
+	const snapshot = `{"type":"snapshot","name":"abc","todolist":"[]","config":"{}","time":1725000000000,"device":"phone"}` + "\n"
+
+	tests := []struct {
+		name      string
+		archive   string
+		snapshots int
+		err       string
+	}{
+		{"empty uid", header + uid + `{"type":"end","uids":1,"snapshots":0}`, 0, ""},
+		{"snapshot", header + uid + snapshot + `{"type":"end","uids":1,"snapshots":1}`, 1, ""},
+		{"format", strings.Replace(header, "axisgtdsync-export", "other", 1) + `{"type":"end"}`, 0, "unknown archive format"},
+		{"version", strings.Replace(header, `"version":1`, `"version":2`, 1) + `{"type":"end"}`, 0, "unsupported archive version 2"},
+		{"truncated", header + uid + snapshot, 0, "truncated"},
+		{"orphan snapshot", header + snapshot + `{"type":"end","uids":0,"snapshots":1}`, 0, "does not follow its uid line"},
+		{"counts", header + uid + snapshot + `{"type":"end","uids":1,"snapshots":2}`, 0, "contains 1 and 1"},
+		{"line type", header + `{"type":"other"}`, 0, `unknown line type "other"`},
+		{"uid name", header + `{"type":"uid","name":""}`, 0, "UID name"},
+		{"no todolist", header + uid + strings.Replace(snapshot, `"[]"`, `""`, 1), 0, "missing todolist or config"},
+		{"syntax", header + `{"type":`, 0, "invalid import"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uids, err := parseImport(strings.NewReader(tt.archive))
+			if tt.err != "" {
+				if !errors.Is(err, ErrInvalidImport) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseImport = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseImport = %v", err)
+			}
+			if len(uids) != 1 || uids[0].Name != "abc" || !uids[0].Status || len(uids[0].Snapshots) != tt.snapshots {
+				t.Fatalf("parseImport = %+v", uids)
+			}
+			if tt.snapshots > 0 && uids[0].Snapshots[0].Device != "phone" {
+				t.Errorf("device = %q, want phone", uids[0].Snapshots[0].Device)
+			}
+		})
+	}
+}
+
+func TestParseBackup(t *testing.T) {
+	tests := []struct {
+		name   string
+		backup string
+		want   []ExportSnapshot
+		err    string
+	}{
+		{
+			name:   "object",
+			backup: `{"todolist":"[1]","config":"{}","time":5}`,
+			want:   []ExportSnapshot{{Todolist: "[1]", Config: "{}", Time: 5}},
+		},
+		{
+			name:   "raw documents",
+			backup: `[{"todolist":[{"id":1}],"config":{"a":true},"time":5}]`,
+			want:   []ExportSnapshot{{Todolist: `[{"id":1}]`, Config: `{"a":true}`, Time: 5}},
+		},
+		{
+			name:   "array",
+			backup: `[{"todolist":"[]","config":"{}","time":5},{"todolist":"[2]","config":"{}","time":6}]`,
+			want:   []ExportSnapshot{{Todolist: "[]", Config: "{}", Time: 5}, {Todolist: "[2]", Config: "{}", Time: 6}},
+		},
+		{name: "no config", backup: `{"todolist":"[]","time":5}`, err: "missing todolist or config"},
+		{name: "negative time", backup: `{"todolist":"[]","config":"{}","time":-1}`, err: "must be positive"},
+		{name: "not an object", backup: `"backup"`, err: "invalid import"},
+		{name: "not an array of objects", backup: `[1]`, err: "invalid import"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uids, err := parseImport(strings.NewReader(tt.backup))
+			if tt.err != "" {
+				if !errors.Is(err, ErrInvalidImport) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseImport = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseImport = %v", err)
+			}
+			if len(uids) != 1 || uids[0].Name != "" || !uids[0].Status {
+				t.Fatalf("parseImport = %+v, want one enabled UID", uids)
+			}
+			got := uids[0].Snapshots
+			if len(got) != len(tt.want) {
+				t.Fatalf("snapshots = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("snapshot %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// Entries without a time must not collide with each other or with the
+// timed ones, or the import would skip them as duplicates.
+func TestParseBackupUntimed(t *testing.T) {
+	now := time.Now().UnixMilli()
+	// The timed entry most likely holds a time the untimed ones would get.
+	backup := `[{"todolist":"[1]","config":"{}"},` +
+		`{"todolist":"[2]","config":"{}","time":` + strconv.FormatInt(now+1, 10) + `},` +
+		`{"todolist":"[3]","config":"{}"}]`
+
+	uids, err := parseImport(strings.NewReader(backup))
+	if err != nil {
+		t.Fatalf("parseImport = %v", err)
+	}
+	seen := map[int64]bool{}
+	var last int64
+	for i, snapshot := range uids[0].Snapshots {
+		if seen[snapshot.Time] {
+			t.Errorf("snapshot %d reuses time %d", i, snapshot.Time)
+		}
+		seen[snapshot.Time] = true
+		if snapshot.Time < now {
+			t.Errorf("snapshot %d has time %d, before the import started at %d", i, snapshot.Time, now)
+		}
+		if snapshot.Todolist != "[2]" {
+			if snapshot.Time <= last {
+				t.Errorf("untimed snapshot %d has time %d, not after %d", i, snapshot.Time, last)
+			}
+			last = snapshot.Time
+		}
+	}
+}
+
+func TestImportNames(t *testing.T) {
+	archive := func() []importUID {
+		return []importUID{
+			{ExportUID: ExportUID{Name: "abc", ReadToken: "read"}},
+			{ExportUID: ExportUID{Name: "def", ShareToken: "share"}},
+		}
+	}
+	tests := []struct {
+		name      string
+		uids      []importUID
+		opts      ImportOptions
+		generated []string
+		want      []string
+		tokens    bool
+		err       string
+	}{
+		{"archive names", archive(), ImportOptions{Mode: ImportSkip}, nil, []string{"abc", "def"}, true, ""},
+		// GetName only checks the stored UIDs and may return the same name
+		// twice.
+		{"new", archive(), ImportOptions{Mode: ImportNew}, []string{"a1", "a1", "b2"}, []string{"a1", "b2"}, false, ""},
+		{"target", archive()[:1], ImportOptions{Mode: ImportSkip, Name: "xyz"}, nil, []string{"xyz"}, false, ""},
+		{"target of two", archive(), ImportOptions{Mode: ImportSkip, Name: "xyz"}, nil, nil, false, "contains 2 UIDs"},
+		{"backup", []importUID{{}}, ImportOptions{Mode: ImportSkip}, nil, nil, false, "target UID name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated := tt.generated
+			newName := func() (string, error) {
+				if len(generated) == 0 {
+					t.Fatal("too many names generated")
+				}
+				name := generated[0]
+				generated = generated[1:]
+				return name, nil
+			}
+			err := importNames(tt.uids, tt.opts, newName)
+			if tt.err != "" {
+				if !errors.Is(err, ErrInvalidImport) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("importNames = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("importNames = %v", err)
+			}
+			for i, uid := range tt.uids {
+				if uid.Name != tt.want[i] {
+					t.Errorf("name %d = %s, want %s", i, uid.Name, tt.want[i])
+				}
+				if hasTokens := uid.ReadToken != "" || uid.ShareToken != ""; hasTokens != tt.tokens {
+					t.Errorf("tokens of %s = %q, %q", uid.Name, uid.ReadToken, uid.ShareToken)
+				}
+			}
+		})
+	}
+}
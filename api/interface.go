@@ -1,10 +1,15 @@
 package api
 
+import "time"
+
 type AxisGTDType struct {
 	Todolist string `json:"todolist"`
 	Config   string `json:"config"`
 	Time     int64  `json:"time"`
 	UIDName  string `json:"uidname"`
+	// Device names the client that pushed the snapshot, the X-Device or
+	// User-Agent header when empty.
+	Device string `json:"device,omitempty"`
 }
 
 type UID struct {
@@ -25,9 +30,400 @@ type IDSType struct {
 	Name   string `json:"name"`
 	Status bool   `json:"status"`
 	Count  int    `json:"count"`
+	// Bytes and LargestBytes count the todolist and config of the stored
+	// snapshots, LastSync is the time of the newest one.
+	Bytes        int64     `json:"bytes"`
+	LargestBytes int64     `json:"largest_bytes"`
+	LastSync     int64     `json:"last_sync"`
+	Quota        QuotaType `json:"quota"`
+}
+
+// QuotaType limits what one UID may store, 0 is unlimited. When set through
+// the API, 0 falls back to limits.max_uid_bytes and limits.max_uid_snapshots.
+type QuotaType struct {
+	MaxBytes     int64 `json:"max_bytes"`
+	MaxSnapshots int   `json:"max_snapshots"`
 }
 
 type ConfigType struct {
-	PSQLURL string `json:"psql"`
-	CorsURL string `json:"cors"`
+	Listen     string `yaml:"listen" json:"listen"`
+	PSQLURL    string `yaml:"psql_url" json:"psql_url"`
+	AdminToken string `yaml:"admin_token" json:"admin_token"`
+	BodyLimit  int    `yaml:"body_limit" json:"body_limit"`
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// after SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration  `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+	CORS            CORSConfig     `yaml:"cors" json:"cors"`
+	DB              DBConfig       `yaml:"db" json:"db"`
+	Paths           PathsConfig    `yaml:"paths" json:"paths"`
+	Features        FeaturesConfig `yaml:"features" json:"features"`
+	Limits          LimitsConfig   `yaml:"limits" json:"limits"`
+	Cache           CacheConfig    `yaml:"cache" json:"cache"`
+	Metrics         MetricsConfig  `yaml:"metrics" json:"metrics"`
+	Log             LogConfig      `yaml:"log" json:"log"`
+	Webhooks        WebhooksConfig `yaml:"webhooks" json:"webhooks"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" json:"level"`
+	// Format is json or text (logfmt).
+	Format string `yaml:"format" json:"format"`
+}
+
+type CORSConfig struct {
+	Origins []string `yaml:"origins" json:"origins"`
+}
+
+type DBConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns" json:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" json:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" json:"conn_max_idle_time"`
+}
+
+type PathsConfig struct {
+	Views   string `yaml:"views" json:"views"`
+	Public  string `yaml:"public" json:"public"`
+	Swagger string `yaml:"swagger" json:"swagger"`
+}
+
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// PerUIDLabels adds a uid label to the sync metrics. UID names grant
+	// access to their data, so this requires Auth.
+	PerUIDLabels bool `yaml:"per_uid_labels" json:"per_uid_labels"`
+	// Auth protects /metrics with the admin token.
+	Auth bool `yaml:"auth" json:"auth"`
+}
+
+// LimitsConfig bounds what SyncPost accepts, 0 disables a limit. MaxWait is
+// the exception, 0 disables long polling instead of allowing any wait.
+type LimitsConfig struct {
+	MaxTodolistBytes int `yaml:"max_todolist_bytes" json:"max_todolist_bytes"`
+	MaxConfigBytes   int `yaml:"max_config_bytes" json:"max_config_bytes"`
+	// MaxUIDBytes caps the todolist and config bytes stored across all
+	// snapshots of one UID.
+	MaxUIDBytes int `yaml:"max_uid_bytes" json:"max_uid_bytes"`
+	// MaxUIDSnapshots caps the number of snapshots kept per UID.
+	MaxUIDSnapshots int `yaml:"max_uid_snapshots" json:"max_uid_snapshots"`
+	// MaxWait caps the ?wait= of long-polling pulls, 0 answers them at once.
+	MaxWait time.Duration `yaml:"max_wait" json:"max_wait"`
+	// MaxClockSkew is how far in the future a snapshot's time may be.
+	MaxClockSkew time.Duration `yaml:"max_clock_skew" json:"max_clock_skew"`
+	// TodolistSchema and ConfigSchema are optional JSON Schema files the
+	// uploaded fields must match.
+	TodolistSchema string `yaml:"todolist_schema" json:"todolist_schema"`
+	ConfigSchema   string `yaml:"config_schema" json:"config_schema"`
+}
+
+type CacheConfig struct {
+	// Size is how many UIDs keep their newest snapshot in memory, 0
+	// disables the cache.
+	Size int `yaml:"size" json:"size"`
+	// MaxBytes bounds the todolists and configs held, the least recently
+	// pulled go first. 0 leaves only Size as the bound.
+	MaxBytes int `yaml:"max_bytes" json:"max_bytes"`
+}
+
+type FeaturesConfig struct {
+	ManagePage bool `yaml:"manage_page" json:"manage_page"`
+	Swagger    bool `yaml:"swagger" json:"swagger"`
+	Export     bool `yaml:"export" json:"export"`
+	Import     bool `yaml:"import" json:"import"`
+	// Compression compresses responses with br, gzip, deflate or zstd as
+	// the client accepts.
+	Compression bool `yaml:"compression" json:"compression"`
+	// CalDAV serves the tasks to CalDAV clients under /dav, each UID needs
+	// a CalDAV secret as well.
+	CalDAV bool `yaml:"caldav" json:"caldav"`
+	// Webhooks serves the webhook endpoints and delivers their events.
+	Webhooks bool `yaml:"webhooks" json:"webhooks"`
+}
+
+type WebhooksConfig struct {
+	// Timeout limits one delivery attempt.
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	// MaxAttempts is how often a delivery is tried before it is marked
+	// failed.
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts"`
+	// RetryDelay is the wait before the first retry, doubled after every
+	// further failed attempt up to MaxRetryDelay.
+	RetryDelay    time.Duration `yaml:"retry_delay" json:"retry_delay"`
+	MaxRetryDelay time.Duration `yaml:"max_retry_delay" json:"max_retry_delay"`
+	// Interval is how often the queue is checked for deliveries that are
+	// due, including the ones queued by other instances or the command line.
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	// Retention is how long finished deliveries stay in the log, 0 keeps
+	// them.
+	Retention time.Duration `yaml:"retention" json:"retention"`
+	// AllowPrivate lets the webhooks of a UID reach loopback and private
+	// addresses. Global webhooks, which need the admin token, always can.
+	AllowPrivate bool `yaml:"allow_private" json:"allow_private"`
+}
+
+type ExportHeader struct {
+	Type       string `json:"type"`
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	ExportedAt int64  `json:"exported_at"`
+}
+
+type ExportUID struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Status bool   `json:"status"`
+	// Quota and the tokens are missing from the archives of earlier
+	// releases, they import as no quota and no tokens.
+	Quota      QuotaType `json:"quota"`
+	ReadToken  string    `json:"read_token,omitempty"`
+	ShareToken string    `json:"share_token,omitempty"`
+	DAVSecret  string    `json:"caldav_secret,omitempty"`
+}
+
+type ExportSnapshot struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Todolist string `json:"todolist"`
+	Config   string `json:"config"`
+	Time     int64  `json:"time"`
+	Device   string `json:"device,omitempty"`
+}
+
+type ExportFooter struct {
+	Type      string `json:"type"`
+	UIDs      int    `json:"uids"`
+	Snapshots int    `json:"snapshots"`
+}
+
+type ImportResult struct {
+	UIDs        []string `json:"uids"`
+	Imported    int      `json:"imported"`
+	Skipped     int      `json:"skipped"`
+	Overwritten int      `json:"overwritten"`
+}
+
+// TodoTxtResult counts the tasks a todo.txt import changed. Time is the
+// snapshot it stored, 0 when nothing changed.
+type TodoTxtResult struct {
+	Added   int   `json:"added"`
+	Updated int   `json:"updated"`
+	Removed int   `json:"removed"`
+	Time    int64 `json:"time"`
+}
+
+// SearchResultType lists the tasks of a UID's history that match a search.
+type SearchResultType struct {
+	Query string `json:"query"`
+	// Snapshots is the number of snapshots containing a match.
+	Snapshots int             `json:"snapshots"`
+	Tasks     []SearchHitType `json:"tasks"`
+}
+
+// SearchHitType is one matching task with the snapshots it appeared and
+// disappeared in. Task is the newest version of it that matched.
+type SearchHitType struct {
+	Task      TaskType           `json:"task"`
+	FirstSeen int64              `json:"first_seen"`
+	LastSeen  int64              `json:"last_seen"`
+	Present   bool               `json:"present"`
+	Changes   []SearchChangeType `json:"changes"`
+}
+
+type SearchChangeType struct {
+	// Time is the snapshot the task appeared or disappeared in.
+	Time int64 `json:"time"`
+	// Change is "appeared" or "disappeared".
+	Change string `json:"change"`
+}
+
+// TaskTimelineType is the history of one task, oldest event first.
+type TaskTimelineType struct {
+	ID string `json:"id"`
+	// Present tells whether the newest snapshot still has the task.
+	Present bool            `json:"present"`
+	Events  []TaskEventType `json:"events"`
+}
+
+// TaskEventType is a change to a task between two snapshots. Time is the
+// snapshot that has the change and Device the client that stored it, empty
+// for snapshots from before devices were recorded.
+type TaskEventType struct {
+	Time   int64  `json:"time"`
+	Device string `json:"device"`
+	// Event is "created", "edited", "completed", "reopened", "deleted" or
+	// "restored".
+	Event   string           `json:"event"`
+	Changes []TaskChangeType `json:"changes,omitempty"`
+	// Task is the task after the event, before it for "deleted".
+	Task TaskType `json:"task"`
+}
+
+// TaskChangeType is one edited field of an "edited" or "restored" event.
+type TaskChangeType struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// StatsType are the productivity statistics of a UID. Days and Weeks end
+// with the current one, oldest first.
+type StatsType struct {
+	Snapshots int `json:"snapshots"`
+	// Open and Overdue count the tasks of the newest snapshot.
+	Open          int `json:"open"`
+	Overdue       int `json:"overdue"`
+	Completed     int `json:"completed"`
+	CompletedLate int `json:"completed_late"`
+	// AverageCompletion is the mean time from creating to completing a task
+	// in milliseconds.
+	AverageCompletion int64             `json:"average_completion"`
+	Days              []StatsPeriodType `json:"days"`
+	Weeks             []StatsPeriodType `json:"weeks"`
+	Devices           []StatsDeviceType `json:"devices"`
+}
+
+// StatsPeriodType counts the tasks created and completed and the snapshots
+// stored in a day or a week.
+type StatsPeriodType struct {
+	// Start is the first day, like 2024-09-02.
+	Start     string `json:"start"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+	Syncs     int    `json:"syncs"`
+}
+
+// StatsDeviceType is how often a device syncs, Device is empty for the
+// snapshots from before devices were recorded.
+type StatsDeviceType struct {
+	Device    string `json:"device"`
+	Snapshots int    `json:"snapshots"`
+	FirstSync int64  `json:"first_sync"`
+	LastSync  int64  `json:"last_sync"`
+	// PerDay is the average number of snapshots per day between the first
+	// and the last.
+	PerDay float64 `json:"per_day"`
+}
+
+// WebhookRequest subscribes a URL to events, no events means all of them.
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// WebhookType is a webhook of a UID, or a global one when UID is empty.
+// Secret signs the requests and is only returned when the webhook is
+// created.
+type WebhookType struct {
+	ID        int64    `json:"id"`
+	UID       string   `json:"uid"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// WebhookEventType is the body of a webhook request. ID identifies the event
+// and stays the same across retries and replays.
+type WebhookEventType struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	UID   string `json:"uid"`
+	Time  int64  `json:"time"`
+	Data  any    `json:"data,omitempty"`
+}
+
+// WebhookDeliveryType is one event queued for a webhook with the outcome of
+// its last attempt. Status is "pending", "delivered" or "failed".
+type WebhookDeliveryType struct {
+	ID             int64  `json:"id"`
+	WebhookID      int64  `json:"webhook_id"`
+	Event          string `json:"event"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	ResponseStatus int    `json:"response_status"`
+	Error          string `json:"error"`
+	CreatedAt      int64  `json:"created_at"`
+	LastAttempt    int64  `json:"last_attempt"`
+	// NextAttempt is 0 once the delivery is finished.
+	NextAttempt int64            `json:"next_attempt"`
+	Payload     WebhookEventType `json:"payload"`
+}
+
+type SnapshotInfo struct {
+	Time          int64  `json:"time"`
+	TodolistBytes int    `json:"todolist_bytes"`
+	ConfigBytes   int    `json:"config_bytes"`
+	Device        string `json:"device"`
+}
+
+type ReadyType struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type VersionType struct {
+	Version             string `json:"version"`
+	Commit              string `json:"commit"`
+	GoVersion           string `json:"go_version"`
+	SchemaVersion       int    `json:"schema_version"`
+	LatestSchemaVersion int    `json:"latest_schema_version"`
+}
+
+type ErrorType struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// TaskType is a task of the todolist. Times are Unix milliseconds, 0 when
+// unset. Raw is the task as stored by the client, with any fields the server
+// does not know.
+type TaskType struct {
+	ID          string         `json:"id"`
+	Title       string         `json:"title"`
+	Notes       string         `json:"notes"`
+	Done        bool           `json:"done"`
+	Tags        []string       `json:"tags"`
+	Project     string         `json:"project"`
+	Due         int64          `json:"due"`
+	CreatedAt   int64          `json:"created_at"`
+	CompletedAt int64          `json:"completed_at"`
+	Raw         map[string]any `json:"raw"`
+}
+
+// TaskPatch adds or edits a task, nil fields are left alone. Due is a date
+// like 2024-09-01, a time like 2024-09-01T18:00:00Z or Unix milliseconds,
+// "" removes it.
+type TaskPatch struct {
+	Title   *string   `json:"title"`
+	Notes   *string   `json:"notes"`
+	Done    *bool     `json:"done"`
+	Tags    *[]string `json:"tags"`
+	Project *string   `json:"project"`
+	Due     *string   `json:"due"`
+}
+
+type DAVSecretType struct {
+	// Secret is the password of the CalDAV account, any user name works.
+	Secret string `json:"secret"`
+	// URL is the account URL to add to the client.
+	URL string `json:"url"`
+}
+
+type ShareTokenType struct {
+	Token string `json:"token"`
+	// View and Markdown are the URLs of the shared task list.
+	View     string `json:"view"`
+	Markdown string `json:"markdown"`
+}
+
+type ReadTokenType struct {
+	Token string `json:"token"`
+	// Calendar is the feed URL to subscribe to.
+	Calendar string `json:"calendar"`
+}
+
+type UIDPatch struct {
+	Status *bool `json:"status"`
 }
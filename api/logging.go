@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// SetupLogging installs the slog handler chosen by log.format and
+// log.level as the default logger, which the standard log package then
+// writes through as well.
+func SetupLogging(cfg LogConfig) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return fmt.Errorf("log.level: %v", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case "text", "logfmt":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("log.format must be json or text, got %q", cfg.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// RequestID takes the X-Request-ID header of the request or generates one,
+// and echoes it on the response.
+var RequestID = requestid.New()
+
+// RequestLogger logs one line per request once it has been answered. It
+// logs the route rather than the path: UID names grant access to their data
+// and tokens to the feeds, neither belongs in the logs.
+func RequestLogger(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	status := responseStatus(c, err)
+	level := slog.LevelInfo
+	if status >= 500 {
+		level = slog.LevelError
+	} else if status >= 400 {
+		level = slog.LevelWarn
+	}
+	attrs := []any{
+		"method", c.Method(),
+		"route", c.Route().Path,
+		"status", status,
+		"duration", time.Since(start),
+		"ip", c.IP(),
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		attrs = append(attrs, "code", apiErr.Code)
+	}
+	requestLog(c).Log(c.UserContext(), level, "request", attrs...)
+	return err
+}
+
+// requestLog returns the default logger with the request ID attached.
+func requestLog(c *fiber.Ctx) *slog.Logger {
+	if id, ok := c.Locals("requestid").(string); ok {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
+
+// logError records the real cause of a failed request, the response only
+// carries a generic message.
+func logError(c *fiber.Ctx, msg string, err error) {
+	requestLog(c).Error(msg, "error", err, "method", c.Method(), "route", c.Route().Path)
+}
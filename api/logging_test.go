@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// captureLog sends the default logger to a buffer for one test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &buf
+}
+
+func TestRequestLogger(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(RequestID, RequestLogger)
+	app.Get("/api/v1/ids/:name/snapshots/latest", func(c *fiber.Ctx) error {
+		return storeError(sql.ErrNoRows, "Get Failed")
+	})
+	app.Get("/feeds/:token.ics", func(c *fiber.Ctx) error {
+		return c.SendString("feed")
+	})
+
+	tests := []struct {
+		url    string
+		status int
+		level  string
+		code   string
+		route  string
+		cause  bool
+	}{
+		{"/api/v1/ids/secretname/snapshots/latest", 404, "WARN", "not_found", "/api/v1/ids/:name/snapshots/latest", true},
+		{"/feeds/secrettoken.ics", 200, "INFO", "", "/feeds/:token.ics", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			buf := captureLog(t)
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if strings.Contains(buf.String(), "secret") {
+				t.Errorf("log holds the name or token:\n%s", buf)
+			}
+
+			var request, cause map[string]any
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var entry map[string]any
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatal(err)
+				}
+				if entry["msg"] == "request" {
+					request = entry
+				} else {
+					cause = entry
+				}
+			}
+			if request == nil || request["level"] != tt.level || request["route"] != tt.route || request["status"] != float64(tt.status) || request["request_id"] == "" {
+				t.Errorf("request line = %v", request)
+			}
+			if code, _ := request["code"].(string); code != tt.code {
+				t.Errorf("code = %q, want %q", code, tt.code)
+			}
+			if tt.cause && (cause == nil || cause["level"] != "INFO" || cause["code"] != tt.code || cause["error"] == nil) {
+				t.Errorf("cause line = %v, want it at INFO with the code", cause)
+			}
+			if !tt.cause && cause != nil {
+				t.Errorf("cause line = %v, want none", cause)
+			}
+		})
+	}
+}
+
+func TestSetupLogging(t *testing.T) {
+	old := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	tests := []struct {
+		cfg LogConfig
+		err string
+	}{
+		{LogConfig{Level: "info", Format: "json"}, ""},
+		{LogConfig{Level: "DEBUG", Format: "logfmt"}, ""},
+		{LogConfig{Level: "loud", Format: "json"}, "log.level"},
+		{LogConfig{Level: "info", Format: "xml"}, `log.format must be json or text, got "xml"`},
+	}
+	for _, tt := range tests {
+		err := SetupLogging(tt.cfg)
+		if tt.err == "" && err != nil {
+			t.Errorf("SetupLogging(%+v) = %v, want nil", tt.cfg, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("SetupLogging(%+v) = %v, want %q", tt.cfg, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The metrics stay nil until InitMetrics is called, every observer checks
+// for that so the handlers work with metrics disabled.
+var (
+	registry        *prometheus.Registry
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	syncTotal       *prometheus.CounterVec
+	payloadBytes    *prometheus.HistogramVec
+)
+
+// InitMetrics registers every collector, call it after Configure and OpenDB.
+func InitMetrics() {
+	registry = prometheus.NewRegistry()
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "axisgtd_http_requests_total",
+		Help: "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "axisgtd_http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	syncLabels := []string{"op", "status"}
+	if config.Metrics.PerUIDLabels {
+		syncLabels = append(syncLabels, "uid")
+	}
+	syncTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "axisgtd_sync_total",
+		Help: "Sync pushes and pulls by HTTP status.",
+	}, syncLabels)
+
+	payloadBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "axisgtd_sync_payload_bytes",
+		Help:    "Size of the todolist and config fields pushed and pulled.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 9),
+	}, []string{"op", "field"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "axisgtd_cache_requests_total",
+		Help: "Head snapshot cache lookups by result, hit or miss.",
+	}, []string{"result"})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "axisgtd_webhook_deliveries_total",
+		Help: "Webhook delivery attempts by outcome, delivered, pending (will be retried) or failed.",
+	}, []string{"result"})
+
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "axisgtd"),
+		requestsTotal,
+		requestDuration,
+		syncTotal,
+		payloadBytes,
+		storeCollector{},
+		cacheRequests,
+		webhookDeliveries,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "axisgtd_cache_entries",
+			Help: "UIDs in the head snapshot cache.",
+		}, func() float64 {
+			if heads == nil {
+				return 0
+			}
+			return float64(heads.len())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "axisgtd_cache_bytes",
+			Help: "Bytes of todolists and configs in the head snapshot cache.",
+		}, func() float64 {
+			if heads == nil {
+				return 0
+			}
+			return float64(heads.usage())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "axisgtd_sync_waiters",
+			Help: "Long-polling pulls waiting for a new snapshot.",
+		}, func() float64 { return float64(hub.subscribers()) }),
+	)
+}
+
+// @Summary		Prometheus metrics
+// @Description	Exposes request, sync, payload, storage and database pool metrics. Needs the admin token when metrics.auth is set.
+// @Tags			health
+// @Produce		plain
+// @Success		200	{string}	string	"Prometheus text format"
+// @Router			/metrics [get]
+func MetricsHandler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// Metrics is the middleware counting every request by its matched route,
+// so the label values stay bounded by the routes registered. Label values
+// taken from the request are copied, Fiber reuses their memory for the next
+// one.
+func Metrics(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	labels := prometheus.Labels{
+		"route":  c.Route().Path,
+		"method": utils.CopyString(c.Method()),
+		"status": strconv.Itoa(responseStatus(c, err)),
+	}
+	requestsTotal.With(labels).Inc()
+	requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// ObserveSync counts the outcome of the sync handler it wraps, op is "push"
+// or "pull".
+func ObserveSync(op string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if syncTotal == nil {
+			return err
+		}
+
+		values := []string{op, strconv.Itoa(responseStatus(c, err))}
+		if config.Metrics.PerUIDLabels {
+			values = append(values, utils.CopyString(c.Params("name")))
+		}
+		syncTotal.WithLabelValues(values...).Inc()
+		return err
+	}
+}
+
+// responseStatus is the status the client will see, including errors that
+// are only turned into a response by the error handler.
+func responseStatus(c *fiber.Ctx, err error) int {
+	var apiErr *APIError
+	var fiberErr *fiber.Error
+	switch {
+	case err == nil:
+		return c.Response().StatusCode()
+	case errors.As(err, &apiErr):
+		return apiErr.Status
+	case errors.As(err, &fiberErr):
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+func observePayload(op string, todolist string, cfg string) {
+	if payloadBytes == nil {
+		return
+	}
+	payloadBytes.WithLabelValues(op, "todolist").Observe(float64(len(todolist)))
+	payloadBytes.WithLabelValues(op, "config").Observe(float64(len(cfg)))
+}
+
+// storeCollector reads the UID and snapshot counts from the database on
+// every scrape.
+type storeCollector struct{}
+
+var (
+	uidsDesc = prometheus.NewDesc("axisgtd_uids",
+		"UIDs by status.", []string{"status"}, nil)
+	activeUIDsDesc = prometheus.NewDesc("axisgtd_active_uids",
+		"UIDs that pushed a snapshot in the last 24 hours.", nil, nil)
+	snapshotsDesc = prometheus.NewDesc("axisgtd_snapshots",
+		"Stored snapshot rows.", nil, nil)
+	uidSnapshotsDesc = prometheus.NewDesc("axisgtd_uid_snapshots",
+		"Stored snapshot rows per UID, only with metrics.per_uid_labels.", []string{"uid"}, nil)
+)
+
+func (storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- uidsDesc
+	ch <- activeUIDsDesc
+	ch <- snapshotsDesc
+	ch <- uidSnapshotsDesc
+}
+
+func (storeCollector) Collect(ch chan<- prometheus.Metric) {
+	var enabled, disabled float64
+	query := `SELECT COUNT(*) FILTER (WHERE status), COUNT(*) FILTER (WHERE NOT status) FROM UID`
+	if err := db.QueryRow(query).Scan(&enabled, &disabled); err != nil {
+		slog.Error("metrics: error counting UIDs", "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(uidsDesc, prometheus.GaugeValue, enabled, "enabled")
+	ch <- prometheus.MustNewConstMetric(uidsDesc, prometheus.GaugeValue, disabled, "disabled")
+
+	var active, snapshots float64
+	query = `SELECT COUNT(DISTINCT uid_name) FILTER (WHERE time > $1), COUNT(*) FROM axisgtd`
+	err := db.QueryRow(query, time.Now().Add(-24*time.Hour).UnixMilli()).Scan(&active, &snapshots)
+	if err != nil {
+		slog.Error("metrics: error counting snapshots", "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(activeUIDsDesc, prometheus.GaugeValue, active)
+	ch <- prometheus.MustNewConstMetric(snapshotsDesc, prometheus.GaugeValue, snapshots)
+
+	if !config.Metrics.PerUIDLabels {
+		return
+	}
+	rows, err := db.Query(`SELECT uid_name, COUNT(*) FROM axisgtd GROUP BY uid_name`)
+	if err != nil {
+		slog.Error("metrics: error counting snapshots per UID", "error", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var count float64
+		if err := rows.Scan(&name, &count); err != nil {
+			slog.Error("metrics: error counting snapshots per UID", "error", err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(uidSnapshotsDesc, prometheus.GaugeValue, count, name)
+	}
+}
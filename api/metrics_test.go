@@ -0,0 +1,134 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestResponseStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"answered", nil, 201},
+		{"api error", uidNotFound("a"), 404},
+		{"fiber error", fiber.ErrRequestEntityTooLarge, 413},
+		{"other", errors.New("boom"), 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				c.Status(201)
+				got = responseStatus(c, tt.err)
+				return nil
+			})
+			if _, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("responseStatus = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// useMetrics initialises the metrics for one test. The store collector gets
+// a database that is out of reach, it only logs then.
+func useMetrics(t *testing.T, perUID bool) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Metrics.PerUIDLabels = perUID
+	useConfig(t, cfg)
+	unreachable, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=axisgtd dbname=axisgtd sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDB := db
+	db = unreachable
+	t.Cleanup(func() {
+		unreachable.Close()
+		db = oldDB
+		registry, requestsTotal, requestDuration, syncTotal, payloadBytes = nil, nil, nil, nil, nil
+		cacheRequests, webhookDeliveries = nil, nil
+	})
+	captureLog(t)
+	InitMetrics()
+}
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name   string
+		perUID bool
+		want   []string
+		absent []string
+	}{
+		{
+			name: "default labels",
+			want: []string{
+				`axisgtd_http_requests_total{method="GET",route="/sync/:name",status="200"} 1`,
+				`axisgtd_http_requests_total{method="POST",route="/sync/:name",status="409"} 1`,
+				`axisgtd_sync_total{op="pull",status="200"} 1`,
+				`axisgtd_sync_total{op="push",status="409"} 1`,
+				`axisgtd_http_request_duration_seconds_count{method="GET",route="/sync/:name",status="200"} 1`,
+				`axisgtd_sync_waiters 0`,
+			},
+			absent: []string{"secretname"},
+		},
+		{
+			name:   "per UID labels",
+			perUID: true,
+			want: []string{
+				`axisgtd_sync_total{op="pull",status="200",uid="secretname"} 1`,
+				`axisgtd_sync_total{op="push",status="409",uid="secretname"} 1`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMetrics(t, tt.perUID)
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Use(Metrics)
+			app.Get("/sync/:name", ObserveSync("pull"), func(c *fiber.Ctx) error {
+				return c.SendString("{}")
+			})
+			app.Post("/sync/:name", ObserveSync("push"), func(c *fiber.Ctx) error {
+				return storeError(ErrHeadChanged, "Sync Failed")
+			})
+			app.Get("/metrics", MetricsHandler())
+
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				if _, err := app.Test(httptest.NewRequest(method, "/sync/secretname", nil)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != 200 {
+				t.Fatalf("status = %d: %s", resp.StatusCode, body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(body), want+"\n") {
+					t.Errorf("metrics lack %s", want)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(string(body), absent) {
+					t.Errorf("metrics hold %s", absent)
+				}
+			}
+		})
+	}
+}
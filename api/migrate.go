@@ -0,0 +1,192 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// migrations are applied in order and recorded in schema_migrations, the
+// schema version is the number of applied migrations. Never edit or reorder
+// an entry that has been released, append a new one instead.
+var migrations = []string{
+	// 1: the tables created by earlier releases, hence IF NOT EXISTS.
+	`CREATE TABLE IF NOT EXISTS UID (
+		id serial NOT NULL,
+		name character varying(100) NOT NULL,
+		status BOOLEAN NOT NULL,
+		UNIQUE (name)
+	);
+	CREATE TABLE IF NOT EXISTS axisgtd (
+		todolist TEXT NOT NULL,
+		config TEXT NOT NULL,
+		time BIGINT NOT NULL,
+		uid_name CHARACTER VARYING(100) NOT NULL,
+		CONSTRAINT fk_uid_name FOREIGN KEY (uid_name) REFERENCES UID(name)
+	);`,
+	// 2: per-UID quotas, 0 falls back to the limits.max_uid_* defaults.
+	`ALTER TABLE UID
+		ADD COLUMN IF NOT EXISTS quota_bytes BIGINT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS quota_snapshots INTEGER NOT NULL DEFAULT 0;`,
+	// 3: the newest snapshot of a UID is looked up on every pull.
+	`CREATE INDEX IF NOT EXISTS axisgtd_uid_name_time ON axisgtd (uid_name, time DESC);`,
+	// 4: announce every change of a UID or its snapshots on axisgtd_changes,
+	// the head caches of all instances listen to it.
+	`CREATE OR REPLACE FUNCTION axisgtd_notify_uid() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			PERFORM pg_notify('axisgtd_changes', OLD.name);
+		ELSE
+			PERFORM pg_notify('axisgtd_changes', NEW.name);
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+	CREATE OR REPLACE FUNCTION axisgtd_notify_snapshot() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			PERFORM pg_notify('axisgtd_changes', OLD.uid_name);
+		ELSE
+			PERFORM pg_notify('axisgtd_changes', NEW.uid_name);
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS uid_notify ON UID;
+	CREATE TRIGGER uid_notify AFTER INSERT OR UPDATE OR DELETE ON UID
+		FOR EACH ROW EXECUTE PROCEDURE axisgtd_notify_uid();
+	DROP TRIGGER IF EXISTS axisgtd_notify ON axisgtd;
+	CREATE TRIGGER axisgtd_notify AFTER INSERT OR UPDATE OR DELETE ON axisgtd
+		FOR EACH ROW EXECUTE PROCEDURE axisgtd_notify_snapshot();`,
+	// 5: the read-only token of the calendar feed, NULL disables the feed.
+	// The feed is looked up by the token alone.
+	`ALTER TABLE UID ADD COLUMN IF NOT EXISTS read_token CHARACTER VARYING(64);
+	CREATE UNIQUE INDEX IF NOT EXISTS uid_read_token ON UID (read_token);`,
+	// 6: full-text index of the todolists for searching the history. A
+	// tsvector is limited to 1 MB, a todolist over it is indexed by its first
+	// 256 KiB rather than failing the insert.
+	`CREATE OR REPLACE FUNCTION axisgtd_search_vector(todolist TEXT) RETURNS tsvector AS $$
+	BEGIN
+		RETURN to_tsvector('simple', todolist);
+	EXCEPTION WHEN program_limit_exceeded THEN
+		RETURN to_tsvector('simple', left(todolist, 262144));
+	END;
+	$$ LANGUAGE plpgsql IMMUTABLE;
+	CREATE INDEX IF NOT EXISTS axisgtd_search ON axisgtd USING GIN (axisgtd_search_vector(todolist));`,
+	// 7: the device that stored a snapshot, NULL for older ones.
+	`ALTER TABLE axisgtd ADD COLUMN IF NOT EXISTS device CHARACTER VARYING(100);`,
+	// 8: the statistics of a UID, folded from its snapshots up to time. state
+	// is the JSON the server continues from on the next request.
+	`CREATE TABLE IF NOT EXISTS axisgtd_stats (
+		uid_name CHARACTER VARYING(100) PRIMARY KEY REFERENCES UID(name) ON DELETE CASCADE,
+		time BIGINT NOT NULL,
+		snapshots INTEGER NOT NULL,
+		state TEXT NOT NULL
+	);`,
+	// 9: webhooks, of a UID or global when uid_name is NULL, and the log of
+	// their deliveries. next_attempt is NULL once a delivery is finished. A
+	// delivery keeps the URL and secret it is sent with, so the ones a
+	// deleted UID takes its webhooks with still go out.
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id SERIAL PRIMARY KEY,
+		uid_name CHARACTER VARYING(100) REFERENCES UID(name) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		secret CHARACTER VARYING(64) NOT NULL,
+		events TEXT NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS webhooks_uid_name ON webhooks (uid_name);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id INTEGER REFERENCES webhooks(id) ON DELETE SET NULL,
+		url TEXT NOT NULL,
+		secret CHARACTER VARYING(64) NOT NULL,
+		global BOOLEAN NOT NULL,
+		event CHARACTER VARYING(50) NOT NULL,
+		payload TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		status CHARACTER VARYING(20) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_status INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		last_attempt BIGINT NOT NULL DEFAULT 0,
+		next_attempt BIGINT
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (next_attempt) WHERE next_attempt IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id DESC);`,
+	// 10: the password of CalDAV clients, NULL keeps them out.
+	`ALTER TABLE UID ADD COLUMN IF NOT EXISTS caldav_secret CHARACTER VARYING(64);`,
+	// 11: the token of the shared task list, separate from the read token so
+	// either can be revoked alone. NULL disables sharing.
+	`ALTER TABLE UID ADD COLUMN IF NOT EXISTS share_token CHARACTER VARYING(64);
+	CREATE UNIQUE INDEX IF NOT EXISTS uid_share_token ON UID (share_token);`,
+}
+
+// migrationLock is the pg_advisory_xact_lock key that keeps several
+// instances from migrating at the same time.
+const migrationLock = 0x41584953
+
+// LatestSchemaVersion is the version Migrate brings the database to.
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+// SchemaVersion returns the number of migrations applied to the database, 0
+// before the first one.
+func SchemaVersion() (int, error) {
+	var version int
+	err := db.QueryRow(`SELECT CASE WHEN to_regclass('schema_migrations') IS NULL THEN 0
+		ELSE (SELECT COALESCE(MAX(version), 0) FROM schema_migrations) END`).Scan(&version)
+	return version, err
+}
+
+// Migrate applies every pending migration, each in its own transaction, and
+// returns how many were applied.
+func Migrate() (int, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return 0, fmt.Errorf("error creating schema_migrations: %v", err)
+	}
+
+	applied := 0
+	for {
+		done, err := migrateNext()
+		if err != nil || done {
+			return applied, err
+		}
+		applied++
+	}
+}
+
+func migrateNext() (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return false, fmt.Errorf("error locking schema_migrations: %v", err)
+	}
+
+	var version int
+	err = tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return false, fmt.Errorf("error reading schema version: %v", err)
+	}
+	if version >= len(migrations) {
+		return true, tx.Commit()
+	}
+
+	if _, err = tx.Exec(migrations[version]); err != nil {
+		return false, fmt.Errorf("error applying migration %d: %v", version+1, err)
+	}
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`,
+		version+1, time.Now().UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("error recording migration %d: %v", version+1, err)
+	}
+	return false, tx.Commit()
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Routes registers the /api/v1 routes and the unversioned routes of earlier
+// releases, which stay available as deprecated aliases.
+func Routes(app *fiber.App) {
+	v1 := app.Group("/api/v1")
+
+	v1.Post("/ids", CreateID)
+	v1.Get("/ids", GetAllID)
+	v1.Get("/ids/:name", GetIDInfo)
+	v1.Patch("/ids/:name", UpdateID)
+	v1.Delete("/ids/:name", DeleteID)
+	v1.Put("/ids/:name/quota", AdminAuth, SetQuota)
+
+	v1.Get("/ids/:name/history", GetID)
+	v1.Get("/ids/:name/snapshots", GetSnapshots)
+	v1.Post("/ids/:name/snapshots", ObserveSync("push"), DecompressBody, SyncPost)
+	v1.Get("/ids/:name/snapshots/latest", ObserveSync("pull"), SyncGet)
+	v1.Get("/ids/:name/snapshots/:time", GetSnapshotByTime)
+	v1.Delete("/ids/:name/snapshots/:time", DeleteRecord)
+	v1.Post("/ids/:name/snapshots/:time/restore", RestoreRecord)
+
+	v1.Get("/ids/:name/tasks", ListTasks)
+	v1.Post("/ids/:name/tasks", AddTask)
+	v1.Get("/ids/:name/tasks/:id", GetTask)
+	v1.Patch("/ids/:name/tasks/:id", UpdateTask)
+	v1.Post("/ids/:name/tasks/:id/complete", CompleteTask)
+	v1.Get("/ids/:name/tasks/:id/history", TaskTimeline)
+	v1.Get("/ids/:name/search", SearchTasks)
+	v1.Get("/ids/:name/stats", Stats)
+
+	v1.Post("/ids/:name/read-token", CreateReadToken)
+	v1.Delete("/ids/:name/read-token", DeleteReadToken)
+	v1.Post("/ids/:name/share-token", CreateShareToken)
+	v1.Delete("/ids/:name/share-token", DeleteShareToken)
+
+	if config.Features.Export {
+		v1.Get("/ids/:name/export", ExportID)
+		v1.Get("/ids/:name/export.txt", ExportTodoTxt)
+		v1.Get("/export", AdminAuth, ExportAll)
+	}
+	if config.Features.Import {
+		v1.Post("/ids/:name/import", DecompressBody, ImportID)
+		v1.Post("/ids/:name/import.txt", DecompressBody, ImportTodoTxt)
+		v1.Post("/import", AdminAuth, DecompressBody, ImportAll)
+	}
+
+	if config.Features.Webhooks {
+		webhookRoutes(v1.Group("/ids/:name/webhooks"))
+		webhookRoutes(v1.Group("/webhooks", AdminAuth))
+	}
+
+	app.Get("/feeds/:token.ics", Calendar)
+	app.Get("/id/:name/calendar.ics", UIDCalendar)
+	// Before /share/:token, which would take the .md as part of the token.
+	app.Get("/share/:token.md", ShareMarkdown)
+	app.Get("/share/:token", ShareView)
+	app.Get("/id/:name/view.md", UIDShareMarkdown)
+	app.Get("/id/:name/view", UIDShareView)
+
+	if config.Features.CalDAV {
+		v1.Post("/ids/:name/caldav-secret", CreateDAVSecret)
+		v1.Delete("/ids/:name/caldav-secret", DeleteDAVSecret)
+
+		dav := app.Group("/dav/:name", DAVAuth)
+		for _, path := range []string{"/", "/tasks", "/tasks/:file"} {
+			dav.Options(path, DAVOptions)
+		}
+		dav.Add("PROPFIND", "/", PropfindHome)
+		dav.Add("PROPFIND", "/tasks", PropfindTasks)
+		dav.Add("PROPFIND", "/tasks/:file", PropfindTask)
+		dav.Add("REPORT", "/tasks", ReportTasks)
+		dav.Get("/tasks/:file", GetDAVTask)
+		dav.Put("/tasks/:file", PutDAVTask)
+		dav.Delete("/tasks/:file", DeleteDAVTask)
+	}
+
+	app.Put("/create", Deprecated("/api/v1/ids"), CreateID)
+	app.Get("/id/:name", Deprecated("/api/v1/ids/:name/history"), GetID)
+	app.Delete("/id/:name", Deprecated("/api/v1/ids/:name"), DeleteID)
+	app.Get("/ids", Deprecated("/api/v1/ids"), GetAllID)
+	app.Get("/status/:name", Deprecated("/api/v1/ids/:name"), ToggleStatus)
+	app.Get("/sync/:name", Deprecated("/api/v1/ids/:name/snapshots/latest"), ObserveSync("pull"), SyncGet)
+	app.Post("/sync/:name", Deprecated("/api/v1/ids/:name/snapshots"), ObserveSync("push"), DecompressBody, SyncPost)
+	app.Delete("/delete/:name/:time", Deprecated("/api/v1/ids/:name/snapshots/:time"), DeleteRecord)
+	app.Get("/id/:name/search", Deprecated("/api/v1/ids/:name/search"), SearchTasks)
+	app.Get("/id/:name/stats", Deprecated("/api/v1/ids/:name/stats"), Stats)
+	if config.Features.Export {
+		app.Get("/id/:name/export", Deprecated("/api/v1/ids/:name/export"), ExportID)
+		app.Get("/export", Deprecated("/api/v1/export"), AdminAuth, ExportAll)
+		app.Get("/id/:name/export.txt", Deprecated("/api/v1/ids/:name/export.txt"), ExportTodoTxt)
+	}
+	if config.Features.Import {
+		app.Post("/id/:name/import", Deprecated("/api/v1/ids/:name/import"), DecompressBody, ImportID)
+		app.Post("/import", Deprecated("/api/v1/import"), AdminAuth, DecompressBody, ImportAll)
+		app.Post("/id/:name/import.txt", Deprecated("/api/v1/ids/:name/import.txt"), DecompressBody, ImportTodoTxt)
+	}
+}
+
+// webhookRoutes registers the webhook routes of a UID, or the global ones
+// when the group has no :name.
+func webhookRoutes(hooks fiber.Router) {
+	hooks.Post("/", CreateWebhook)
+	hooks.Get("/", GetWebhooks)
+	hooks.Delete("/:id", DeleteWebhook)
+	hooks.Get("/:id/deliveries", GetWebhookDeliveries)
+	hooks.Post("/:id/deliveries/:delivery/replay", ReplayWebhookDelivery)
+}
+
+// Deprecated marks a legacy route with a Deprecation header and links its
+// /api/v1 successor, with the route parameters filled in.
+func Deprecated(successor string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		link := successor
+		for _, param := range c.Route().Params {
+			link = strings.Replace(link, ":"+param, c.Params(param), 1)
+		}
+		c.Set("Deprecation", "true")
+		c.Set(fiber.HeaderLink, "<"+link+`>; rel="successor-version"`)
+		return c.Next()
+	}
+}
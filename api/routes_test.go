@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDeprecated(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/ids", Deprecated("/api/v1/ids"), ok)
+	app.Delete("/delete/:name/:time", Deprecated("/api/v1/ids/:name/snapshots/:time"), ok)
+	app.Get("/id/:name/export.txt", Deprecated("/api/v1/ids/:name/export.txt"), ok)
+
+	tests := []struct {
+		method string
+		url    string
+		link   string
+	}{
+		{http.MethodGet, "/ids", "</api/v1/ids>; rel=\"successor-version\""},
+		{http.MethodDelete, "/delete/a/1725408000000", "</api/v1/ids/a/snapshots/1725408000000>; rel=\"successor-version\""},
+		{http.MethodGet, "/id/a/export.txt", "</api/v1/ids/a/export.txt>; rel=\"successor-version\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(tt.method, tt.url, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != 200 {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			if got := resp.Header.Get("Deprecation"); got != "true" {
+				t.Errorf("Deprecation = %q, want true", got)
+			}
+			if got := resp.Header.Get("Link"); got != tt.link {
+				t.Errorf("Link = %q, want %q", got, tt.link)
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	legacy := []string{
+		"PUT /create",
+		"GET /id/:name",
+		"DELETE /id/:name",
+		"GET /ids",
+		"GET /status/:name",
+		"GET /sync/:name",
+		"POST /sync/:name",
+		"DELETE /delete/:name/:time",
+		"GET /id/:name/search",
+		"GET /id/:name/stats",
+	}
+	export := []string{"GET /id/:name/export", "GET /export", "GET /id/:name/export.txt"}
+	imports := []string{"POST /id/:name/import", "POST /import", "POST /id/:name/import.txt"}
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{"features on", true},
+		{"features off", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Features.Export = tt.enabled
+			cfg.Features.Import = tt.enabled
+			useConfig(t, cfg)
+
+			app := fiber.New(fiber.Config{RequestMethods: append(fiber.DefaultMethods, DAVMethods...)})
+			Routes(app)
+			registered := map[string]bool{}
+			for _, route := range app.GetRoutes(true) {
+				registered[route.Method+" "+route.Path] = true
+			}
+
+			for _, route := range legacy {
+				if !registered[route] {
+					t.Errorf("%s is not registered", route)
+				}
+			}
+			for _, route := range append(export, imports...) {
+				if registered[route] != tt.enabled {
+					t.Errorf("%s registered = %v, want %v", route, registered[route], tt.enabled)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	maxSearchTerms = 8
+	maxSearchLimit = 500
+)
+
+// searchTerms splits a query into the lower-case words the index holds.
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchesTerms reports whether every term starts a word of the task's
+// title, notes, project or tags. The index matches the whole todolist, so
+// a snapshot it returns may hold no matching task.
+func matchesTerms(task TaskType, terms []string) bool {
+	words := searchTerms(task.Title + " " + task.Notes + " " + task.Project + " " + strings.Join(task.Tags, " "))
+	for _, term := range terms {
+		found := false
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// searchKey identifies a task across snapshots, by its title when the
+// todolist has no ids.
+func searchKey(task TaskType) string {
+	if task.ID != "" {
+		return "id:" + task.ID
+	}
+	return "title:" + strings.ToLower(strings.TrimSpace(task.Title))
+}
+
+// @Summary		Search the task history
+// @Description	Finds the tasks in every snapshot of a UID that have a word starting with each word of q, in the title, notes, project or tags. Each task lists the snapshots it appeared and disappeared in, so deleted tasks can be found and restored. Newest first.
+// @Tags			tasks
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Param			q		query		string	true	"Words to search for"
+// @Param			limit	query		int		false	"Maximum number of tasks, 50 by default"
+// @Success		200		{object}	SearchResultType
+// @Failure		400		{object}	ErrorType	"Invalid query"
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/search [get]
+func SearchTasks(c *fiber.Ctx) error {
+	terms := searchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		return badRequest("invalid_query", "q needs at least one word")
+	}
+	if len(terms) > maxSearchTerms {
+		return badRequest("invalid_query", "q may have at most %d words", maxSearchTerms)
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > maxSearchLimit {
+		return badRequest("invalid_limit", "limit must be between 1 and %d", maxSearchLimit)
+	}
+
+	uid, err := GetUID(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Search Failed")
+	}
+	if !uid.Status {
+		return uidDisabled(uid.Name)
+	}
+
+	result := SearchResultType{Query: c.Query("q"), Tasks: []SearchHitType{}}
+	times, _, err := SnapshotTimes(uid.Name)
+	if err != nil {
+		return storeError(err, "Search Failed")
+	}
+	if len(times) == 0 {
+		return c.JSON(result)
+	}
+	positions := make(map[int64]int, len(times))
+	for i, t := range times {
+		positions[t] = i
+	}
+
+	// A task disappeared in the snapshot after the last one it was in, which
+	// the index skips when no task in it matches anymore.
+	hits := map[string]*SearchHitType{}
+	var previous map[string]bool
+	previousPos := -1
+	disappeared := func(keys map[string]bool, pos int) {
+		for key := range keys {
+			hits[key].Changes = append(hits[key].Changes, SearchChangeType{Time: times[pos], Change: "disappeared"})
+		}
+	}
+
+	err = SearchSnapshots(uid.Name, terms, times[len(times)-1], func(t int64, _ string, todolist string) error {
+		pos, ok := positions[t]
+		if !ok {
+			return nil
+		}
+		list, err := parseTaskList(todolist)
+		if err != nil {
+			return nil
+		}
+		current := map[string]bool{}
+		var matched []TaskType
+		for i := range list.items {
+			task := list.task(i)
+			if key := searchKey(task); !current[key] && matchesTerms(task, terms) {
+				current[key] = true
+				matched = append(matched, task)
+			}
+		}
+		if len(matched) == 0 {
+			return nil
+		}
+		result.Snapshots++
+
+		if previousPos == pos-1 {
+			gone := map[string]bool{}
+			for key := range previous {
+				if !current[key] {
+					gone[key] = true
+				}
+			}
+			disappeared(gone, pos)
+		} else {
+			disappeared(previous, previousPos+1)
+		}
+		for _, task := range matched {
+			key := searchKey(task)
+			hit := hits[key]
+			if hit == nil {
+				hit = &SearchHitType{FirstSeen: t}
+				hits[key] = hit
+			}
+			if !previous[key] || previousPos != pos-1 {
+				hit.Changes = append(hit.Changes, SearchChangeType{Time: t, Change: "appeared"})
+			}
+			hit.Task = task
+			hit.LastSeen = t
+		}
+		previous, previousPos = current, pos
+		return nil
+	})
+	if err != nil {
+		return storeError(err, "Search Failed")
+	}
+	if previousPos == len(times)-1 {
+		for key := range previous {
+			hits[key].Present = true
+		}
+	} else if previousPos >= 0 {
+		disappeared(previous, previousPos+1)
+	}
+
+	for _, hit := range hits {
+		result.Tasks = append(result.Tasks, *hit)
+	}
+	sort.Slice(result.Tasks, func(i, j int) bool {
+		a, b := result.Tasks[i], result.Tasks[j]
+		if a.LastSeen != b.LastSeen {
+			return a.LastSeen > b.LastSeen
+		}
+		return a.FirstSeen > b.FirstSeen
+	})
+	if len(result.Tasks) > limit {
+		result.Tasks = result.Tasks[:limit]
+	}
+	return c.JSON(result)
+}
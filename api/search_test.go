@@ -0,0 +1,47 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"", []string{}},
+		{"  ", []string{}},
+		{"Bank", []string{"bank"}},
+		{"call the BANK", []string{"call", "the", "bank"}},
+		{"e-mail, re:invoice #42", []string{"e", "mail", "re", "invoice", "42"}},
+		{"Grüße café", []string{"grüße", "café"}},
+		{"東京 meeting", []string{"東京", "meeting"}},
+	}
+	for _, tt := range tests {
+		if got := searchTerms(tt.q); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesTerms(t *testing.T) {
+	task := TaskType{Title: "Call the bank", Notes: "about the e-mail", Project: "Finance", Tags: []string{"phone"}}
+	tests := []struct {
+		q    string
+		want bool
+	}{
+		{"", true},
+		{"bank", true},
+		{"BA", true},
+		{"call bank", true},
+		{"fin pho mail", true},
+		{"ank", false},
+		{"bank loan", false},
+	}
+	for _, tt := range tests {
+		if got := matchesTerms(task, searchTerms(tt.q)); got != tt.want {
+			t.Errorf("matchesTerms(%q) = %t, want %t", tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// shareGroup is one project of the shared task list.
+type shareGroup struct {
+	Name  string
+	Tasks []shareTask
+}
+
+type shareTask struct {
+	Title   string
+	Notes   string
+	Done    bool
+	Due     string
+	Overdue bool
+	Tags    []string
+}
+
+// shareDue formats a due date, without the time for all-day dates.
+func shareDue(ms int64) string {
+	t := time.UnixMilli(ms).UTC()
+	if ms%(24*60*60*1000) == 0 {
+		return t.Format("Mon 2 Jan 2006")
+	}
+	return t.Format("Mon 2 Jan 2006 15:04 UTC")
+}
+
+// shareGroups groups the matching tasks by project, projects by name and
+// the tasks without one last. Within a project tasks are ordered by due
+// date, the ones without at the end.
+func shareGroups(list *taskList, filter taskFilter, now time.Time) []shareGroup {
+	byProject := map[string][]TaskType{}
+	for i := range list.items {
+		task := list.task(i)
+		if filter.match(task) {
+			byProject[task.Project] = append(byProject[task.Project], task)
+		}
+	}
+
+	projects := make([]string, 0, len(byProject))
+	for project := range byProject {
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if (projects[i] == "") != (projects[j] == "") {
+			return projects[j] == ""
+		}
+		return strings.ToLower(projects[i]) < strings.ToLower(projects[j])
+	})
+
+	groups := make([]shareGroup, 0, len(projects))
+	for _, project := range projects {
+		tasks := byProject[project]
+		sort.SliceStable(tasks, func(i, j int) bool {
+			if (tasks[i].Due == 0) != (tasks[j].Due == 0) {
+				return tasks[j].Due == 0
+			}
+			return tasks[i].Due < tasks[j].Due
+		})
+		group := shareGroup{Name: project}
+		if project == "" {
+			group.Name = "No project"
+		}
+		for _, task := range tasks {
+			shared := shareTask{Title: task.Title, Notes: task.Notes, Done: task.Done, Tags: task.Tags}
+			if task.Due != 0 {
+				shared.Due = shareDue(task.Due)
+				shared.Overdue = !task.Done && task.Due < now.UnixMilli()
+			}
+			group.Tasks = append(group.Tasks, shared)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// @Summary		Create a share token
+// @Description	Creates or replaces the token of the shared task list. It is separate from the read token of the calendar feed, so either can be revoked alone. Replacing it stops the old links.
+// @Tags			share
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Success		200		{object}	ShareTokenType
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/share-token [post]
+func CreateShareToken(c *fiber.Ctx) error {
+	token, err := GenerateRandomHex(32)
+	if err != nil {
+		return err
+	}
+	err = SetShareToken(c.Params("name"), token)
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Create share token Failed")
+	}
+	return c.JSON(ShareTokenType{
+		Token:    token,
+		View:     fmt.Sprintf("%s/share/%s", c.BaseURL(), token),
+		Markdown: fmt.Sprintf("%s/share/%s.md", c.BaseURL(), token),
+	})
+}
+
+// @Summary		Revoke the share token
+// @Description	Removes the share token of a UID, which ends the sharing.
+// @Tags			share
+// @Param			name	path		string	true	"UID Name"
+// @Success		204		"Revoked"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/share-token [delete]
+func DeleteShareToken(c *fiber.Ctx) error {
+	err := SetShareToken(c.Params("name"), "")
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Revoke share token Failed")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// sharedTasks loads the grouped tasks of the newest snapshot of the UID the
+// share token belongs to. done is false when a 304 was sent instead.
+func sharedTasks(c *fiber.Ctx, token string, lookup func(token string) (string, error)) (headSnapshot, []shareGroup, bool, error) {
+	filter, err := parseTaskFilter(c)
+	if err != nil {
+		return headSnapshot{}, nil, false, badRequest("invalid_filter", "%v", err)
+	}
+	if c.Query("status") == "" {
+		filter.status = "open"
+	}
+	head, list, err := tokenTasks(token, lookup)
+	if err != nil {
+		return head, nil, false, err
+	}
+
+	// The token is in the URL, it must not leak to the links on the page.
+	c.Set("Referrer-Policy", "no-referrer")
+	c.Set("X-Robots-Tag", "noindex")
+	current := head.revision()
+	setRevisionHeaders(c, current)
+	if notModified(c, current) {
+		return head, nil, false, c.SendStatus(fiber.StatusNotModified)
+	}
+	return head, shareGroups(list, filter, time.Now()), true, nil
+}
+
+// @Summary		Shared task list
+// @Description	Renders the open tasks of the newest snapshot as a read-only HTML page, grouped by project. Takes the filters of the task list, status=all also shows done tasks.
+// @Tags			share
+// @Produce		html
+// @Param			token	path		string	true	"Share token"
+// @Param			status	query		string	false	"open (default), done or all"
+// @Param			tag		query		string	false	"Only tasks with this tag"
+// @Param			project	query		string	false	"Only tasks of this project"
+// @Success		200		{string}	string		"HTML page"
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"Unknown share token or no records available"
+// @Failure		422		{object}	ErrorType	"The todolist format is not understood"
+// @Router			/share/{token} [get]
+func ShareView(c *fiber.Ctx) error {
+	return shareView(c, c.Params("token"), UIDByShareToken)
+}
+
+// @Summary		Shared task list of a UID
+// @Description	The shared HTML page under the UID name, for links made with it. The share token is still required, without it or with another UID's token the answer is the same 404 as for an unknown token.
+// @Tags			share
+// @Produce		html
+// @Param			name	path		string	true	"UID Name"
+// @Param			token	query		string	true	"Share token"
+// @Param			status	query		string	false	"open (default), done or all"
+// @Param			tag		query		string	false	"Only tasks with this tag"
+// @Param			project	query		string	false	"Only tasks of this project"
+// @Success		200		{string}	string		"HTML page"
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"Unknown share token or no records available"
+// @Failure		422		{object}	ErrorType	"The todolist format is not understood"
+// @Router			/id/{name}/view [get]
+func UIDShareView(c *fiber.Ctx) error {
+	return shareView(c, c.Query("token"), tokenOf(c.Params("name"), UIDByShareToken))
+}
+
+func shareView(c *fiber.Ctx, token string, lookup func(token string) (string, error)) error {
+	head, groups, ok, err := sharedTasks(c, token, lookup)
+	if err != nil || !ok {
+		return err
+	}
+	return c.Render("share", fiber.Map{
+		"Title":   "Tasks",
+		"Updated": time.UnixMilli(head.Time).UTC().Format("Mon 2 Jan 2006 15:04 UTC"),
+		"Groups":  groups,
+	})
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`)
+
+// @Summary		Shared task list as Markdown
+// @Description	Renders the same list as the shared HTML page as Markdown, with task list items.
+// @Tags			share
+// @Produce		plain
+// @Param			token	path		string	true	"Share token"
+// @Param			status	query		string	false	"open (default), done or all"
+// @Param			tag		query		string	false	"Only tasks with this tag"
+// @Param			project	query		string	false	"Only tasks of this project"
+// @Success		200		{string}	string		"Markdown"
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"Unknown share token or no records available"
+// @Failure		422		{object}	ErrorType	"The todolist format is not understood"
+// @Router			/share/{token}.md [get]
+func ShareMarkdown(c *fiber.Ctx) error {
+	return shareMarkdown(c, c.Params("token"), UIDByShareToken)
+}
+
+// @Summary		Shared task list of a UID as Markdown
+// @Description	The shared Markdown list under the UID name. The share token is still required, as for the HTML page.
+// @Tags			share
+// @Produce		plain
+// @Param			name	path		string	true	"UID Name"
+// @Param			token	query		string	true	"Share token"
+// @Param			status	query		string	false	"open (default), done or all"
+// @Param			tag		query		string	false	"Only tasks with this tag"
+// @Param			project	query		string	false	"Only tasks of this project"
+// @Success		200		{string}	string		"Markdown"
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"Unknown share token or no records available"
+// @Failure		422		{object}	ErrorType	"The todolist format is not understood"
+// @Router			/id/{name}/view.md [get]
+func UIDShareMarkdown(c *fiber.Ctx) error {
+	return shareMarkdown(c, c.Query("token"), tokenOf(c.Params("name"), UIDByShareToken))
+}
+
+func shareMarkdown(c *fiber.Ctx, token string, lookup func(token string) (string, error)) error {
+	head, groups, ok, err := sharedTasks(c, token, lookup)
+	if err != nil || !ok {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+	return c.SendString(writeShareMarkdown(head.Time, groups))
+}
+
+// writeShareMarkdown renders the groups as a Markdown list. Titles, project
+// names and notes are escaped so they show as written rather than as
+// formatting or HTML.
+func writeShareMarkdown(updated int64, groups []shareGroup) string {
+	var b strings.Builder
+	b.WriteString("# Tasks\n\n")
+	b.WriteString("_Updated " + time.UnixMilli(updated).UTC().Format("Mon 2 Jan 2006 15:04 UTC") + "_\n")
+	for _, group := range groups {
+		b.WriteString("\n## " + markdownEscaper.Replace(group.Name) + "\n\n")
+		for _, task := range group.Tasks {
+			if task.Done {
+				b.WriteString("- [x] ")
+			} else {
+				b.WriteString("- [ ] ")
+			}
+			b.WriteString(markdownEscaper.Replace(strings.Join(strings.Fields(task.Title), " ")))
+			if task.Due != "" {
+				b.WriteString(" — due " + task.Due)
+				if task.Overdue {
+					b.WriteString(" **(overdue)**")
+				}
+			}
+			for _, tag := range task.Tags {
+				b.WriteString(" `" + strings.ReplaceAll(tag, "`", "'") + "`")
+			}
+			b.WriteString("\n")
+			for _, line := range strings.Split(strings.TrimSpace(task.Notes), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					b.WriteString("  " + markdownEscaper.Replace(line) + "\n")
+				}
+			}
+		}
+	}
+	if len(groups) == 0 {
+		b.WriteString("\nNo tasks.\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestShareGroups(t *testing.T) {
+	list, err := parseTaskList(`[
+		{"id":1,"title":"no project, no due"},
+		{"id":2,"title":"work, later","project":"work","due":1725300000000},
+		{"id":3,"title":"home","project":"Home"},
+		{"id":4,"title":"work, no due","project":"work"},
+		{"id":5,"title":"work, sooner","project":"work","due":1725100000000},
+		{"id":6,"title":"done","project":"Archive","done":true},
+		{"id":7,"title":"no project, due","due":1725235200000}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.UnixMilli(1725200000000)
+
+	got := shareGroups(list, taskFilter{status: "open"}, now)
+	var names []string
+	titles := map[string][]string{}
+	for _, group := range got {
+		names = append(names, group.Name)
+		for _, task := range group.Tasks {
+			titles[group.Name] = append(titles[group.Name], task.Title)
+		}
+	}
+	// Projects by name regardless of case, the tasks without one last.
+	if want := []string{"Home", "work", "No project"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("groups = %q, want %q", names, want)
+	}
+	// By due date, the ones without at the end.
+	if want := []string{"work, sooner", "work, later", "work, no due"}; !reflect.DeepEqual(titles["work"], want) {
+		t.Errorf("tasks of work = %q, want %q", titles["work"], want)
+	}
+	if want := []string{"no project, due", "no project, no due"}; !reflect.DeepEqual(titles["No project"], want) {
+		t.Errorf("tasks without a project = %q, want %q", titles["No project"], want)
+	}
+
+	work := got[1].Tasks
+	if !work[0].Overdue || work[0].Due != "Sat 31 Aug 2024 10:26 UTC" {
+		t.Errorf("sooner = %+v, want overdue with its time", work[0])
+	}
+	if work[1].Overdue {
+		t.Errorf("later = %+v, want not overdue", work[1])
+	}
+	if due := got[2].Tasks[0].Due; due != "Mon 2 Sep 2024" {
+		t.Errorf("all-day due = %q, want the date alone", due)
+	}
+
+	if all := shareGroups(list, taskFilter{status: "all"}, now); len(all) != 4 || all[0].Name != "Archive" {
+		t.Errorf("status=all groups = %+v, want Archive first", all)
+	}
+}
+
+func TestMarkdownEscaper(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"*bold* _it_", `\*bold\* \_it\_`},
+		{"[link](https://example.com)", `\[link\](https://example.com)`},
+		{"<script>", `\<script\>`},
+		{"# heading | cell", `\# heading \| cell`},
+		{"`code` and \\", "\\`code\\` and \\\\"},
+	}
+	for _, tt := range tests {
+		if got := markdownEscaper.Replace(tt.in); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteShareMarkdown(t *testing.T) {
+	groups := []shareGroup{{
+		Name: "A_B",
+		Tasks: []shareTask{
+			{Title: "Buy  *milk*\n", Notes: "line one\n\n  <b>two</b>  ", Due: "Mon 2 Sep 2024", Overdue: true, Tags: []string{"sh`op"}},
+			{Title: "Done", Done: true},
+		},
+	}}
+	got := writeShareMarkdown(1725235200000, groups)
+	want := "# Tasks\n\n" +
+		"_Updated Mon 2 Sep 2024 00:00 UTC_\n" +
+		"\n## A\\_B\n\n" +
+		"- [ ] Buy \\*milk\\* — due Mon 2 Sep 2024 **(overdue)** `sh'op`\n" +
+		"  line one\n" +
+		"  \\<b\\>two\\</b\\>\n" +
+		"- [x] Done\n"
+	if got != want {
+		t.Errorf("markdown =\n%s\nwant\n%s", got, want)
+	}
+	if got := writeShareMarkdown(1725235200000, nil); !strings.HasSuffix(got, "\nNo tasks.\n") {
+		t.Errorf("markdown of no groups = %q", got)
+	}
+}
+
+// Without a token the routes under the UID name are refused before the
+// database is asked.
+func TestUIDShareRoutes(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/id/:name/view.md", UIDShareMarkdown)
+	app.Get("/id/:name/view", UIDShareView)
+
+	for _, url := range []string{"/id/abc/view", "/id/abc/view.md", "/id/abc/view?token="} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body ErrorType
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != 404 || body.Code != "invalid_token" {
+			t.Errorf("GET %s = %d %+v, want 404 invalid_token", url, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	maxStatsDays  = 366
+	maxStatsWeeks = 260
+)
+
+const dayMillis = 24 * 60 * 60 * 1000
+
+// statsState is what the statistics are computed from. It is folded from
+// the snapshots one at a time and cached in axisgtd_stats, so a request only
+// reads the snapshots stored since the last one.
+type statsState struct {
+	// Tasks holds every task ever seen by its searchKey.
+	Tasks   map[string]*statsTask   `json:"tasks"`
+	Days    map[string]*statsDay    `json:"days"`
+	Devices map[string]*statsDevice `json:"devices"`
+	// Completions counts the completions with a known creation time and
+	// CompletionTime sums the milliseconds they took.
+	Completions    int   `json:"completions"`
+	CompletionTime int64 `json:"completion_time"`
+	Completed      int   `json:"completed"`
+	CompletedLate  int   `json:"completed_late"`
+}
+
+type statsTask struct {
+	Created int64 `json:"c"`
+	Due     int64 `json:"d,omitempty"`
+	Done    bool  `json:"x,omitempty"`
+	// Present tells whether the newest snapshot folded in has the task.
+	Present bool `json:"p,omitempty"`
+}
+
+type statsDay struct {
+	Created   int `json:"c,omitempty"`
+	Completed int `json:"x,omitempty"`
+	Syncs     int `json:"s,omitempty"`
+}
+
+type statsDevice struct {
+	Snapshots int   `json:"n"`
+	First     int64 `json:"f"`
+	Last      int64 `json:"l"`
+}
+
+func newStatsState() *statsState {
+	return &statsState{
+		Tasks:   map[string]*statsTask{},
+		Days:    map[string]*statsDay{},
+		Devices: map[string]*statsDevice{},
+	}
+}
+
+// statsDate is the UTC day of a time, like 2024-09-02.
+func statsDate(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format(time.DateOnly)
+}
+
+func (s *statsState) day(ms int64) *statsDay {
+	date := statsDate(ms)
+	d := s.Days[date]
+	if d == nil {
+		d = new(statsDay)
+		s.Days[date] = d
+	}
+	return d
+}
+
+// fold adds one snapshot. A task is created when it first appears and
+// completed whenever it turns done, at its own created and completed times
+// if it has them. A todolist the server doesn't understand only counts as a
+// sync.
+func (s *statsState) fold(t int64, device string, todolist string) {
+	s.day(t).Syncs++
+	d := s.Devices[device]
+	if d == nil {
+		d = &statsDevice{First: t}
+		s.Devices[device] = d
+	}
+	d.Snapshots++
+	d.Last = t
+
+	list, err := parseTaskList(todolist)
+	if err != nil {
+		return
+	}
+	for _, task := range s.Tasks {
+		task.Present = false
+	}
+	for i := range list.items {
+		item := list.task(i)
+		key := searchKey(item)
+		if key == "title:" {
+			continue
+		}
+		task := s.Tasks[key]
+		if task == nil {
+			task = &statsTask{Created: item.CreatedAt}
+			if task.Created == 0 {
+				task.Created = t
+			}
+			s.Tasks[key] = task
+			s.day(task.Created).Created++
+		} else if task.Present {
+			// The same task twice in one todolist.
+			continue
+		}
+
+		if item.Done && !task.Done {
+			completed := item.CompletedAt
+			if completed == 0 {
+				completed = t
+			}
+			s.day(completed).Completed++
+			s.Completed++
+			if completed >= task.Created {
+				s.Completions++
+				s.CompletionTime += completed - task.Created
+			}
+			if item.Due != 0 && completed > item.Due {
+				s.CompletedLate++
+			}
+		}
+		task.Done = item.Done
+		task.Due = item.Due
+		task.Present = true
+	}
+}
+
+// loadStats returns the statistics state of a UID brought up to date with
+// its newest snapshot, and the number of snapshots. The cache is rebuilt
+// when snapshots it already covers were deleted or added since.
+func loadStats(uidName string) (*statsState, int, error) {
+	state := newStatsState()
+	until, snapshots, raw, err := GetStatsCache(uidName)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, err
+	}
+	cached := err == nil
+	if cached {
+		count, err := CountSnapshots(uidName, until)
+		if err != nil {
+			return nil, 0, err
+		}
+		cached = count == snapshots && json.Unmarshal([]byte(raw), state) == nil
+	}
+	if !cached {
+		state = newStatsState()
+		until, snapshots = math.MinInt64, 0
+	}
+
+	folded := 0
+	err = WalkSnapshots(uidName, until, func(t int64, device string, todolist string) error {
+		state.fold(t, device, todolist)
+		until = t
+		folded++
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	snapshots += folded
+
+	if folded > 0 || !cached && snapshots > 0 {
+		raw, err := json.Marshal(state)
+		if err == nil {
+			err = SaveStatsCache(uidName, until, snapshots, string(raw))
+		}
+		if err != nil {
+			slog.Warn("stats: error caching statistics", "uid", uidName, "error", err)
+		}
+	}
+	return state, snapshots, nil
+}
+
+// report turns the state into the statistics of the last days and weeks
+// before now.
+func (s *statsState) report(snapshots int, days int, weeks int, now time.Time) StatsType {
+	stats := StatsType{
+		Snapshots:     snapshots,
+		Completed:     s.Completed,
+		CompletedLate: s.CompletedLate,
+		Days:          make([]StatsPeriodType, 0, days),
+		Weeks:         make([]StatsPeriodType, 0, weeks),
+		Devices:       make([]StatsDeviceType, 0, len(s.Devices)),
+	}
+	if s.Completions > 0 {
+		stats.AverageCompletion = s.CompletionTime / int64(s.Completions)
+	}
+	for _, task := range s.Tasks {
+		if task.Present && !task.Done {
+			stats.Open++
+			if task.Due != 0 && task.Due < now.UnixMilli() {
+				stats.Overdue++
+			}
+		}
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	period := func(start time.Time, length int) StatsPeriodType {
+		p := StatsPeriodType{Start: start.Format(time.DateOnly)}
+		for i := 0; i < length; i++ {
+			if d := s.Days[start.AddDate(0, 0, i).Format(time.DateOnly)]; d != nil {
+				p.Created += d.Created
+				p.Completed += d.Completed
+				p.Syncs += d.Syncs
+			}
+		}
+		return p
+	}
+	for i := days - 1; i >= 0; i-- {
+		stats.Days = append(stats.Days, period(today.AddDate(0, 0, -i), 1))
+	}
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	for i := weeks - 1; i >= 0; i-- {
+		stats.Weeks = append(stats.Weeks, period(monday.AddDate(0, 0, -7*i), 7))
+	}
+
+	for name, d := range s.Devices {
+		device := StatsDeviceType{Device: name, Snapshots: d.Snapshots, FirstSync: d.First, LastSync: d.Last}
+		activeDays := (d.Last-d.First)/dayMillis + 1
+		device.PerDay = float64(d.Snapshots) / float64(activeDays)
+		stats.Devices = append(stats.Devices, device)
+	}
+	sort.Slice(stats.Devices, func(i, j int) bool {
+		a, b := stats.Devices[i], stats.Devices[j]
+		if a.LastSync != b.LastSync {
+			return a.LastSync > b.LastSync
+		}
+		return a.Device < b.Device
+	})
+	return stats
+}
+
+// @Summary		Productivity statistics
+// @Description	Computes statistics from the snapshots of a UID: tasks created and completed per day and week, the average time to complete a task, overdue tasks and how often each device syncs. Days are UTC and weeks start on Monday. The statistics are cached and only updated with the snapshots stored since the last request.
+// @Tags			tasks
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Param			days	query		int		false	"Number of days, 30 by default"
+// @Param			weeks	query		int		false	"Number of weeks, 12 by default"
+// @Success		200		{object}	StatsType
+// @Failure		400		{object}	ErrorType	"Invalid days or weeks"
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/stats [get]
+func Stats(c *fiber.Ctx) error {
+	days := c.QueryInt("days", 30)
+	if days < 0 || days > maxStatsDays {
+		return badRequest("invalid_days", "days must be between 0 and %d", maxStatsDays)
+	}
+	weeks := c.QueryInt("weeks", 12)
+	if weeks < 0 || weeks > maxStatsWeeks {
+		return badRequest("invalid_weeks", "weeks must be between 0 and %d", maxStatsWeeks)
+	}
+
+	uid, err := GetUID(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Stats Failed")
+	}
+	if !uid.Status {
+		return uidDisabled(uid.Name)
+	}
+
+	state, snapshots, err := loadStats(uid.Name)
+	if err != nil {
+		return storeError(err, "Stats Failed")
+	}
+	return c.JSON(state.report(snapshots, days, weeks, time.Now()))
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// monday is 2024-09-02, a Monday, at midnight UTC.
+const monday = 1725235200000
+
+const hour = int64(time.Hour / time.Millisecond)
+
+type statsSnapshot struct {
+	time     int64
+	device   string
+	todolist string
+}
+
+func foldStats(snapshots []statsSnapshot) *statsState {
+	s := newStatsState()
+	for _, snapshot := range snapshots {
+		s.fold(snapshot.time, snapshot.device, snapshot.todolist)
+	}
+	return s
+}
+
+func TestStatsFold(t *testing.T) {
+	now := time.UnixMilli(monday + 7*dayMillis)
+
+	tests := []struct {
+		name      string
+		snapshots []statsSnapshot
+		want      StatsType
+	}{
+		{
+			name: "completed",
+			snapshots: []statsSnapshot{
+				{monday + hour, "", `[{"id":1,"title":"a"}]`},
+				{monday + 3*hour, "", `[{"id":1,"title":"a","done":true}]`},
+			},
+			want: StatsType{Completed: 1, AverageCompletion: 2 * hour},
+		},
+		{
+			name: "own times",
+			snapshots: []statsSnapshot{
+				{monday + 5*hour, "", `[{"id":1,"title":"a","createdAt":1725235200000,"done":true,"completedAt":1725249600000}]`},
+			},
+			want: StatsType{Completed: 1, AverageCompletion: 4 * hour},
+		},
+		{
+			name: "reopened",
+			snapshots: []statsSnapshot{
+				{monday, "", `[{"id":1,"title":"a","done":true}]`},
+				{monday + hour, "", `[{"id":1,"title":"a","done":false}]`},
+				{monday + 3*hour, "", `[{"id":1,"title":"a","done":true}]`},
+			},
+			want: StatsType{Completed: 2, AverageCompletion: 3 * hour / 2},
+		},
+		{
+			name: "late",
+			snapshots: []statsSnapshot{
+				{monday, "", `[{"id":1,"title":"a","due":1725238800000}]`},
+				{monday + 2*hour, "", `[{"id":1,"title":"a","due":1725238800000,"done":true}]`},
+			},
+			want: StatsType{Completed: 1, CompletedLate: 1, AverageCompletion: 2 * hour},
+		},
+		{
+			name: "open and overdue",
+			snapshots: []statsSnapshot{
+				{monday, "", `[{"id":1,"title":"a","due":1725238800000},{"id":2,"title":"b","due":1893456000000},{"id":3,"title":"c"}]`},
+			},
+			want: StatsType{Open: 3, Overdue: 1},
+		},
+		{
+			name: "deleted",
+			snapshots: []statsSnapshot{
+				{monday, "", `[{"id":1,"title":"a"},{"id":2,"title":"b"}]`},
+				{monday + hour, "", `[{"id":2,"title":"b"}]`},
+			},
+			want: StatsType{Open: 1},
+		},
+		{
+			name: "twice in one todolist",
+			snapshots: []statsSnapshot{
+				{monday, "", `[{"id":1,"title":"a"},{"id":1,"title":"a","done":true}]`},
+			},
+			want: StatsType{Open: 1},
+		},
+		{
+			name: "without ids",
+			snapshots: []statsSnapshot{
+				{monday, "", `[{"title":"Call"},{"title":""}]`},
+				{monday + hour, "", `[{"title":" call ","done":true}]`},
+			},
+			want: StatsType{Completed: 1, AverageCompletion: hour},
+		},
+		// A todolist the server doesn't understand leaves the tasks as
+		// they were.
+		{
+			name: "not understood",
+			snapshots: []statsSnapshot{
+				{monday, "", `[{"id":1,"title":"a"}]`},
+				{monday + hour, "", `"tasks"`},
+			},
+			want: StatsType{Open: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := foldStats(tt.snapshots).report(len(tt.snapshots), 0, 0, now)
+			tt.want.Snapshots = len(tt.snapshots)
+			got.Days, got.Weeks, got.Devices = nil, nil, nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("report = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsReport(t *testing.T) {
+	s := foldStats([]statsSnapshot{
+		{monday + hour, "laptop", `[{"id":1,"title":"a"}]`},
+		{monday + 7*dayMillis, "phone", `[{"id":1,"title":"a"},{"id":2,"title":"b"}]`},
+		{monday + 8*dayMillis, "phone", `[{"id":1,"title":"a","done":true},{"id":2,"title":"b"}]`},
+		{monday + 8*dayMillis + hour, "laptop", `[{"id":1,"title":"a","done":true},{"id":2,"title":"b"}]`},
+	})
+	// A Tuesday, so the current week has started the day before.
+	now := time.UnixMilli(monday + 8*dayMillis + 12*hour)
+	got := s.report(4, 3, 2, now)
+
+	wantDays := []StatsPeriodType{
+		{Start: "2024-09-08"},
+		{Start: "2024-09-09", Created: 1, Syncs: 1},
+		{Start: "2024-09-10", Completed: 1, Syncs: 2},
+	}
+	if !reflect.DeepEqual(got.Days, wantDays) {
+		t.Errorf("days = %+v, want %+v", got.Days, wantDays)
+	}
+	wantWeeks := []StatsPeriodType{
+		{Start: "2024-09-02", Created: 1, Syncs: 1},
+		{Start: "2024-09-09", Created: 1, Completed: 1, Syncs: 3},
+	}
+	if !reflect.DeepEqual(got.Weeks, wantWeeks) {
+		t.Errorf("weeks = %+v, want %+v", got.Weeks, wantWeeks)
+	}
+	wantDevices := []StatsDeviceType{
+		{Device: "laptop", Snapshots: 2, FirstSync: monday + hour, LastSync: monday + 8*dayMillis + hour, PerDay: 2.0 / 9},
+		{Device: "phone", Snapshots: 2, FirstSync: monday + 7*dayMillis, LastSync: monday + 8*dayMillis, PerDay: 1},
+	}
+	if !reflect.DeepEqual(got.Devices, wantDevices) {
+		t.Errorf("devices = %+v, want %+v", got.Devices, wantDevices)
+	}
+	if got.Open != 1 || got.Completed != 1 || got.AverageCompletion != 8*dayMillis-hour {
+		t.Errorf("report = %+v", got)
+	}
+
+	if empty := newStatsState().report(0, 1, 1, now); len(empty.Days) != 1 || len(empty.Weeks) != 1 || empty.Devices == nil {
+		t.Errorf("report of no snapshots = %+v", empty)
+	}
+}
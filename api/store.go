@@ -0,0 +1,683 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The functions in this file are the storage layer shared by the HTTP
+// handlers and the command line.
+
+func CreateUID() (string, error) {
+	uidName, err := GetName()
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO UID (name, status) VALUES ($1, $2)`
+	_, err = db.Exec(query, uidName, true)
+	if err != nil {
+		return "", err
+	}
+	return uidName, nil
+}
+
+// idsQuery selects a UID with the usage of its snapshots, in the order
+// scanID reads them.
+const idsQuery = `
+	SELECT
+		UID.id,
+		UID.name,
+		UID.status,
+		COUNT(axisgtd.uid_name) AS axisgtd_count,
+		COALESCE(SUM(octet_length(axisgtd.todolist) + octet_length(axisgtd.config)), 0),
+		COALESCE(MAX(octet_length(axisgtd.todolist) + octet_length(axisgtd.config)), 0),
+		COALESCE(MAX(axisgtd.time), 0),
+		UID.quota_bytes,
+		UID.quota_snapshots
+	FROM
+		UID
+	LEFT JOIN axisgtd ON UID.name = axisgtd.uid_name`
+
+const idsGroupBy = `
+	GROUP BY
+		UID.id, UID.name, UID.status, UID.quota_bytes, UID.quota_snapshots`
+
+func scanID(row interface{ Scan(...any) error }) (IDSType, error) {
+	var id IDSType
+	err := row.Scan(&id.Id, &id.Name, &id.Status, &id.Count,
+		&id.Bytes, &id.LargestBytes, &id.LastSync,
+		&id.Quota.MaxBytes, &id.Quota.MaxSnapshots)
+	if id.Quota.MaxBytes == 0 {
+		id.Quota.MaxBytes = int64(config.Limits.MaxUIDBytes)
+	}
+	if id.Quota.MaxSnapshots == 0 {
+		id.Quota.MaxSnapshots = config.Limits.MaxUIDSnapshots
+	}
+	return id, err
+}
+
+func ListIDs() ([]IDSType, error) {
+	rows, err := db.Query(idsQuery + idsGroupBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []IDSType
+	for rows.Next() {
+		preID, err := scanID(rows)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, preID)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Id < ids[j].Id
+	})
+	return ids, rows.Err()
+}
+
+// LookupID returns one UID with its usage and quota.
+func LookupID(uidName string) (IDSType, error) {
+	return lookupID(db, uidName)
+}
+
+// lookupID is LookupID on a database or, to check a quota before writing,
+// in a transaction.
+func lookupID(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}, uidName string) (IDSType, error) {
+	return scanID(q.QueryRow(idsQuery+` WHERE UID.name = $1`+idsGroupBy, uidName))
+}
+
+// SetUIDQuota stores the quota of a UID, 0 fields fall back to the
+// configured defaults.
+func SetUIDQuota(uidName string, quota QuotaType) error {
+	result, err := db.Exec(`UPDATE UID SET quota_bytes = $1, quota_snapshots = $2 WHERE name = $3`,
+		quota.MaxBytes, quota.MaxSnapshots, uidName)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetUIDStatus enables or disables a UID, the webhooks hear of it when the
+// status changed.
+func SetUIDStatus(uidName string, status bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current bool
+	if err := tx.QueryRow(`SELECT status FROM UID WHERE name = $1 FOR UPDATE`, uidName).Scan(&current); err != nil {
+		return err
+	}
+	if current == status {
+		return nil
+	}
+	if _, err := tx.Exec(`UPDATE UID SET status = $1 WHERE name = $2`, status, uidName); err != nil {
+		return err
+	}
+	event := EventUIDDisabled
+	if status {
+		event = EventUIDEnabled
+	}
+	if err := queueEvent(tx, uidName, event, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	wakeWebhooks()
+	return nil
+}
+
+// ListSnapshots returns the snapshots of a UID, newest first.
+func ListSnapshots(uidName string) ([]SnapshotInfo, error) {
+	query := `
+		SELECT time, octet_length(todolist), octet_length(config), COALESCE(device, '')
+		FROM axisgtd
+		WHERE uid_name = $1
+		ORDER BY time DESC`
+	rows, err := db.Query(query, uidName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []SnapshotInfo
+	for rows.Next() {
+		var info SnapshotInfo
+		if err := rows.Scan(&info.Time, &info.TodolistBytes, &info.ConfigBytes, &info.Device); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, info)
+	}
+	return snapshots, rows.Err()
+}
+
+func GetSnapshot(uidName string, time int64) (AxisGTDJsonType, error) {
+	data := AxisGTDJsonType{Name: uidName}
+	query := `
+		SELECT axisgtd.todolist, axisgtd.config, axisgtd.time, UID.status
+		FROM axisgtd
+		JOIN UID ON axisgtd.uid_name = UID.name
+		WHERE uid_name = $1 AND time = $2
+		LIMIT 1`
+	err := db.QueryRow(query, uidName, time).Scan(&data.Todolist, &data.Config, &data.Time, &data.Status)
+	return data, err
+}
+
+// RestoreSnapshot stores a copy of an old snapshot as the newest one, so
+// clients pick it up on their next sync, and returns the new time. device
+// names who restored it.
+func RestoreSnapshot(uidName string, from int64, device string) (int64, error) {
+	snapshot, err := GetSnapshot(uidName, from)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, uidName); err != nil {
+		return 0, err
+	}
+	usage, err := lookupID(tx, uidName)
+	if err != nil {
+		return 0, err
+	}
+	if err := quotaError(usage, int64(len(snapshot.Todolist)+len(snapshot.Config)), 1); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UnixMilli()
+	query := `INSERT INTO axisgtd (todolist,config,time,uid_name,device) VALUES ($1,$2,$3,$4,NULLIF($5, ''))`
+	_, err = tx.Exec(query, snapshot.Todolist, snapshot.Config, now, uidName, deviceName(device))
+	if err != nil {
+		return 0, err
+	}
+	if err := queueEvent(tx, uidName, EventSnapshotCreated, snapshotEvent(device, now)); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	wakeWebhooks()
+	return now, nil
+}
+
+// PruneSnapshots deletes old snapshots of one UID, or of every UID when
+// uidName is empty. A snapshot is deleted when it is not among the newest
+// keep snapshots or is older than before (unix milliseconds); zero disables
+// either rule. The newest snapshot of a UID is never deleted.
+func PruneSnapshots(uidName string, keep int, before int64) (int64, error) {
+	if keep <= 0 && before <= 0 {
+		return 0, fmt.Errorf("either keep or before is required")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM axisgtd
+		USING (
+			SELECT uid_name, time, ROW_NUMBER() OVER (PARTITION BY uid_name ORDER BY time DESC) AS rn
+			FROM axisgtd
+			WHERE $1::text = '' OR uid_name = $1::text
+		) ranked
+		WHERE axisgtd.uid_name = ranked.uid_name
+			AND axisgtd.time = ranked.time
+			AND ranked.rn > 1
+			AND (($2::int > 0 AND ranked.rn > $2::int) OR ($3::bigint > 0 AND axisgtd.time < $3::bigint))
+		RETURNING axisgtd.uid_name, axisgtd.time`
+	rows, err := tx.Query(query, uidName, keep, before)
+	if err != nil {
+		return 0, err
+	}
+	deleted := map[string][]int64{}
+	var count int64
+	for rows.Next() {
+		var name string
+		var t int64
+		if err := rows.Scan(&name, &t); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		deleted[name] = append(deleted[name], t)
+		count++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for name, times := range deleted {
+		sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+		if err := queueEvent(tx, name, EventSnapshotDeleted, snapshotEvent("", times...)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	wakeWebhooks()
+	return count, nil
+}
+
+// AppendSnapshot stores snapshot as the newest snapshot of a UID, only if the
+// newest one is still at time base and the quota leaves room for it.
+func AppendSnapshot(uidName string, snapshot AxisGTDType, base int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, uidName); err != nil {
+		return err
+	}
+	usage, err := lookupID(tx, uidName)
+	if err != nil {
+		return err
+	}
+	if err := quotaError(usage, int64(len(snapshot.Todolist)+len(snapshot.Config)), 1); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO axisgtd (todolist, config, time, uid_name, device)
+		SELECT $1, $2, $3, $4, NULLIF($6, '')
+		WHERE (SELECT MAX(time) FROM axisgtd WHERE uid_name = $4) = $5`
+	result, err := tx.Exec(query, snapshot.Todolist, snapshot.Config, snapshot.Time, uidName, base, deviceName(snapshot.Device))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrHeadChanged
+	}
+	if err := queueEvent(tx, uidName, EventSnapshotCreated, snapshotEvent(snapshot.Device, snapshot.Time)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	wakeWebhooks()
+	return nil
+}
+
+// InsertSnapshot stores a snapshot pushed by a client. The quota is checked
+// and the webhooks are queued in the same transaction, so neither can miss
+// or outlive the insert.
+func InsertSnapshot(uidName string, snapshot AxisGTDType) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, uidName); err != nil {
+		return err
+	}
+	usage, err := lookupID(tx, uidName)
+	if err != nil {
+		return err
+	}
+	if err := quotaError(usage, int64(len(snapshot.Todolist)+len(snapshot.Config)), 1); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO axisgtd (todolist,config,time,uid_name,device) VALUES ($1,$2,$3,$4,NULLIF($5, ''))`
+	_, err = tx.Exec(query, snapshot.Todolist, snapshot.Config, snapshot.Time, uidName, deviceName(snapshot.Device))
+	if err != nil {
+		return err
+	}
+	if err := queueEvent(tx, uidName, EventSnapshotCreated, snapshotEvent(snapshot.Device, snapshot.Time)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	wakeWebhooks()
+	return nil
+}
+
+// SetReadToken stores the read token of a UID, "" removes it.
+func SetReadToken(uidName string, token string) error {
+	result, err := db.Exec(`UPDATE UID SET read_token = NULLIF($1, '') WHERE name = $2`, token, uidName)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetShareToken stores the share token of a UID, "" removes it.
+func SetShareToken(uidName string, token string) error {
+	result, err := db.Exec(`UPDATE UID SET share_token = NULLIF($1, '') WHERE name = $2`, token, uidName)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetDAVSecret stores the CalDAV password of a UID, "" removes it.
+func SetDAVSecret(uidName string, secret string) error {
+	result, err := db.Exec(`UPDATE UID SET caldav_secret = NULLIF($1, '') WHERE name = $2`, secret, uidName)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetDAVSecret returns the CalDAV password of a UID, "" when it has none.
+func GetDAVSecret(uidName string) (string, error) {
+	var secret sql.NullString
+	err := db.QueryRow(`SELECT caldav_secret FROM UID WHERE name = $1`, uidName).Scan(&secret)
+	return secret.String, err
+}
+
+// UIDByReadToken returns the name of the UID a read token belongs to.
+func UIDByReadToken(token string) (string, error) {
+	var name string
+	err := db.QueryRow(`SELECT name FROM UID WHERE read_token = $1`, token).Scan(&name)
+	return name, err
+}
+
+// UIDByShareToken returns the name of the UID a share token belongs to.
+func UIDByShareToken(token string) (string, error) {
+	var name string
+	err := db.QueryRow(`SELECT name FROM UID WHERE share_token = $1`, token).Scan(&name)
+	return name, err
+}
+
+// SnapshotTimes returns the times of the snapshots of a UID, oldest first,
+// and the devices that stored them.
+func SnapshotTimes(uidName string) ([]int64, []string, error) {
+	rows, err := db.Query(`SELECT time, COALESCE(device, '') FROM axisgtd WHERE uid_name = $1 ORDER BY time`, uidName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var times []int64
+	var devices []string
+	for rows.Next() {
+		var t int64
+		var device string
+		if err := rows.Scan(&t, &device); err != nil {
+			return nil, nil, err
+		}
+		times = append(times, t)
+		devices = append(devices, device)
+	}
+	return times, devices, rows.Err()
+}
+
+// searchIndexBytes is how much of a todolist migration 6 indexes when the
+// whole of it doesn't fit in a tsvector.
+const searchIndexBytes = 262144
+
+// SearchSnapshots calls fn with the snapshots of a UID up to a time whose
+// todolist has a word starting with each of the terms, oldest first. The
+// terms must be lower-case letters and digits, without any every snapshot
+// matches. Todolists longer than the index covers are always passed, the
+// caller has to check them itself.
+func SearchSnapshots(uidName string, terms []string, until int64, fn func(time int64, device string, todolist string) error) error {
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+	query := `
+		SELECT time, COALESCE(device, ''), todolist
+		FROM axisgtd
+		WHERE uid_name = $1 AND time <= $2
+			AND ($3 = '' OR octet_length(todolist) > $4 OR axisgtd_search_vector(todolist) @@ to_tsquery('simple', $3))
+		ORDER BY time`
+	rows, err := db.Query(query, uidName, until, strings.Join(prefixes, " & "), searchIndexBytes)
+	if err != nil {
+		return err
+	}
+	return eachSnapshot(rows, fn)
+}
+
+// WalkSnapshots calls fn with the snapshots of a UID newer than a time,
+// oldest first.
+func WalkSnapshots(uidName string, after int64, fn func(time int64, device string, todolist string) error) error {
+	query := `
+		SELECT time, COALESCE(device, ''), todolist
+		FROM axisgtd
+		WHERE uid_name = $1 AND time > $2
+		ORDER BY time`
+	rows, err := db.Query(query, uidName, after)
+	if err != nil {
+		return err
+	}
+	return eachSnapshot(rows, fn)
+}
+
+// eachSnapshot calls fn with every row of time, device and todolist, then
+// closes rows.
+func eachSnapshot(rows *sql.Rows, fn func(time int64, device string, todolist string) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		var t int64
+		var device, todolist string
+		if err := rows.Scan(&t, &device, &todolist); err != nil {
+			return err
+		}
+		if err := fn(t, device, todolist); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetStatsCache returns the cached statistics state of a UID with the time
+// of the newest snapshot and the number of snapshots folded into it.
+// sql.ErrNoRows means nothing is cached yet.
+func GetStatsCache(uidName string) (until int64, snapshots int, state string, err error) {
+	query := `SELECT time, snapshots, state FROM axisgtd_stats WHERE uid_name = $1`
+	err = db.QueryRow(query, uidName).Scan(&until, &snapshots, &state)
+	return until, snapshots, state, err
+}
+
+// SaveStatsCache stores the statistics state of a UID, unless a newer one
+// was stored meanwhile.
+func SaveStatsCache(uidName string, until int64, snapshots int, state string) error {
+	query := `
+		INSERT INTO axisgtd_stats (uid_name, time, snapshots, state)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (uid_name) DO UPDATE
+		SET time = EXCLUDED.time, snapshots = EXCLUDED.snapshots, state = EXCLUDED.state
+		WHERE axisgtd_stats.time <= EXCLUDED.time`
+	_, err := db.Exec(query, uidName, until, snapshots, state)
+	return err
+}
+
+// CountSnapshots returns the number of snapshots of a UID up to a time.
+func CountSnapshots(uidName string, until int64) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM axisgtd WHERE uid_name = $1 AND time <= $2`, uidName, until).Scan(&count)
+	return count, err
+}
+
+// webhookScope matches the webhooks of a UID, or the global ones when
+// uidName is empty, as $1.
+const webhookScope = `uid_name IS NOT DISTINCT FROM NULLIF($1, '')`
+
+// AddWebhook stores a webhook of a UID, or a global one when uidName is
+// empty. No events subscribes to all of them.
+func AddWebhook(uidName string, url string, events []string, secret string) (WebhookType, error) {
+	hook := WebhookType{UID: uidName, URL: url, Events: events, Secret: secret, CreatedAt: time.Now().UnixMilli()}
+	query := `
+		INSERT INTO webhooks (uid_name, url, secret, events, created_at)
+		VALUES (NULLIF($1, ''), $2, $3, $4, $5)
+		RETURNING id`
+	err := db.QueryRow(query, uidName, url, secret, strings.Join(events, ","), hook.CreatedAt).Scan(&hook.ID)
+	return hook, err
+}
+
+// ListWebhooks returns the webhooks of a UID, or the global ones when
+// uidName is empty, without their secrets.
+func ListWebhooks(uidName string) ([]WebhookType, error) {
+	rows, err := db.Query(`SELECT id, url, events, created_at FROM webhooks WHERE `+webhookScope+` ORDER BY id`, uidName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []WebhookType{}
+	for rows.Next() {
+		hook := WebhookType{UID: uidName, Events: []string{}}
+		var events string
+		if err := rows.Scan(&hook.ID, &hook.URL, &events, &hook.CreatedAt); err != nil {
+			return nil, err
+		}
+		if events != "" {
+			hook.Events = strings.Split(events, ",")
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// RemoveWebhook deletes a webhook with its deliveries, sql.ErrNoRows if the
+// scope has no such webhook. The deliveries go first, deleting the webhook
+// only detaches them.
+func RemoveWebhook(uidName string, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM webhook_deliveries WHERE webhook_id = (SELECT id FROM webhooks WHERE id = $2 AND ` + webhookScope + `)`
+	if _, err := tx.Exec(query, uidName, id); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = $2 AND `+webhookScope, uidName, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event, d.status, d.attempts, d.response_status, d.error,
+	d.created_at, d.last_attempt, COALESCE(d.next_attempt, 0), d.payload`
+
+func scanDelivery(row interface{ Scan(...any) error }) (WebhookDeliveryType, error) {
+	var d WebhookDeliveryType
+	var payload string
+	err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Status, &d.Attempts, &d.ResponseStatus, &d.Error,
+		&d.CreatedAt, &d.LastAttempt, &d.NextAttempt, &payload)
+	if err != nil {
+		return d, err
+	}
+	return d, json.Unmarshal([]byte(payload), &d.Payload)
+}
+
+// ListDeliveries returns the newest deliveries of a webhook in the scope of
+// uidName, sql.ErrNoRows if there is no such webhook.
+func ListDeliveries(uidName string, webhookID int64, limit int) ([]WebhookDeliveryType, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM webhooks WHERE id = $2 AND `+webhookScope+`)`, uidName, webhookID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d WHERE d.webhook_id = $1 ORDER BY d.id DESC LIMIT $2`
+	rows, err := db.Query(query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDeliveryType{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ReplayDelivery queues the payload of a delivery again as a new delivery,
+// sql.ErrNoRows if the webhook in the scope of uidName has no such delivery.
+func ReplayDelivery(uidName string, webhookID int64, deliveryID int64) (WebhookDeliveryType, error) {
+	query := `
+		WITH replayed AS (
+			INSERT INTO webhook_deliveries (webhook_id, url, secret, global, event, payload, created_at, next_attempt)
+			SELECT d.webhook_id, webhooks.url, webhooks.secret, webhooks.uid_name IS NULL, d.event, d.payload, $4, $4
+			FROM webhook_deliveries d
+			JOIN webhooks ON webhooks.id = d.webhook_id
+			WHERE d.id = $3 AND d.webhook_id = $2 AND ` + webhookScope + `
+			RETURNING *
+		)
+		SELECT ` + deliveryColumns + ` FROM replayed d`
+	return scanDelivery(db.QueryRow(query, uidName, webhookID, deliveryID, time.Now().UnixMilli()))
+}
+
+// PruneDeliveries deletes the finished deliveries created before a time.
+func PruneDeliveries(before int64) (int64, error) {
+	result, err := db.Exec(`DELETE FROM webhook_deliveries WHERE next_attempt IS NULL AND created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
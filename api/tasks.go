@@ -0,0 +1,733 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The todolist format belongs to the AxisGTD client and is not versioned, so
+// tasks are read tolerantly: the field names below are tried in order, and
+// everything else in a task is kept untouched when it is written back.
+var taskFields = map[string][]string{
+	"id":          {"id", "uuid", "_id", "key"},
+	"title":       {"title", "text", "content", "name", "task"},
+	"notes":       {"notes", "note", "description", "desc"},
+	"done":        {"done", "completed", "isDone", "finished", "checked", "isCompleted", "status"},
+	"tags":        {"tags", "labels"},
+	"project":     {"project", "category", "context", "group"},
+	"due":         {"due", "dueDate", "due_date", "deadline", "dueAt"},
+	"createdAt":   {"createdAt", "created", "created_at", "createTime", "createdTime"},
+	"completedAt": {"completedAt", "completed_at", "doneAt", "finishedAt", "finishTime"},
+	"priority":    {"priority", "prio", "importance"},
+}
+
+// taskContainers are the keys a todolist object may keep its task array in.
+var taskContainers = []string{"tasks", "todos", "items", "todolist", "list", "data"}
+
+var doneStatuses = map[string]bool{"done": true, "completed": true, "complete": true, "finished": true, "closed": true}
+
+// taskList is a parsed todolist. The items are the task objects inside root,
+// so changing one changes root, ids are their ids in the same order.
+type taskList struct {
+	root      any
+	container string
+	keyed     bool
+	items     []map[string]any
+	ids       []string
+}
+
+func parseTaskList(todolist string) (*taskList, error) {
+	dec := json.NewDecoder(strings.NewReader(todolist))
+	dec.UseNumber()
+	l := new(taskList)
+	if err := dec.Decode(&l.root); err != nil {
+		return nil, fmt.Errorf("todolist is not valid JSON: %v", err)
+	}
+
+	switch root := l.root.(type) {
+	case []any:
+		l.setItems(taskObjects(root))
+		return l, nil
+	case map[string]any:
+		for _, key := range taskContainers {
+			if array, ok := root[key].([]any); ok {
+				l.container = key
+				l.setItems(taskObjects(array))
+				return l, nil
+			}
+		}
+		// An object of tasks keyed by their id.
+		for _, key := range sortedKeys(root) {
+			item, ok := root[key].(map[string]any)
+			if !ok {
+				return nil, errors.New("todolist has no task list the server understands")
+			}
+			l.items = append(l.items, item)
+			l.ids = append(l.ids, key)
+		}
+		l.keyed = true
+		return l, nil
+	}
+	return nil, errors.New("todolist has no task list the server understands")
+}
+
+func (l *taskList) setItems(items []map[string]any) {
+	l.items = items
+	for _, item := range items {
+		l.ids = append(l.ids, stringValue(item[taskKey(item, "id")]))
+	}
+}
+
+func taskObjects(array []any) []map[string]any {
+	items := make([]map[string]any, 0, len(array))
+	for _, v := range array {
+		if item, ok := v.(map[string]any); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// taskKey is the key an item uses for a field, or "" when it has none.
+func taskKey(item map[string]any, field string) string {
+	for _, key := range taskFields[field] {
+		if _, ok := item[key]; ok {
+			return key
+		}
+	}
+	return ""
+}
+
+// listKey is the key the list uses for a field, so a task added or edited
+// looks like its neighbours.
+func (l *taskList) listKey(field string) string {
+	for _, item := range l.items {
+		if key := taskKey(item, field); key != "" {
+			return key
+		}
+	}
+	return taskFields[field][0]
+}
+
+// find returns the index of a task, or -1.
+func (l *taskList) find(id string) int {
+	for i, itemID := range l.ids {
+		if itemID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *taskList) task(i int) TaskType {
+	return toTask(l.items[i], l.ids[i])
+}
+
+func (l *taskList) add(id string, item map[string]any) int {
+	switch {
+	case l.keyed:
+		l.root.(map[string]any)[id] = item
+	case l.container != "":
+		root := l.root.(map[string]any)
+		root[l.container] = append(root[l.container].([]any), item)
+	default:
+		l.root = append(l.root.([]any), item)
+	}
+	l.items = append(l.items, item)
+	l.ids = append(l.ids, id)
+	return len(l.items) - 1
+}
+
+// remove deletes the task at i, from root as well.
+func (l *taskList) remove(i int) {
+	switch {
+	case l.keyed:
+		delete(l.root.(map[string]any), l.ids[i])
+	case l.container != "":
+		root := l.root.(map[string]any)
+		root[l.container] = removeObject(root[l.container].([]any), i)
+	default:
+		l.root = removeObject(l.root.([]any), i)
+	}
+	l.items = append(l.items[:i:i], l.items[i+1:]...)
+	l.ids = append(l.ids[:i:i], l.ids[i+1:]...)
+}
+
+// removeObject removes the i-th object of array, skipping the values that
+// aren't objects like taskObjects does.
+func removeObject(array []any, i int) []any {
+	n := -1
+	for j, v := range array {
+		if _, ok := v.(map[string]any); ok {
+			if n++; n == i {
+				return append(array[:j:j], array[j+1:]...)
+			}
+		}
+	}
+	return array
+}
+
+// nextID continues numeric ids and makes up a random one otherwise.
+func (l *taskList) nextID() (string, error) {
+	var highest int64
+	numeric := len(l.ids) > 0
+	for _, id := range l.ids {
+		v, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			numeric = false
+			break
+		}
+		highest = max(highest, v)
+	}
+	if numeric {
+		return strconv.FormatInt(highest+1, 10), nil
+	}
+	return GenerateRandomHex(8)
+}
+
+// newItem starts a task with the id stored the way the list stores ids,
+// keyed lists don't repeat it inside the task.
+func (l *taskList) newItem(id string) map[string]any {
+	item := map[string]any{}
+	if l.keyed && len(l.items) > 0 && taskKey(l.items[0], "id") == "" {
+		return item
+	}
+	key := l.listKey("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err == nil && len(l.items) > 0 {
+		if _, isNumber := l.items[0][key].(json.Number); isNumber {
+			item[key] = json.Number(id)
+			return item
+		}
+	}
+	item[key] = id
+	return item
+}
+
+func (l *taskList) marshal() (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(l.root); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func toTask(item map[string]any, id string) TaskType {
+	task := TaskType{
+		ID:          id,
+		Title:       stringValue(item[taskKey(item, "title")]),
+		Notes:       stringValue(item[taskKey(item, "notes")]),
+		Done:        doneValue(item[taskKey(item, "done")]),
+		Tags:        tagsValue(item[taskKey(item, "tags")]),
+		Project:     stringValue(item[taskKey(item, "project")]),
+		Due:         timeValue(item[taskKey(item, "due")]),
+		CreatedAt:   timeValue(item[taskKey(item, "createdAt")]),
+		CompletedAt: timeValue(item[taskKey(item, "completedAt")]),
+		Raw:         item,
+	}
+	if task.Tags == nil {
+		task.Tags = []string{}
+	}
+	return task
+}
+
+func stringValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func doneValue(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case json.Number:
+		return v.String() != "0"
+	case string:
+		return doneStatuses[strings.ToLower(v)]
+	}
+	return false
+}
+
+func tagsValue(v any) []string {
+	var tags []string
+	switch v := v.(type) {
+	case []any:
+		for _, tag := range v {
+			if s := stringValue(tag); s != "" {
+				tags = append(tags, s)
+			}
+		}
+	case string:
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// timeValue reads a time stored as Unix milliseconds, Unix seconds or a date
+// string, as Unix milliseconds. 0 is no time.
+func timeValue(v any) int64 {
+	switch v := v.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			f, err := v.Float64()
+			if err != nil {
+				return 0
+			}
+			n = int64(f)
+		}
+		if n > 0 && n < 1e11 {
+			return n * 1000
+		}
+		return n
+	case string:
+		t, err := parseTaskTime(v)
+		if err != nil {
+			return 0
+		}
+		return t
+	}
+	return 0
+}
+
+var taskTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", time.DateOnly}
+
+// parseTaskTime accepts Unix milliseconds or a date with an optional time,
+// in UTC unless it carries a zone.
+func parseTaskTime(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	for _, layout := range taskTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a date like 2024-09-01 or 2024-09-01T18:00:00Z", s)
+}
+
+// setTime writes a time in the form the list already uses for the field.
+func (l *taskList) setTime(item map[string]any, field string, ms int64) {
+	key := l.listKey(field)
+	if ms == 0 {
+		delete(item, key)
+		return
+	}
+	for _, other := range l.items {
+		switch v := other[key].(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil && n > 0 && n < 1e11 {
+				item[key] = json.Number(strconv.FormatInt(ms/1000, 10))
+				return
+			}
+			item[key] = json.Number(strconv.FormatInt(ms, 10))
+			return
+		case string:
+			t := time.UnixMilli(ms).UTC()
+			if len(v) == len(time.DateOnly) {
+				item[key] = t.Format(time.DateOnly)
+			} else {
+				item[key] = t.Format(time.RFC3339)
+			}
+			return
+		}
+	}
+	item[key] = json.Number(strconv.FormatInt(ms, 10))
+}
+
+func (l *taskList) setDone(item map[string]any, done bool) {
+	key := taskKey(item, "done")
+	if key == "" {
+		key = l.listKey("done")
+	}
+	if _, isString := item[key].(string); isString || (key == "status" && item[key] == nil) {
+		if done {
+			item[key] = "done"
+		} else {
+			item[key] = "todo"
+		}
+	} else {
+		item[key] = done
+	}
+
+	if done {
+		l.setTime(item, "completedAt", time.Now().UnixMilli())
+	} else {
+		l.setTime(item, "completedAt", 0)
+	}
+}
+
+func (l *taskList) setTags(item map[string]any, tags []string) {
+	key := l.listKey("tags")
+	if _, isString := item[key].(string); isString {
+		item[key] = strings.Join(tags, ",")
+		return
+	}
+	values := make([]any, len(tags))
+	for i, tag := range tags {
+		values[i] = tag
+	}
+	item[key] = values
+}
+
+// apply writes a patch onto an item, keeping the item's own key names.
+func (l *taskList) apply(item map[string]any, patch TaskPatch) error {
+	set := func(field string, value string) {
+		key := taskKey(item, field)
+		if key == "" {
+			key = l.listKey(field)
+		}
+		item[key] = value
+	}
+	if patch.Title != nil {
+		if strings.TrimSpace(*patch.Title) == "" {
+			return errors.New("title must not be empty")
+		}
+		set("title", *patch.Title)
+	}
+	if patch.Notes != nil {
+		set("notes", *patch.Notes)
+	}
+	if patch.Project != nil {
+		set("project", *patch.Project)
+	}
+	if patch.Tags != nil {
+		l.setTags(item, *patch.Tags)
+	}
+	if patch.Due != nil {
+		var due int64
+		if *patch.Due != "" {
+			var err error
+			if due, err = parseTaskTime(*patch.Due); err != nil {
+				return fmt.Errorf("due: %v", err)
+			}
+		}
+		l.setTime(item, "due", due)
+	}
+	if patch.Done != nil {
+		l.setDone(item, *patch.Done)
+	}
+	return nil
+}
+
+// taskFilter holds the query parameters of ListTasks.
+type taskFilter struct {
+	status    string
+	tag       string
+	project   string
+	dueBefore int64
+	dueAfter  int64
+	hasDue    bool
+}
+
+func parseTaskFilter(c *fiber.Ctx) (taskFilter, error) {
+	f := taskFilter{
+		status:  c.Query("status", "all"),
+		tag:     c.Query("tag"),
+		project: c.Query("project"),
+		hasDue:  c.QueryBool("has_due"),
+	}
+	switch f.status {
+	case "all", "open", "done":
+	default:
+		return f, fmt.Errorf("status must be all, open or done, got %q", f.status)
+	}
+	var err error
+	if s := c.Query("due_before"); s != "" {
+		if f.dueBefore, err = parseTaskTime(s); err != nil {
+			return f, fmt.Errorf("due_before: %v", err)
+		}
+	}
+	if s := c.Query("due_after"); s != "" {
+		if f.dueAfter, err = parseTaskTime(s); err != nil {
+			return f, fmt.Errorf("due_after: %v", err)
+		}
+	}
+	return f, nil
+}
+
+func (f taskFilter) match(task TaskType) bool {
+	if (f.status == "open" && task.Done) || (f.status == "done" && !task.Done) {
+		return false
+	}
+	if f.tag != "" {
+		found := false
+		for _, tag := range task.Tags {
+			if strings.EqualFold(tag, f.tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.project != "" && !strings.EqualFold(task.Project, f.project) {
+		return false
+	}
+	if (f.hasDue || f.dueBefore != 0 || f.dueAfter != 0) && task.Due == 0 {
+		return false
+	}
+	if f.dueBefore != 0 && task.Due >= f.dueBefore {
+		return false
+	}
+	if f.dueAfter != 0 && task.Due < f.dueAfter {
+		return false
+	}
+	return true
+}
+
+// headTasks loads the parsed todolist of the newest snapshot of the UID in
+// the request.
+func headTasks(c *fiber.Ctx) (headSnapshot, *taskList, error) {
+	head, err := LatestSnapshot(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return head, nil, uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return head, nil, storeError(err, "Get tasks Failed")
+	}
+	if !head.Status {
+		return head, nil, uidDisabled(head.Name)
+	}
+	if !head.HasSnapshot {
+		return head, nil, notFound("no_records", "No records available")
+	}
+	list, err := parseTaskList(head.Todolist)
+	if err != nil {
+		return head, nil, newError(fiber.StatusUnprocessableEntity, "unsupported_todolist", "%v", err)
+	}
+	return head, list, nil
+}
+
+// saveTasks stores the changed todolist as a new snapshot on top of head,
+// unless If-Match names another revision or someone else synced meanwhile.
+func saveTasks(c *fiber.Ctx, head headSnapshot, list *taskList) (*AxisGTDType, error) {
+	if match := c.Get(fiber.HeaderIfMatch); match != "" && match != "*" && !strings.Contains(match, head.revision().ETag()) {
+		return nil, newError(fiber.StatusPreconditionFailed, "head_changed", "the todolist changed since %s, reload and retry", match)
+	}
+	snapshot, err := storeTasks(head, list, requestDevice(c))
+	if err != nil {
+		return nil, err
+	}
+	setRevisionHeaders(c, snapshotRevision(snapshot.Todolist, snapshot.Config, snapshot.Time))
+	return snapshot, nil
+}
+
+// storeTasks appends the changed todolist with the config of head, the
+// error wraps ErrHeadChanged when someone else synced meanwhile.
+func storeTasks(head headSnapshot, list *taskList, device string) (*AxisGTDType, error) {
+	todolist, err := list.marshal()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &AxisGTDType{Todolist: todolist, Config: head.Config, Time: time.Now().UnixMilli(), Device: device}
+	if snapshot.Time <= head.Time {
+		snapshot.Time = head.Time + 1
+	}
+	if err := checkUpload(snapshot); err != nil {
+		return nil, err
+	}
+	if err := AppendSnapshot(head.Name, *snapshot, head.Time); err != nil {
+		return nil, storeError(err, "Save tasks Failed")
+	}
+	uidChanged(head.Name)
+	return snapshot, nil
+}
+
+// @Summary		List tasks
+// @Description	Parses the todolist of the newest snapshot into tasks, optionally filtered. Dates are Unix milliseconds or dates like 2024-09-01.
+// @Tags			tasks
+// @Produce		json
+// @Param			name		path		string	true	"UID Name"
+// @Param			status		query		string	false	"all (default), open or done"
+// @Param			tag			query		string	false	"Only tasks with this tag"
+// @Param			project		query		string	false	"Only tasks of this project"
+// @Param			due_before	query		string	false	"Only tasks due before this time"
+// @Param			due_after	query		string	false	"Only tasks due at or after this time"
+// @Param			has_due		query		bool	false	"Only tasks with a due date"
+// @Success		200			{array}		TaskType
+// @Failure		400			{object}	ErrorType	"Invalid filter"
+// @Failure		403			{object}	ErrorType	"UID is disabled"
+// @Failure		404			{object}	ErrorType	"UID not found or no records available"
+// @Failure		422			{object}	ErrorType	"The todolist format is not understood"
+// @Router			/api/v1/ids/{name}/tasks [get]
+func ListTasks(c *fiber.Ctx) error {
+	filter, err := parseTaskFilter(c)
+	if err != nil {
+		return badRequest("invalid_filter", "%v", err)
+	}
+	head, list, err := headTasks(c)
+	if err != nil {
+		return err
+	}
+
+	tasks := []TaskType{}
+	for i := range list.items {
+		if task := list.task(i); filter.match(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	setRevisionHeaders(c, head.revision())
+	return c.JSON(tasks)
+}
+
+// @Summary		Get a task
+// @Description	Returns one task of the newest snapshot's todolist.
+// @Tags			tasks
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Param			id		path		string	true	"Task id"
+// @Success		200		{object}	TaskType
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"UID or task not found"
+// @Failure		422		{object}	ErrorType	"The todolist format is not understood"
+// @Router			/api/v1/ids/{name}/tasks/{id} [get]
+func GetTask(c *fiber.Ctx) error {
+	head, list, err := headTasks(c)
+	if err != nil {
+		return err
+	}
+	i := list.find(c.Params("id"))
+	if i < 0 {
+		return notFound("task_not_found", "task %s not found", c.Params("id"))
+	}
+	setRevisionHeaders(c, head.revision())
+	return c.JSON(list.task(i))
+}
+
+// @Summary		Add a task
+// @Description	Adds a task to the todolist and stores the result as a new snapshot, which clients pick up on their next sync.
+// @Tags			tasks
+// @Accept			json
+// @Produce		json
+// @Param			name		path		string		true	"UID Name"
+// @Param			task		body		TaskPatch	true	"The task, title is required"
+// @Param			If-Match	header		string		false	"Only if the todolist is still at this ETag"
+// @Success		201			{object}	TaskType
+// @Failure		400			{object}	ErrorType	"Invalid task"
+// @Failure		404			{object}	ErrorType	"UID not found or no records available"
+// @Failure		409			{object}	ErrorType	"Another client synced meanwhile"
+// @Failure		412			{object}	ErrorType	"If-Match does not match"
+// @Failure		413			{object}	ErrorType	"Quota exceeded"
+// @Router			/api/v1/ids/{name}/tasks [post]
+func AddTask(c *fiber.Ctx) error {
+	var patch TaskPatch
+	if err := c.BodyParser(&patch); err != nil {
+		return &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body", Message: "Invalid request body", Err: err}
+	}
+	if patch.Title == nil {
+		return badRequest("invalid_task", "title is required")
+	}
+	head, list, err := headTasks(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := list.nextID()
+	if err != nil {
+		return err
+	}
+	item := list.newItem(id)
+	list.setTime(item, "createdAt", time.Now().UnixMilli())
+	if patch.Done == nil {
+		done := false
+		patch.Done = &done
+	}
+	if err := list.apply(item, patch); err != nil {
+		return badRequest("invalid_task", "%v", err)
+	}
+	i := list.add(id, item)
+
+	if _, err := saveTasks(c, head, list); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(list.task(i))
+}
+
+// @Summary		Edit a task
+// @Description	Changes the given fields of a task and stores the result as a new snapshot. Set due to "" to remove it.
+// @Tags			tasks
+// @Accept			json
+// @Produce		json
+// @Param			name		path		string		true	"UID Name"
+// @Param			id			path		string		true	"Task id"
+// @Param			task		body		TaskPatch	true	"Fields to change"
+// @Param			If-Match	header		string		false	"Only if the todolist is still at this ETag"
+// @Success		200			{object}	TaskType
+// @Failure		400			{object}	ErrorType	"Invalid task"
+// @Failure		404			{object}	ErrorType	"UID or task not found"
+// @Failure		409			{object}	ErrorType	"Another client synced meanwhile"
+// @Failure		412			{object}	ErrorType	"If-Match does not match"
+// @Router			/api/v1/ids/{name}/tasks/{id} [patch]
+func UpdateTask(c *fiber.Ctx) error {
+	var patch TaskPatch
+	if err := c.BodyParser(&patch); err != nil {
+		return &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body", Message: "Invalid request body", Err: err}
+	}
+	return changeTask(c, patch)
+}
+
+// @Summary		Complete a task
+// @Description	Marks a task as done and stores the result as a new snapshot.
+// @Tags			tasks
+// @Produce		json
+// @Param			name		path		string	true	"UID Name"
+// @Param			id			path		string	true	"Task id"
+// @Param			If-Match	header		string	false	"Only if the todolist is still at this ETag"
+// @Success		200			{object}	TaskType
+// @Failure		404			{object}	ErrorType	"UID or task not found"
+// @Failure		409			{object}	ErrorType	"Another client synced meanwhile"
+// @Failure		412			{object}	ErrorType	"If-Match does not match"
+// @Router			/api/v1/ids/{name}/tasks/{id}/complete [post]
+func CompleteTask(c *fiber.Ctx) error {
+	done := true
+	return changeTask(c, TaskPatch{Done: &done})
+}
+
+func changeTask(c *fiber.Ctx, patch TaskPatch) error {
+	head, list, err := headTasks(c)
+	if err != nil {
+		return err
+	}
+	i := list.find(c.Params("id"))
+	if i < 0 {
+		return notFound("task_not_found", "task %s not found", c.Params("id"))
+	}
+	if err := list.apply(list.items[i], patch); err != nil {
+		return badRequest("invalid_task", "%v", err)
+	}
+	if _, err := saveTasks(c, head, list); err != nil {
+		return err
+	}
+	return c.JSON(list.task(i))
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTaskList(t *testing.T) {
+	tests := []struct {
+		name     string
+		todolist string
+		ids      []string
+		titles   []string
+		err      bool
+	}{
+		{"array", `[{"id":1,"title":"a"},{"id":2,"title":"b"}]`, []string{"1", "2"}, []string{"a", "b"}, false},
+		{"container", `{"version":2,"todos":[{"uuid":"x","text":"a"},"note",{"uuid":"y","text":"b"}]}`, []string{"x", "y"}, []string{"a", "b"}, false},
+		{"keyed by id", `{"b":{"title":"second"},"a":{"title":"first"}}`, []string{"a", "b"}, []string{"first", "second"}, false},
+		{"no ids", `[{"content":"a"}]`, []string{""}, []string{"a"}, false},
+		{"empty", `[]`, nil, nil, false},
+		{"mixed object", `{"a":{"title":"first"},"count":1}`, nil, nil, true},
+		{"string", `"tasks"`, nil, nil, true},
+		{"invalid", `[`, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parseTaskList(tt.todolist)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parseTaskList = %+v, want an error", list)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTaskList = %v", err)
+			}
+			var titles []string
+			for i := range list.items {
+				titles = append(titles, list.task(i).Title)
+			}
+			if !reflect.DeepEqual(list.ids, tt.ids) || !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("ids %q, titles %q, want %q, %q", list.ids, titles, tt.ids, tt.titles)
+			}
+		})
+	}
+}
+
+func TestToTask(t *testing.T) {
+	tests := []struct {
+		item string
+		want TaskType
+	}{
+		{`[{"id":1,"title":"a","done":true,"tags":["x","y"],"due":1725000000}]`,
+			TaskType{ID: "1", Title: "a", Done: true, Tags: []string{"x", "y"}, Due: 1725000000000}},
+		{`[{"id":"2","text":"b","status":"Completed","labels":"x, y","dueDate":"2024-09-01","createdAt":1725000000123}]`,
+			TaskType{ID: "2", Title: "b", Done: true, Tags: []string{"x", "y"}, Due: 1725148800000, CreatedAt: 1725000000123}},
+		{`[{"id":3,"title":"c","completed":0,"project":"home","notes":"n"}]`,
+			TaskType{ID: "3", Title: "c", Tags: []string{}, Project: "home", Notes: "n"}},
+		// "date" is often when a task was written down and "list" holds the
+		// tasks, neither is read as the due date or the project.
+		{`{"list":[{"id":4,"title":"d","date":"2024-09-01"}]}`,
+			TaskType{ID: "4", Title: "d", Tags: []string{}}},
+	}
+	for _, tt := range tests {
+		list, err := parseTaskList(tt.item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := list.task(0)
+		got.Raw = nil
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("task of %s = %+v, want %+v", tt.item, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	str := func(s string) *string { return &s }
+	yes := true
+
+	tests := []struct {
+		name     string
+		todolist string
+		patch    TaskPatch
+		want     string
+		err      string
+	}{
+		{
+			name:     "keeps the item's keys",
+			todolist: `[{"id":1,"text":"a","note":""}]`,
+			patch:    TaskPatch{Title: str("b"), Notes: str("n")},
+			want:     `[{"id":1,"note":"n","text":"b"}]`,
+		},
+		{
+			name:     "uses the list's keys",
+			todolist: `[{"id":1,"text":"a"},{"id":2,"text":"b","category":"work"}]`,
+			patch:    TaskPatch{Project: str("home")},
+			want:     `[{"category":"home","id":1,"text":"a"},{"category":"work","id":2,"text":"b"}]`,
+		},
+		{
+			name:     "tags as a string",
+			todolist: `[{"id":1,"tags":"x"}]`,
+			patch:    TaskPatch{Tags: &[]string{"y", "z"}},
+			want:     `[{"id":1,"tags":"y,z"}]`,
+		},
+		{
+			name:     "due in seconds",
+			todolist: `[{"id":1,"due":1725000000},{"id":2}]`,
+			patch:    TaskPatch{Due: str("2024-09-02")},
+			want:     `[{"due":1725235200,"id":1},{"id":2}]`,
+		},
+		{
+			name:     "due as a date",
+			todolist: `[{"id":1,"due":"2024-09-01"}]`,
+			patch:    TaskPatch{Due: str("2024-09-02T10:00:00Z")},
+			want:     `[{"due":"2024-09-02","id":1}]`,
+		},
+		{
+			name:     "clear due",
+			todolist: `[{"id":1,"due":1725000000000}]`,
+			patch:    TaskPatch{Due: str("")},
+			want:     `[{"id":1}]`,
+		},
+		{
+			name:     "done as a status",
+			todolist: `[{"id":1,"status":"todo"}]`,
+			patch:    TaskPatch{Done: &yes},
+		},
+		{name: "empty title", todolist: `[{"id":1}]`, patch: TaskPatch{Title: str(" ")}, err: "title must not be empty"},
+		{name: "invalid due", todolist: `[{"id":1}]`, patch: TaskPatch{Due: str("soon")}, err: `due: "soon" is not a date like 2024-09-01 or 2024-09-01T18:00:00Z`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parseTaskList(tt.todolist)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = list.apply(list.items[0], tt.patch)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("apply = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply = %v", err)
+			}
+			if tt.want == "" {
+				// The completion time is now, so only check what the task
+				// reads as.
+				if task := list.task(0); !task.Done || task.CompletedAt == 0 || task.Raw["status"] != "done" {
+					t.Errorf("task = %+v, want done with a completion time", task)
+				}
+				return
+			}
+			if got, _ := list.marshal(); got != tt.want {
+				t.Errorf("todolist = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
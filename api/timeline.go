@@ -0,0 +1,151 @@
+package api
+
+import (
+	"database/sql"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxDeviceLength is the size of the device column.
+const maxDeviceLength = 100
+
+// requestDevice names the client of a request for the snapshots it stores:
+// the X-Device header apps can set to something like "Anna's phone", or
+// else the User-Agent.
+func requestDevice(c *fiber.Ctx) string {
+	if device := strings.TrimSpace(c.Get("X-Device")); device != "" {
+		return device
+	}
+	return c.Get(fiber.HeaderUserAgent)
+}
+
+// deviceName cuts a device to fit the column without splitting a character.
+func deviceName(device string) string {
+	device = strings.TrimSpace(device)
+	if len(device) <= maxDeviceLength {
+		return device
+	}
+	device = device[:maxDeviceLength]
+	for !utf8.ValidString(device) {
+		device = device[:len(device)-1]
+	}
+	return device
+}
+
+// taskChanges lists the fields edited between two versions of a task.
+// Completion has events of its own.
+func taskChanges(before TaskType, after TaskType) []TaskChangeType {
+	var changes []TaskChangeType
+	if before.Title != after.Title {
+		changes = append(changes, TaskChangeType{Field: "title", From: before.Title, To: after.Title})
+	}
+	if before.Notes != after.Notes {
+		changes = append(changes, TaskChangeType{Field: "notes", From: before.Notes, To: after.Notes})
+	}
+	if before.Due != after.Due {
+		changes = append(changes, TaskChangeType{Field: "due", From: before.Due, To: after.Due})
+	}
+	if before.Project != after.Project {
+		changes = append(changes, TaskChangeType{Field: "project", From: before.Project, To: after.Project})
+	}
+	if !slices.Equal(before.Tags, after.Tags) {
+		changes = append(changes, TaskChangeType{Field: "tags", From: before.Tags, To: after.Tags})
+	}
+	return changes
+}
+
+// taskTimeline follows one task through the snapshots of a UID, passed to
+// visit oldest first. A todolist the server doesn't understand is skipped
+// rather than taken as deleting the task.
+type taskTimeline struct {
+	TaskTimelineType
+	last  TaskType
+	found bool
+}
+
+func newTaskTimeline(id string) *taskTimeline {
+	return &taskTimeline{TaskTimelineType: TaskTimelineType{ID: id, Events: []TaskEventType{}}}
+}
+
+func (tl *taskTimeline) visit(t int64, device string, todolist string) error {
+	list, err := parseTaskList(todolist)
+	if err != nil {
+		return nil
+	}
+	i := list.find(tl.ID)
+	if i < 0 {
+		if tl.Present {
+			tl.Events = append(tl.Events, TaskEventType{Time: t, Device: device, Event: "deleted", Task: tl.last})
+			tl.Present = false
+		}
+		return nil
+	}
+	task := list.task(i)
+	event := TaskEventType{Time: t, Device: device, Task: task}
+
+	switch {
+	case !tl.found:
+		event.Event = "created"
+		tl.Events = append(tl.Events, event)
+	case !tl.Present:
+		event.Event = "restored"
+		event.Changes = taskChanges(tl.last, task)
+		tl.Events = append(tl.Events, event)
+	default:
+		if changes := taskChanges(tl.last, task); len(changes) > 0 {
+			event.Event = "edited"
+			event.Changes = changes
+			tl.Events = append(tl.Events, event)
+		}
+		if tl.last.Done != task.Done {
+			event.Event = "completed"
+			if !task.Done {
+				event.Event = "reopened"
+			}
+			event.Changes = nil
+			tl.Events = append(tl.Events, event)
+		}
+	}
+	tl.last, tl.found, tl.Present = task, true, true
+	return nil
+}
+
+// @Summary		Task timeline
+// @Description	Reconstructs the history of a task from the snapshots of a UID: when it was created, edited, completed, reopened, deleted and restored, each with the snapshot and the device that stored the change. Oldest first.
+// @Tags			tasks
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Param			id		path		string	true	"Task id"
+// @Success		200		{object}	TaskTimelineType
+// @Failure		403		{object}	ErrorType	"UID is disabled"
+// @Failure		404		{object}	ErrorType	"UID or task not found"
+// @Failure		500		{object}	ErrorType	"Internal server error"
+// @Router			/api/v1/ids/{name}/tasks/{id}/history [get]
+func TaskTimeline(c *fiber.Ctx) error {
+	uid, err := GetUID(c.Params("name"))
+	if err == sql.ErrNoRows {
+		return uidNotFound(c.Params("name"))
+	}
+	if err != nil {
+		return storeError(err, "Timeline Failed")
+	}
+	if !uid.Status {
+		return uidDisabled(uid.Name)
+	}
+
+	// Every snapshot is read: the search index splits ids into other words
+	// than the server does and doesn't cover the end of long todolists, so
+	// a snapshot it skips may still have the task.
+	tl := newTaskTimeline(c.Params("id"))
+	if err := WalkSnapshots(uid.Name, math.MinInt64, tl.visit); err != nil {
+		return storeError(err, "Timeline Failed")
+	}
+	if !tl.found {
+		return notFound("task_not_found", "task %s not found", tl.ID)
+	}
+	return c.JSON(tl.TaskTimelineType)
+}
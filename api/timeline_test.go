@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTaskTimeline(t *testing.T) {
+	type snapshot struct {
+		device   string
+		todolist string
+	}
+	tests := []struct {
+		name      string
+		id        string
+		snapshots []snapshot
+		events    []string
+		present   bool
+	}{
+		{
+			name: "created, edited and completed",
+			id:   "1",
+			snapshots: []snapshot{
+				{"phone", `[]`},
+				{"phone", `[{"id":1,"title":"a"}]`},
+				{"laptop", `[{"id":1,"title":"b"}]`},
+				{"laptop", `[{"id":1,"title":"b"}]`},
+				{"phone", `[{"id":1,"title":"b","done":true}]`},
+			},
+			events:  []string{"created 2 phone", "edited 3 laptop", "completed 5 phone"},
+			present: true,
+		},
+		{
+			name: "deleted and restored",
+			id:   "1",
+			snapshots: []snapshot{
+				{"phone", `[{"id":1,"title":"a"}]`},
+				{"laptop", `[]`},
+				{"laptop", `[{"id":2,"title":"b"}]`},
+				{"phone", `[{"id":1,"title":"a","done":true}]`},
+				{"phone", `[]`},
+			},
+			events: []string{"created 1 phone", "deleted 2 laptop", "restored 4 phone", "deleted 5 phone"},
+		},
+		{
+			name: "reopened",
+			id:   "x",
+			snapshots: []snapshot{
+				{"", `{"todos":[{"uuid":"x","text":"a","done":true}]}`},
+				{"", `{"todos":[{"uuid":"x","text":"a","done":false}]}`},
+			},
+			events:  []string{"created 1 ", "reopened 2 "},
+			present: true,
+		},
+		// A todolist the server can't read says nothing about the task.
+		{
+			name: "not understood",
+			id:   "1",
+			snapshots: []snapshot{
+				{"", `[{"id":1,"title":"a"}]`},
+				{"", `"tasks"`},
+				{"", `[{"id":1,"title":"a"}]`},
+			},
+			events:  []string{"created 1 "},
+			present: true,
+		},
+		// The task sits past the part of a long todolist the search index
+		// covers.
+		{
+			name: "long todolist",
+			id:   "9",
+			snapshots: []snapshot{
+				{"", `[{"id":1,"title":"` + strings.Repeat("a", searchIndexBytes) + `"},{"id":9,"title":"late"}]`},
+				{"", `[{"id":1,"title":"` + strings.Repeat("a", searchIndexBytes) + `"},{"id":9,"title":"later"}]`},
+			},
+			events:  []string{"created 1 ", "edited 2 "},
+			present: true,
+		},
+		{name: "missing", id: "7", snapshots: []snapshot{{"", `[{"id":1}]`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := newTaskTimeline(tt.id)
+			for i, s := range tt.snapshots {
+				if err := tl.visit(int64(i+1), s.device, s.todolist); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var events []string
+			for _, event := range tl.Events {
+				events = append(events, fmt.Sprintf("%s %d %s", event.Event, event.Time, event.Device))
+			}
+			if !reflect.DeepEqual(events, tt.events) || tl.Present != tt.present || tl.found != (len(tt.events) > 0) {
+				t.Errorf("events %q, present %t, want %q, %t", events, tl.Present, tt.events, tt.present)
+			}
+		})
+	}
+}
+
+func TestTaskTimelineChanges(t *testing.T) {
+	tl := newTaskTimeline("1")
+	tl.visit(1, "", `[{"id":1,"title":"a","tags":["x"],"due":1725235200000}]`)
+	tl.visit(2, "", `[]`)
+	tl.visit(3, "", `[{"id":1,"title":"b","tags":["x","y"],"due":1725235200000}]`)
+
+	restored := tl.Events[len(tl.Events)-1]
+	want := []TaskChangeType{
+		{Field: "title", From: "a", To: "b"},
+		{Field: "tags", From: []string{"x"}, To: []string{"x", "y"}},
+	}
+	if restored.Event != "restored" || !reflect.DeepEqual(restored.Changes, want) {
+		t.Errorf("event = %s %+v, want restored %+v", restored.Event, restored.Changes, want)
+	}
+	if deleted := tl.Events[1]; deleted.Event != "deleted" || deleted.Task.Title != "a" {
+		t.Errorf("event = %s of %q, want deleted of the task before", deleted.Event, deleted.Task.Title)
+	}
+}
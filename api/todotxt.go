@@ -0,0 +1,388 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// todo.txt (https://github.com/todotxt/todo.txt) keeps one task per line:
+//
+//	x 2024-09-02 2024-08-30 Call the bank +finance @phone due:2024-09-02 id:42
+//
+// Contexts are the tags of a task and the last +project its project. Every
+// exported line carries the task id as id:, so an edited file can be
+// imported again onto the same tasks.
+
+const (
+	TodoTxtMerge   = "merge"
+	TodoTxtReplace = "replace"
+)
+
+var todoTxtPriority = regexp.MustCompile(`^\([A-Z]\)$`)
+
+// todoTxtTask is one parsed line. Dates are Unix milliseconds at midnight
+// UTC, 0 when missing.
+type todoTxtTask struct {
+	id        string
+	done      bool
+	priority  string
+	completed int64
+	created   int64
+	due       int64
+	title     string
+	project   string
+	contexts  []string
+}
+
+func parseTodoTxtDate(s string) (int64, bool) {
+	if len(s) != len(time.DateOnly) {
+		return 0, false
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return 0, false
+	}
+	return t.UnixMilli(), true
+}
+
+func todoTxtDate(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format(time.DateOnly)
+}
+
+// parseTodoTxtLine reads one line, false for blank lines. Words the format
+// gives no meaning to, including unknown key:value pairs, stay in the title.
+func parseTodoTxtLine(line string) (todoTxtTask, bool) {
+	var t todoTxtTask
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return t, false
+	}
+	if words[0] == "x" {
+		t.done = true
+		words = words[1:]
+		if len(words) > 0 {
+			if ms, ok := parseTodoTxtDate(words[0]); ok {
+				t.completed = ms
+				words = words[1:]
+			}
+		}
+	} else if todoTxtPriority.MatchString(words[0]) {
+		t.priority = words[0][1:2]
+		words = words[1:]
+	}
+	if len(words) > 0 {
+		if ms, ok := parseTodoTxtDate(words[0]); ok {
+			t.created = ms
+			words = words[1:]
+		}
+	}
+
+	var title []string
+	for _, word := range words {
+		key, value, isPair := strings.Cut(word, ":")
+		switch {
+		case len(word) > 1 && word[0] == '+':
+			if t.project != "" {
+				title = append(title, "+"+t.project)
+			}
+			t.project = word[1:]
+		case len(word) > 1 && word[0] == '@':
+			t.contexts = append(t.contexts, word[1:])
+		case isPair && key == "id" && value != "":
+			t.id = value
+			if id, err := url.PathUnescape(value); err == nil {
+				t.id = id
+			}
+		case isPair && key == "due":
+			if ms, ok := parseTodoTxtDate(value); ok {
+				t.due = ms
+				continue
+			}
+			title = append(title, word)
+		case isPair && key == "pri" && len(value) == 1 && value[0] >= 'A' && value[0] <= 'Z':
+			t.priority = value
+		default:
+			title = append(title, word)
+		}
+	}
+	t.title = strings.Join(title, " ")
+	return t, true
+}
+
+// todoTxtWord makes a project or context one word.
+func todoTxtWord(s string) string {
+	return strings.Join(strings.Fields(s), "_")
+}
+
+// todoTxtLine writes a task as one line. Done tasks keep their priority as
+// pri:, as the format recommends.
+func todoTxtLine(task TaskType, priority string) string {
+	var words []string
+	if task.Done {
+		words = append(words, "x")
+		if task.CompletedAt != 0 {
+			words = append(words, todoTxtDate(task.CompletedAt))
+		}
+	} else if priority != "" {
+		words = append(words, "("+priority+")")
+	}
+	// A done task can only have a creation date after its completion date.
+	if task.CreatedAt != 0 && (!task.Done || task.CompletedAt != 0) {
+		words = append(words, todoTxtDate(task.CreatedAt))
+	}
+	if title := strings.Join(strings.Fields(task.Title), " "); title != "" {
+		words = append(words, title)
+	}
+	if task.Project != "" {
+		words = append(words, "+"+todoTxtWord(task.Project))
+	}
+	for _, tag := range task.Tags {
+		words = append(words, "@"+todoTxtWord(tag))
+	}
+	if task.Due != 0 {
+		words = append(words, "due:"+todoTxtDate(task.Due))
+	}
+	if task.Done && priority != "" {
+		words = append(words, "pri:"+priority)
+	}
+	// Percent-encoded so that an id with spaces comes back unchanged.
+	words = append(words, "id:"+url.PathEscape(task.ID))
+	return strings.Join(words, " ")
+}
+
+// priorityValue reads a priority as a letter. Numbers count from 1 for A.
+func priorityValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		if p := strings.ToUpper(strings.TrimSpace(v)); len(p) == 1 && p[0] >= 'A' && p[0] <= 'Z' {
+			return p
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil && n >= 1 && n <= 26 {
+			return string(rune('A' + n - 1))
+		}
+	}
+	return ""
+}
+
+// setPriority writes a priority as a letter or, if the list numbers its
+// priorities, as a number.
+func (l *taskList) setPriority(item map[string]any, priority string) {
+	key := taskKey(item, "priority")
+	if key == "" {
+		key = l.listKey("priority")
+	}
+	if priority == "" {
+		delete(item, key)
+		return
+	}
+	for _, other := range l.items {
+		if _, isNumber := other[key].(json.Number); isNumber {
+			item[key] = json.Number(strconv.Itoa(int(priority[0]-'A') + 1))
+			return
+		}
+	}
+	item[key] = priority
+}
+
+// patch turns the line into the changes to the task current, nil for a new
+// one. Values todo.txt can't express, like the time of a due date or
+// spaces in a project, are kept when the line doesn't change them.
+func (t todoTxtTask) patch(current *TaskType) TaskPatch {
+	var patch TaskPatch
+	if current == nil || strings.Join(strings.Fields(current.Title), " ") != t.title {
+		patch.Title = &t.title
+	}
+	if current == nil || current.Done != t.done {
+		patch.Done = &t.done
+	}
+	if current == nil || todoTxtWord(current.Project) != t.project {
+		patch.Project = &t.project
+	}
+	changedTags := current == nil || len(current.Tags) != len(t.contexts)
+	for i := 0; !changedTags && i < len(t.contexts); i++ {
+		changedTags = todoTxtWord(current.Tags[i]) != t.contexts[i]
+	}
+	if changedTags {
+		tags := append([]string{}, t.contexts...)
+		patch.Tags = &tags
+	}
+	if current == nil || (current.Due == 0) != (t.due == 0) || (t.due != 0 && todoTxtDate(current.Due) != todoTxtDate(t.due)) {
+		due := ""
+		if t.due != 0 {
+			due = todoTxtDate(t.due)
+		}
+		patch.Due = &due
+	}
+	return patch
+}
+
+// @Summary		Export tasks as todo.txt
+// @Description	Writes the tasks of the newest todolist in the todo.txt format, with the same filters as the task list.
+// @Tags			tasks
+// @Produce		plain
+// @Param			name		path		string	true	"UID Name"
+// @Param			status		query		string	false	"all (default), open or done"
+// @Param			tag			query		string	false	"Only tasks with this tag"
+// @Param			project		query		string	false	"Only tasks of this project"
+// @Success		200			{string}	string		"todo.txt"
+// @Failure		400			{object}	ErrorType	"Invalid filter"
+// @Failure		403			{object}	ErrorType	"UID is disabled"
+// @Failure		404			{object}	ErrorType	"UID not found or no records available"
+// @Failure		422			{object}	ErrorType	"The todolist format is not understood"
+// @Router			/api/v1/ids/{name}/export.txt [get]
+func ExportTodoTxt(c *fiber.Ctx) error {
+	filter, err := parseTaskFilter(c)
+	if err != nil {
+		return badRequest("invalid_filter", "%v", err)
+	}
+	head, list, err := headTasks(c)
+	if err != nil {
+		return err
+	}
+
+	current := head.revision()
+	setRevisionHeaders(c, current)
+	if notModified(c, current) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	var b strings.Builder
+	for i, item := range list.items {
+		task := list.task(i)
+		if task.ID != "" && filter.match(task) {
+			b.WriteString(todoTxtLine(task, priorityValue(item[taskKey(item, "priority")])))
+			b.WriteString("\n")
+		}
+	}
+	c.Set(fiber.HeaderContentType, "text/plain; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="todo.txt"`)
+	return c.SendString(b.String())
+}
+
+// @Summary		Import todo.txt
+// @Description	Applies a todo.txt file to the newest todolist and stores the result as a new snapshot. Lines with an id: update that task, the others are added; mode=replace also removes the tasks the file doesn't list.
+// @Tags			tasks
+// @Accept			plain
+// @Produce		json
+// @Param			name		path		string	true	"UID Name"
+// @Param			mode		query		string	false	"merge (default) or replace"
+// @Param			If-Match	header		string	false	"Only if the todolist is still at this ETag"
+// @Success		200			{object}	TodoTxtResult
+// @Failure		400			{object}	ErrorType	"Invalid mode or task"
+// @Failure		404			{object}	ErrorType	"UID not found or no records available"
+// @Failure		409			{object}	ErrorType	"Another client synced meanwhile"
+// @Failure		412			{object}	ErrorType	"If-Match does not match"
+// @Failure		413			{object}	ErrorType	"Quota exceeded"
+// @Failure		422			{object}	ErrorType	"The todolist format is not understood"
+// @Router			/api/v1/ids/{name}/import.txt [post]
+func ImportTodoTxt(c *fiber.Ctx) error {
+	mode := c.Query("mode", TodoTxtMerge)
+	if mode != TodoTxtMerge && mode != TodoTxtReplace {
+		return badRequest("invalid_mode", "mode must be merge or replace")
+	}
+	head, list, err := headTasks(c)
+	if err != nil {
+		return err
+	}
+
+	var result TodoTxtResult
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(c.Body()))
+	scanner.Buffer(nil, len(c.Body())+1)
+	for n := 1; scanner.Scan(); n++ {
+		line, ok := parseTodoTxtLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		added, changed, err := importTodoTxtLine(list, line, seen)
+		if err != nil {
+			return badRequest("invalid_task", "line %d: %v", n, err)
+		}
+		switch {
+		case added:
+			result.Added++
+		case changed:
+			result.Updated++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return badRequest("invalid_body", "%v", err)
+	}
+
+	if mode == TodoTxtReplace {
+		for i := len(list.ids) - 1; i >= 0; i-- {
+			if list.ids[i] != "" && !seen[list.ids[i]] {
+				list.remove(i)
+				result.Removed++
+			}
+		}
+	}
+
+	if result.Added+result.Updated+result.Removed == 0 {
+		return c.JSON(result)
+	}
+	snapshot, err := saveTasks(c, head, list)
+	if err != nil {
+		return err
+	}
+	result.Time = snapshot.Time
+	return c.JSON(result)
+}
+
+// importTodoTxtLine updates the task the line names or adds a new one, and
+// reports which it did and whether an existing task changed. Lines without
+// a known id, or repeating one, are new tasks.
+func importTodoTxtLine(list *taskList, line todoTxtTask, seen map[string]bool) (added bool, changed bool, err error) {
+	if i := list.find(line.id); line.id != "" && i >= 0 && !seen[line.id] {
+		seen[line.id] = true
+		item := list.items[i]
+		before, err := json.Marshal(item)
+		if err != nil {
+			return false, false, err
+		}
+		current := list.task(i)
+		if err := list.apply(item, line.patch(&current)); err != nil {
+			return false, false, err
+		}
+		if priorityValue(item[taskKey(item, "priority")]) != line.priority {
+			list.setPriority(item, line.priority)
+		}
+		if line.done && !current.Done && line.completed != 0 {
+			list.setTime(item, "completedAt", line.completed)
+		}
+		after, err := json.Marshal(item)
+		return false, !bytes.Equal(before, after), err
+	}
+
+	id, err := list.nextID()
+	if err != nil {
+		return false, false, err
+	}
+	item := list.newItem(id)
+	created := line.created
+	if created == 0 {
+		created = time.Now().UnixMilli()
+	}
+	list.setTime(item, "createdAt", created)
+	if err := list.apply(item, line.patch(nil)); err != nil {
+		return false, false, err
+	}
+	if line.priority != "" {
+		list.setPriority(item, line.priority)
+	}
+	if line.done && line.completed != 0 {
+		list.setTime(item, "completedAt", line.completed)
+	}
+	list.add(id, item)
+	seen[id] = true
+	return true, true, nil
+}
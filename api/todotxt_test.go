@@ -0,0 +1,96 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTodoTxtLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want todoTxtTask
+		ok   bool
+	}{
+		{"", todoTxtTask{}, false},
+		{"   ", todoTxtTask{}, false},
+		{"Call the bank", todoTxtTask{title: "Call the bank"}, true},
+		{
+			"(A) 2024-08-30 Call the bank +finance @phone @town due:2024-09-02 id:42",
+			todoTxtTask{id: "42", priority: "A", created: 1724976000000, due: 1725235200000,
+				title: "Call the bank", project: "finance", contexts: []string{"phone", "town"}},
+			true,
+		},
+		{
+			"x 2024-09-02 2024-08-30 Call the bank pri:B id:42",
+			todoTxtTask{id: "42", done: true, priority: "B", completed: 1725235200000, created: 1724976000000, title: "Call the bank"},
+			true,
+		},
+		// Only the first word can be a priority, and only for open tasks.
+		{"x (A) Call", todoTxtTask{done: true, title: "(A) Call"}, true},
+		{"Call (A)", todoTxtTask{title: "Call (A)"}, true},
+		// The last project wins, the others stay in the title.
+		{"Plan +home +work", todoTxtTask{title: "Plan +home", project: "work"}, true},
+		{"Meet at 10:30 due:friday url:https://a.example", todoTxtTask{title: "Meet at 10:30 due:friday url:https://a.example"}, true},
+		{"Lone + and @ signs", todoTxtTask{title: "Lone + and @ signs"}, true},
+		{"xylophone lesson", todoTxtTask{title: "xylophone lesson"}, true},
+		{"Escaped id:a%20b%2Fc", todoTxtTask{title: "Escaped", id: "a b/c"}, true},
+		{"Invalid escape id:50%", todoTxtTask{title: "Invalid escape", id: "50%"}, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseTodoTxtLine(tt.line)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTodoTxtLine(%q) = %+v, %t, want %+v, %t", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTodoTxtLine(t *testing.T) {
+	tests := []struct {
+		task     TaskType
+		priority string
+		want     string
+	}{
+		{TaskType{ID: "1", Title: "Call the bank"}, "", "Call the bank id:1"},
+		{
+			TaskType{ID: "42", Title: "Call  the\nbank", Project: "my finances", Tags: []string{"phone"},
+				Due: 1725289200000, CreatedAt: 1724976000000},
+			"A",
+			"(A) 2024-08-30 Call the bank +my_finances @phone due:2024-09-02 id:42",
+		},
+		{
+			TaskType{ID: "42", Title: "Call", Done: true, CompletedAt: 1725235200000, CreatedAt: 1724976000000},
+			"B",
+			"x 2024-09-02 2024-08-30 Call pri:B id:42",
+		},
+		// Without a completion date the creation date would be read as one.
+		{TaskType{ID: "42", Title: "Call", Done: true, CreatedAt: 1724976000000}, "", "x Call id:42"},
+		{TaskType{ID: "a b/c", Title: "Call"}, "", "Call id:a%20b%2Fc"},
+	}
+	for _, tt := range tests {
+		if got := todoTxtLine(tt.task, tt.priority); got != tt.want {
+			t.Errorf("todoTxtLine(%+v, %q) = %q, want %q", tt.task, tt.priority, got, tt.want)
+		}
+	}
+}
+
+// An exported line must import as the same task, whatever its id holds.
+func TestTodoTxtRoundTrip(t *testing.T) {
+	tasks := []TaskType{
+		{ID: "42", Title: "Call the bank", Project: "finance", Tags: []string{"phone", "town"}, Due: 1725235200000, CreatedAt: 1724976000000},
+		{ID: "x", Title: "x", Done: true, CompletedAt: 1725235200000},
+		{ID: "id with spaces", Title: "Spaces"},
+		{ID: "50%:+@", Title: "Symbols"},
+		{ID: "ünïcödé", Title: "Unicode"},
+	}
+	for _, task := range tasks {
+		line := todoTxtLine(task, "")
+		got, ok := parseTodoTxtLine(line)
+		if !ok || got.id != task.ID || got.title != task.Title || got.done != task.Done || got.project != task.Project ||
+			got.due != task.Due || got.created != task.CreatedAt || got.completed != task.CompletedAt {
+			t.Errorf("%q reads as %+v, want %+v", line, got, task)
+		}
+		if len(got.contexts) != len(task.Tags) || (len(task.Tags) > 0 && !reflect.DeepEqual(got.contexts, task.Tags)) {
+			t.Errorf("%q has contexts %q, want %q", line, got.contexts, task.Tags)
+		}
+	}
+}
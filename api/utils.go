@@ -4,48 +4,19 @@ import (
 	"crypto/rand"
 	"fmt"
 	"io"
-	"log"
-	"os"
+	"log/slog"
 )
 
-func GetConfig() (configData ConfigType) {
-
-	var config ConfigType
-	config.PSQLURL = os.Getenv("psqlURL")
-
-	if os.Getenv("corsURL") != "" {
-		config.CorsURL = "https://www.axisgtd.work,http://localhost:3000/,http://127.0.0.1:8080," + os.Getenv("corsURL")
-	} else {
-		config.CorsURL = "https://www.axisgtd.work,http://localhost:3000/,http://127.0.0.1:8080"
-
-	}
-
-	if config.PSQLURL == "" {
-		fmt.Println("Please set the environment variable psqlURL")
-		fmt.Println("e.g. export psqlURL=\"user='youruser' password='yourpassword' dbname='yourdbname' sslmode='require'\"")
-		os.Exit(0)
-	}
-	return config
-}
-
-func InitDB() {
-
-	createUIDTableQuery := `
-  	CREATE TABLE IF NOT EXISTS UID (
-  		id serial NOT NULL,
-  		name character varying(100) NOT NULL,
-  		status BOOLEAN NOT NULL,
-  		UNIQUE (name)
-  	)`
-	var err error
-	_, err = db.Exec(createUIDTableQuery)
-	checkerr(err)
-}
-
-func checkerr(err error) {
+// InitDB brings the schema up to date and logs the migrations it applied.
+func InitDB() error {
+	applied, err := Migrate()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	if applied > 0 {
+		slog.Info("applied database migrations", "count", applied, "version", LatestSchemaVersion())
+	}
+	return nil
 }
 
 func GenerateRandomHex(n int) (string, error) {
@@ -58,31 +29,35 @@ func GenerateRandomHex(n int) (string, error) {
 }
 
 func GetName() (string, error) {
-	uidName, err := GenerateRandomHex(5)
-	checkerr(err)
-	var exists bool
-
 	query := "SELECT EXISTS(SELECT 1 FROM UID WHERE name = $1)"
-	err = db.QueryRow(query, uidName).Scan(&exists)
-	checkerr(err)
-
-	for exists {
-		uidName, err = GenerateRandomHex(5)
-		checkerr(err)
-		err = db.QueryRow(query, uidName).Scan(&exists)
-		checkerr(err)
+	for {
+		uidName, err := GenerateRandomHex(5)
+		if err != nil {
+			return "", err
+		}
+		var exists bool
+		if err = db.QueryRow(query, uidName).Scan(&exists); err != nil {
+			return "", err
+		}
+		if !exists {
+			return uidName, nil
+		}
 	}
-
-	return uidName, nil
 }
 
 func DeleteDBRecord(uidName string, time int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
         DELETE FROM axisgtd
         WHERE uid_name = $1 AND time = $2;
     `
 
-	result, err := db.Exec(query, uidName, time)
+	result, err := tx.Exec(query, uidName, time)
 	if err != nil {
 		return err
 	}
@@ -93,16 +68,23 @@ func DeleteDBRecord(uidName string, time int64) error {
 	}
 
 	if affected == 0 {
-		return fmt.Errorf("no records found with uid_name %s and time %d", uidName, time)
+		return fmt.Errorf("no records found with uid_name %s and time %d: %w", uidName, time, ErrNotFound)
 	}
 
+	if err := queueEvent(tx, uidName, EventSnapshotDeleted, snapshotEvent("", time)); err != nil {
+		return fmt.Errorf("error queueing webhooks: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	wakeWebhooks()
 	return nil
 }
 
 func DeleteUIDAndAxisGtdByUID(uidName string) error {
 	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer func() {
 		if p := recover(); p != nil {
@@ -116,7 +98,7 @@ func DeleteUIDAndAxisGtdByUID(uidName string) error {
 	err = tx.QueryRow(countQuery, uidName).Scan(&count)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error checking count in axisgtd: %v", err)
+		return fmt.Errorf("error checking count in axisgtd: %w", err)
 	}
 
 	if count > 0 {
@@ -124,29 +106,43 @@ func DeleteUIDAndAxisGtdByUID(uidName string) error {
 		_, err = tx.Exec(deleteAxisGtdQuery, uidName)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("error deleting from axisgtd: %v", err)
+			return fmt.Errorf("error deleting from axisgtd: %w", err)
 		}
 	}
 
+	// Queued first, the webhooks of the UID are deleted with it.
+	if err = queueEvent(tx, uidName, EventUIDDeleted, nil); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error queueing webhooks: %w", err)
+	}
+
 	deleteUIDQuery := `DELETE FROM uid WHERE name = $1`
 	result, err := tx.Exec(deleteUIDQuery, uidName)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error deleting from UID: %v", err)
+		return fmt.Errorf("error deleting from UID: %w", err)
 	}
 	affectedRows, err := result.RowsAffected()
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error getting affected rows from UID: %v", err)
+		return fmt.Errorf("error getting affected rows from UID: %w", err)
 	}
 	if affectedRows == 0 {
 		tx.Rollback()
-		return fmt.Errorf("no UID record found for name %s", uidName)
+		return fmt.Errorf("no UID record found for name %s: %w", uidName, ErrNotFound)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
+	wakeWebhooks()
 	return nil
 }
+
+func GetUID(uidName string) (UID, error) {
+	var uid UID
+	query := `SELECT name, status FROM UID WHERE name = $1`
+	err := db.QueryRow(query, uidName).Scan(&uid.Name, &uid.Status)
+	return uid, err
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The schemas stay nil unless limits.todolist_schema or limits.config_schema
+// is set, then uploads are checked against them after the JSON syntax.
+var (
+	todolistSchema *jsonSchema
+	configSchema   *jsonSchema
+)
+
+// LoadSchemas reads the JSON Schemas named in the configuration, call it
+// after Configure.
+func LoadSchemas() error {
+	var err error
+	if todolistSchema, err = loadSchema(config.Limits.TodolistSchema); err != nil {
+		return fmt.Errorf("limits.todolist_schema: %v", err)
+	}
+	if configSchema, err = loadSchema(config.Limits.ConfigSchema); err != nil {
+		return fmt.Errorf("limits.config_schema: %v", err)
+	}
+	return nil
+}
+
+func loadSchema(path string) (*jsonSchema, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema := new(jsonSchema)
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return schema, nil
+}
+
+// checkUpload checks an uploaded snapshot against the configured
+// limits. Oversized fields are 413, anything malformed 422.
+func checkUpload(snapshot *AxisGTDType) *APIError {
+	limits := config.Limits
+	if limits.MaxTodolistBytes > 0 && len(snapshot.Todolist) > limits.MaxTodolistBytes {
+		return newError(fiber.StatusRequestEntityTooLarge, "todolist_too_large",
+			"todolist is %d bytes, the limit is %d", len(snapshot.Todolist), limits.MaxTodolistBytes)
+	}
+	if limits.MaxConfigBytes > 0 && len(snapshot.Config) > limits.MaxConfigBytes {
+		return newError(fiber.StatusRequestEntityTooLarge, "config_too_large",
+			"config is %d bytes, the limit is %d", len(snapshot.Config), limits.MaxConfigBytes)
+	}
+
+	if err := validateField("todolist", snapshot.Todolist, todolistSchema); err != nil {
+		return err
+	}
+	if err := validateField("config", snapshot.Config, configSchema); err != nil {
+		return err
+	}
+
+	if snapshot.Time <= 0 {
+		return newError(fiber.StatusUnprocessableEntity, "invalid_time",
+			"time must be a positive Unix time in milliseconds, got %d", snapshot.Time)
+	}
+	if limit := time.Now().Add(limits.MaxClockSkew).UnixMilli(); limits.MaxClockSkew > 0 && snapshot.Time > limit {
+		return newError(fiber.StatusUnprocessableEntity, "invalid_time",
+			"time %s is more than %s in the future", time.UnixMilli(snapshot.Time).UTC().Format(time.RFC3339), limits.MaxClockSkew)
+	}
+	return nil
+}
+
+func validateField(field string, value string, schema *jsonSchema) *APIError {
+	if strings.TrimSpace(value) == "" {
+		return newError(fiber.StatusUnprocessableEntity, "invalid_"+field, "%s must not be empty", field)
+	}
+	var doc any
+	if err := json.Unmarshal([]byte(value), &doc); err != nil {
+		return newError(fiber.StatusUnprocessableEntity, "invalid_"+field, "%s is not valid JSON: %v", field, err)
+	}
+	if schema == nil {
+		return nil
+	}
+	if err := schema.validate(doc, ""); err != nil {
+		return newError(fiber.StatusUnprocessableEntity, "invalid_"+field, "%s does not match the schema: %v", field, err)
+	}
+	return nil
+}
+
+// quotaError rejects adding snapshots taking size more bytes to a UID with
+// the given usage. Adding nothing, or shrinking it, always passes.
+func quotaError(usage IDSType, size int64, snapshots int) *APIError {
+	quota := usage.Quota
+	if quota.MaxBytes > 0 && size > 0 && usage.Bytes+size > quota.MaxBytes {
+		return newError(fiber.StatusRequestEntityTooLarge, "quota_exceeded",
+			"%s stores %d bytes, %d more would exceed the quota of %d, delete or prune old snapshots",
+			usage.Name, usage.Bytes, size, quota.MaxBytes)
+	}
+	if quota.MaxSnapshots > 0 && snapshots > 0 && usage.Count+snapshots > quota.MaxSnapshots {
+		return newError(fiber.StatusRequestEntityTooLarge, "quota_exceeded",
+			"%s stores %d snapshots, %d more would exceed the quota of %d, delete or prune old snapshots",
+			usage.Name, usage.Count, snapshots, quota.MaxSnapshots)
+	}
+	return nil
+}
+
+// jsonSchema is the subset of JSON Schema needed to describe the AxisGTD
+// formats: type, enum, required, properties, additionalProperties, items
+// and the length and range keywords. Other keywords are ignored.
+type jsonSchema struct {
+	Type                 any                    `json:"type"`
+	Enum                 []any                  `json:"enum"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+}
+
+// validate returns the first mismatch, prefixed with the JSON Pointer of
+// the offending value.
+func (s *jsonSchema) validate(v any, path string) error {
+	at := path
+	if at == "" {
+		at = "/"
+	}
+
+	if s.Type != nil && !s.typeMatches(v) {
+		return fmt.Errorf("%s: expected %v, got %s", at, s.Type, jsonType(v))
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if reflect.DeepEqual(allowed, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", at, v, s.Enum)
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		if s.MinLength != nil && len([]rune(v)) < *s.MinLength {
+			return fmt.Errorf("%s: shorter than %d characters", at, *s.MinLength)
+		}
+		if s.MaxLength != nil && len([]rune(v)) > *s.MaxLength {
+			return fmt.Errorf("%s: longer than %d characters", at, *s.MaxLength)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fmt.Errorf("%s: %v is less than %v", at, v, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fmt.Errorf("%s: %v is greater than %v", at, v, *s.Maximum)
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fmt.Errorf("%s: fewer than %d items", at, *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fmt.Errorf("%s: more than %d items", at, *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, key)
+			}
+		}
+		for key, value := range v {
+			property, ok := s.Properties[key]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %q", at, key)
+				}
+				continue
+			}
+			pointer := strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+			if err := property.validate(value, path+"/"+pointer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *jsonSchema) typeMatches(v any) bool {
+	types, ok := s.Type.([]any)
+	if !ok {
+		types = []any{s.Type}
+	}
+	actual := jsonType(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONSchema(t *testing.T) {
+	const schema = `{
+		"type": "object",
+		"required": ["tasks"],
+		"additionalProperties": false,
+		"properties": {
+			"version": {"type": "integer", "minimum": 1, "maximum": 3},
+			"mode": {"enum": ["list", "board"]},
+			"owner/name": {"type": ["string", "null"], "maxLength": 3},
+			"tasks": {
+				"type": "array",
+				"maxItems": 2,
+				"items": {
+					"type": "object",
+					"required": ["title"],
+					"properties": {
+						"title": {"type": "string", "minLength": 1},
+						"done": {"type": "boolean"},
+						"weight": {"type": "number"}
+					}
+				}
+			}
+		}
+	}`
+	var s jsonSchema
+	if err := json.Unmarshal([]byte(schema), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		doc string
+		err string
+	}{
+		{`{"tasks":[]}`, ""},
+		{`{"tasks":[{"title":"a","done":true,"weight":1.5,"extra":1}],"version":2,"mode":"board"}`, ""},
+		{`{"tasks":[{"title":"a","weight":2}]}`, ""},
+		{`{"tasks":[],"owner/name":null}`, ""},
+		{`[]`, "/: expected object, got array"},
+		{`{}`, `/: missing required property "tasks"`},
+		{`{"tasks":[],"other":1}`, `/: unexpected property "other"`},
+		{`{"tasks":{}}`, "/tasks: expected array, got object"},
+		{`{"tasks":[{},{},{}]}`, "/tasks: more than 2 items"},
+		{`{"tasks":[{"title":"a"},{"title":""}]}`, "/tasks/1/title: shorter than 1 characters"},
+		{`{"tasks":[{"title":"a","done":"yes"}]}`, "/tasks/0/done: expected boolean, got string"},
+		{`{"tasks":[],"version":1.5}`, "/version: expected integer, got number"},
+		{`{"tasks":[],"version":4}`, "/version: 4 is greater than 3"},
+		{`{"tasks":[],"version":0}`, "/version: 0 is less than 1"},
+		{`{"tasks":[],"mode":"grid"}`, "/mode: grid is not one of [list board]"},
+		{`{"tasks":[],"owner/name":"four"}`, "/owner~1name: longer than 3 characters"},
+		{`{"tasks":[],"owner/name":"äöü"}`, ""},
+	}
+	for _, tt := range tests {
+		var doc any
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		err := s.validate(doc, "")
+		if tt.err == "" && err != nil {
+			t.Errorf("validate(%s) = %v, want nil", tt.doc, err)
+		}
+		if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("validate(%s) = %v, want %s", tt.doc, err, tt.err)
+		}
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Limits.MaxTodolistBytes = 10
+	cfg.Limits.MaxConfigBytes = 4
+	cfg.Limits.MaxClockSkew = time.Hour
+	useConfig(t, cfg)
+	now := time.Now().UnixMilli()
+
+	tests := []struct {
+		name     string
+		snapshot AxisGTDType
+		status   int
+		code     string
+	}{
+		{"valid", AxisGTDType{Todolist: "[]", Config: "{}", Time: now}, 0, ""},
+		{"todolist too large", AxisGTDType{Todolist: "[1,2,3,4,5]", Config: "{}", Time: now}, 413, "todolist_too_large"},
+		{"config too large", AxisGTDType{Todolist: "[]", Config: `{"a":1}`, Time: now}, 413, "config_too_large"},
+		{"empty todolist", AxisGTDType{Todolist: " ", Config: "{}", Time: now}, 422, "invalid_todolist"},
+		{"invalid config", AxisGTDType{Todolist: "[]", Config: "{", Time: now}, 422, "invalid_config"},
+		{"no time", AxisGTDType{Todolist: "[]", Config: "{}"}, 422, "invalid_time"},
+		{"future", AxisGTDType{Todolist: "[]", Config: "{}", Time: now + 2*time.Hour.Milliseconds()}, 422, "invalid_time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUpload(&tt.snapshot)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("checkUpload = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Status != tt.status || err.Code != tt.code {
+				t.Errorf("checkUpload = %+v, want %d %s", err, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestCheckUploadSchema(t *testing.T) {
+	useConfig(t, DefaultConfig())
+	old := todolistSchema
+	t.Cleanup(func() { todolistSchema = old })
+	todolistSchema = &jsonSchema{Type: "array"}
+
+	err := checkUpload(&AxisGTDType{Todolist: "{}", Config: "{}", Time: 1})
+	if err == nil || err.Code != "invalid_todolist" || !strings.Contains(err.Message, "does not match the schema") {
+		t.Errorf("checkUpload = %+v, want a schema mismatch", err)
+	}
+	if err := checkUpload(&AxisGTDType{Todolist: "[]", Config: "{}", Time: 1}); err != nil {
+		t.Errorf("checkUpload = %v, want nil", err)
+	}
+}
+
+func TestQuotaError(t *testing.T) {
+	usage := IDSType{Name: "abc", Bytes: 900, Count: 9, Quota: QuotaType{MaxBytes: 1000, MaxSnapshots: 10}}
+	tests := []struct {
+		name      string
+		usage     IDSType
+		size      int64
+		snapshots int
+		exceeded  bool
+	}{
+		{"fits", usage, 100, 1, false},
+		{"too many bytes", usage, 101, 1, true},
+		{"too many snapshots", usage, 10, 2, true},
+		{"nothing added", usage, 0, 0, false},
+		// An overwrite that shrinks the data passes even over the quota.
+		{"shrinks", IDSType{Bytes: 2000, Count: 20, Quota: usage.Quota}, -10, 0, false},
+		{"no quota", IDSType{Bytes: 1 << 40, Count: 1 << 20}, 1 << 20, 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := quotaError(tt.usage, tt.size, tt.snapshots)
+			if tt.exceeded && (err == nil || err.Status != 413 || err.Code != "quota_exceeded") {
+				t.Errorf("quotaError = %+v, want quota_exceeded", err)
+			}
+			if !tt.exceeded && err != nil {
+				t.Errorf("quotaError = %v, want nil", err)
+			}
+		})
+	}
+}
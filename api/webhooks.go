@@ -0,0 +1,570 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Webhooks are delivered from an outbox: the change queues a delivery for
+// every subscribed webhook in webhook_deliveries, in the same transaction
+// where it can, and a background worker on every instance sends the due
+// ones. Each worker claims its deliveries with FOR UPDATE SKIP LOCKED, so
+// instances sharing a database don't send one twice.
+
+const (
+	EventSnapshotCreated = "snapshot.created"
+	EventSnapshotDeleted = "snapshot.deleted"
+	EventUIDEnabled      = "uid.enabled"
+	EventUIDDisabled     = "uid.disabled"
+	// EventUIDDeleted is queued before the UID is deleted, its deliveries
+	// outlive the webhooks of the UID.
+	EventUIDDeleted = "uid.deleted"
+)
+
+var webhookEvents = []string{EventSnapshotCreated, EventSnapshotDeleted, EventUIDEnabled, EventUIDDisabled, EventUIDDeleted}
+
+const (
+	// maxWebhooks limits the webhooks of a UID and the global ones.
+	maxWebhooks       = 10
+	maxWebhookURL     = 2000
+	maxDeliveryLimit  = 500
+	deliveryBatchSize = 20
+)
+
+// snapshotEventData is the data of the snapshot events. Imports and prunes
+// create or delete several snapshots in one event.
+type snapshotEventData struct {
+	Times  []int64 `json:"times"`
+	Device string  `json:"device,omitempty"`
+}
+
+func snapshotEvent(device string, times ...int64) snapshotEventData {
+	return snapshotEventData{Times: times, Device: deviceName(device)}
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// queueEvent queues a delivery of an event of a UID for each webhook
+// subscribed to it. q is the transaction of the change, or the database
+// when there is none.
+func queueEvent(q execer, uidName string, event string, data any) error {
+	if !config.Features.Webhooks {
+		return nil
+	}
+	id, err := GenerateRandomHex(32)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	payload, err := json.Marshal(WebhookEventType{ID: id, Event: event, UID: uidName, Time: now, Data: data})
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, url, secret, global, event, payload, created_at, next_attempt)
+		SELECT id, url, secret, uid_name IS NULL, $2, $3, $4, $4
+		FROM webhooks
+		WHERE (uid_name = $1 OR uid_name IS NULL)
+			AND (events = '' OR $2 = ANY(string_to_array(events, ',')))`
+	_, err = q.Exec(query, uidName, event, string(payload), now)
+	return err
+}
+
+var webhookWake = make(chan struct{}, 1)
+
+// wakeWebhooks has the worker of this instance look for due deliveries now
+// rather than at the next interval.
+func wakeWebhooks() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// signWebhook is the X-AxisGTD-Signature of a body.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay is the wait after a failed attempt, doubling from
+// webhooks.retry_delay up to webhooks.max_retry_delay.
+func retryDelay(attempts int) time.Duration {
+	delay := config.Webhooks.RetryDelay
+	for i := 1; i < attempts && delay < config.Webhooks.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, config.Webhooks.MaxRetryDelay)
+}
+
+var errPrivateAddress = errors.New("address is not public, see webhooks.allow_private")
+
+// publicOnly refuses connections to loopback, private and link-local
+// addresses, so the webhooks of a UID can't reach into the server's network.
+// It checks the resolved address, which a hostname can't get around.
+func publicOnly(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+func newWebhookClient(control func(string, string, syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Webhooks.Timeout, Control: control}
+	return &http.Client{
+		Timeout: config.Webhooks.Timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: config.Webhooks.Timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		// A redirect counts as a failure, it could lead anywhere.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookWorker sends the due deliveries until its context is canceled.
+type webhookWorker struct {
+	global *http.Client
+	uid    *http.Client
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var webhooks *webhookWorker
+
+var webhookDeliveries *prometheus.CounterVec
+
+func observeWebhook(result string) {
+	if webhookDeliveries != nil {
+		webhookDeliveries.WithLabelValues(result).Inc()
+	}
+}
+
+// StartWebhooks starts delivering webhooks in the background, call
+// StopWebhooks before closing the database.
+func StartWebhooks() {
+	if !config.Features.Webhooks {
+		return
+	}
+	w := &webhookWorker{global: newWebhookClient(nil), done: make(chan struct{})}
+	w.uid = w.global
+	if !config.Webhooks.AllowPrivate {
+		w.uid = newWebhookClient(publicOnly)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	webhooks = w
+	go w.run(ctx)
+}
+
+// StopWebhooks stops the worker and waits for the deliveries in flight. The
+// ones it cancels are sent again after their claim runs out.
+func StopWebhooks() {
+	if webhooks != nil {
+		webhooks.cancel()
+		<-webhooks.done
+	}
+}
+
+func (w *webhookWorker) run(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(config.Webhooks.Interval)
+	defer ticker.Stop()
+	var pruned time.Time
+
+	for {
+		for {
+			claimed, err := w.deliverDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("webhooks: error claiming deliveries", "error", err)
+			}
+			if claimed < deliveryBatchSize || err != nil {
+				break
+			}
+		}
+		if config.Webhooks.Retention > 0 && time.Since(pruned) > time.Hour {
+			pruned = time.Now()
+			if _, err := PruneDeliveries(time.Now().Add(-config.Webhooks.Retention).UnixMilli()); err != nil {
+				slog.Error("webhooks: error pruning the delivery log", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-webhookWake:
+		}
+	}
+}
+
+type claimedDelivery struct {
+	id       int64
+	event    string
+	payload  string
+	attempts int
+	url      string
+	secret   string
+	global   bool
+}
+
+// deliverDue claims a batch of due deliveries and sends them, it returns
+// how many it claimed. A claim pushes next_attempt past the time an attempt
+// can take, so other workers skip the delivery meanwhile.
+func (w *webhookWorker) deliverDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	lease := now.Add(2*config.Webhooks.Timeout + time.Minute).UnixMilli()
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE next_attempt <= $1
+			ORDER BY next_attempt
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event, payload, attempts, url, secret, global`
+	rows, err := db.QueryContext(ctx, query, now.UnixMilli(), lease, deliveryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var claimed []claimedDelivery
+	for rows.Next() {
+		var d claimedDelivery
+		if err := rows.Scan(&d.id, &d.event, &d.payload, &d.attempts, &d.url, &d.secret, &d.global); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		claimed = append(claimed, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.deliver(ctx, d)
+		}()
+	}
+	wg.Wait()
+	return len(claimed), nil
+}
+
+// deliveryOutcome is the status of a delivery after its attempts-th attempt
+// answered status or failed with err, the time of the next attempt while it
+// is pending and the error to show in the log.
+func deliveryOutcome(attempts int, status int, err error, now time.Time) (string, sql.NullInt64, string) {
+	if err == nil && status >= 200 && status <= 299 {
+		return DeliveryDelivered, sql.NullInt64{}, ""
+	}
+	message := "unexpected status " + strconv.Itoa(status)
+	if err != nil {
+		message = err.Error()
+	}
+	if attempts < config.Webhooks.MaxAttempts {
+		return DeliveryPending, sql.NullInt64{Int64: now.Add(retryDelay(attempts)).UnixMilli(), Valid: true}, message
+	}
+	return DeliveryFailed, sql.NullInt64{}, message
+}
+
+// deliver makes one attempt and records its outcome.
+func (w *webhookWorker) deliver(ctx context.Context, d claimedDelivery) {
+	client := w.uid
+	if d.global {
+		client = w.global
+	}
+	status, err := sendWebhook(ctx, client, d)
+	if ctx.Err() != nil {
+		return
+	}
+
+	attempts := d.attempts + 1
+	now := time.Now()
+	result, next, message := deliveryOutcome(attempts, status, err, now)
+	observeWebhook(result)
+	if result != DeliveryDelivered {
+		slog.Warn("webhooks: delivery failed", "delivery", d.id, "event", d.event, "attempts", attempts, "error", message)
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, response_status = $4, error = $5, last_attempt = $6, next_attempt = $7
+		WHERE id = $1`
+	if _, err := db.Exec(query, d.id, result, attempts, status, message, now.UnixMilli(), next); err != nil {
+		slog.Error("webhooks: error recording delivery", "delivery", d.id, "error", err)
+	}
+}
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// sendWebhook posts the payload and returns the response status.
+func sendWebhook(ctx context.Context, client *http.Client, d claimedDelivery) (int, error) {
+	body := []byte(d.payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AxisGTDSync-Webhook/"+Version)
+	req.Header.Set("X-AxisGTD-Event", d.event)
+	req.Header.Set("X-AxisGTD-Delivery", strconv.FormatInt(d.id, 10))
+	req.Header.Set("X-AxisGTD-Signature", signWebhook(d.secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, nil
+}
+
+// webhookOwner returns the UID of the webhook routes, "" on the global
+// ones, after checking that it exists.
+func webhookOwner(c *fiber.Ctx) (string, error) {
+	name := c.Params("name")
+	if name == "" {
+		return "", nil
+	}
+	if _, err := GetUID(name); err == sql.ErrNoRows {
+		return "", uidNotFound(name)
+	} else if err != nil {
+		return "", storeError(err, "Webhook Failed")
+	}
+	return name, nil
+}
+
+// pathID reads the numeric id in a route parameter, a malformed one can't
+// exist and answers 404 with code.
+func pathID(c *fiber.Ctx, param string, code string) (int64, error) {
+	id, err := strconv.ParseInt(c.Params(param), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, notFound(code, "%s %q not found", param, c.Params(param))
+	}
+	return id, nil
+}
+
+func validateWebhook(req *WebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(req.URL) > maxWebhookURL {
+		return badRequest("invalid_url", "url must be an http or https URL of at most %d bytes", maxWebhookURL)
+	}
+	var events []string
+	for _, event := range req.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return badRequest("invalid_event", "unknown event %q, events are %v", event, webhookEvents)
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if events == nil {
+		events = []string{}
+	}
+	req.Events = events
+	return nil
+}
+
+// @Summary		Create a webhook
+// @Description	Subscribes a URL to the events of a UID, or with the admin token to the events of every UID. Events are snapshot.created, snapshot.deleted, uid.enabled, uid.disabled and uid.deleted, no events means all of them. Every request is a JSON POST signed with the returned secret as X-AxisGTD-Signature: sha256=<hex HMAC-SHA256 of the body>.
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			name	path		string			true	"UID Name"
+// @Param			webhook	body		WebhookRequest	true	"URL and events"
+// @Success		201		{object}	WebhookType
+// @Failure		400		{object}	ErrorType	"Invalid URL or event"
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Failure		409		{object}	ErrorType	"Too many webhooks"
+// @Router			/api/v1/ids/{name}/webhooks [post]
+// @Router			/api/v1/webhooks [post]
+func CreateWebhook(c *fiber.Ctx) error {
+	owner, err := webhookOwner(c)
+	if err != nil {
+		return err
+	}
+	var req WebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return &APIError{Status: fiber.StatusBadRequest, Code: "invalid_body", Message: "Invalid request body", Err: err}
+	}
+	if err := validateWebhook(&req); err != nil {
+		return err
+	}
+
+	hooks, err := ListWebhooks(owner)
+	if err != nil {
+		return storeError(err, "Webhook Failed")
+	}
+	if len(hooks) >= maxWebhooks {
+		return newError(fiber.StatusConflict, "too_many_webhooks", "at most %d webhooks, delete one first", maxWebhooks)
+	}
+	secret, err := GenerateRandomHex(64)
+	if err != nil {
+		return storeError(err, "Webhook Failed")
+	}
+	hook, err := AddWebhook(owner, req.URL, req.Events, secret)
+	if err != nil {
+		return storeError(err, "Webhook Failed")
+	}
+	return c.Status(fiber.StatusCreated).JSON(hook)
+}
+
+// @Summary		List webhooks
+// @Description	Lists the webhooks of a UID, or with the admin token the global ones. Secrets are only shown when a webhook is created.
+// @Tags			webhooks
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Success		200		{array}		WebhookType
+// @Failure		404		{object}	ErrorType	"UID not found"
+// @Router			/api/v1/ids/{name}/webhooks [get]
+// @Router			/api/v1/webhooks [get]
+func GetWebhooks(c *fiber.Ctx) error {
+	owner, err := webhookOwner(c)
+	if err != nil {
+		return err
+	}
+	hooks, err := ListWebhooks(owner)
+	if err != nil {
+		return storeError(err, "Webhook Failed")
+	}
+	return c.JSON(hooks)
+}
+
+// @Summary		Delete a webhook
+// @Description	Deletes a webhook with its delivery log, pending deliveries are dropped.
+// @Tags			webhooks
+// @Param			name	path	string	true	"UID Name"
+// @Param			id		path	int		true	"Webhook id"
+// @Success		204
+// @Failure		404	{object}	ErrorType	"UID or webhook not found"
+// @Router			/api/v1/ids/{name}/webhooks/{id} [delete]
+// @Router			/api/v1/webhooks/{id} [delete]
+func DeleteWebhook(c *fiber.Ctx) error {
+	owner, err := webhookOwner(c)
+	if err != nil {
+		return err
+	}
+	id, err := pathID(c, "id", "webhook_not_found")
+	if err != nil {
+		return err
+	}
+	err = RemoveWebhook(owner, id)
+	if err == sql.ErrNoRows {
+		return notFound("webhook_not_found", "webhook %d not found", id)
+	}
+	if err != nil {
+		return storeError(err, "Webhook Failed")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// @Summary		Webhook delivery log
+// @Description	Lists the newest deliveries of a webhook with their payload and the outcome of the last attempt.
+// @Tags			webhooks
+// @Produce		json
+// @Param			name	path		string	true	"UID Name"
+// @Param			id		path		int		true	"Webhook id"
+// @Param			limit	query		int		false	"Maximum number of deliveries, 50 by default"
+// @Success		200		{array}		WebhookDeliveryType
+// @Failure		400		{object}	ErrorType	"Invalid limit"
+// @Failure		404		{object}	ErrorType	"UID or webhook not found"
+// @Router			/api/v1/ids/{name}/webhooks/{id}/deliveries [get]
+// @Router			/api/v1/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	owner, err := webhookOwner(c)
+	if err != nil {
+		return err
+	}
+	id, err := pathID(c, "id", "webhook_not_found")
+	if err != nil {
+		return err
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > maxDeliveryLimit {
+		return badRequest("invalid_limit", "limit must be between 1 and %d", maxDeliveryLimit)
+	}
+	deliveries, err := ListDeliveries(owner, id, limit)
+	if err == sql.ErrNoRows {
+		return notFound("webhook_not_found", "webhook %d not found", id)
+	}
+	if err != nil {
+		return storeError(err, "Webhook Failed")
+	}
+	return c.JSON(deliveries)
+}
+
+// @Summary		Replay a webhook delivery
+// @Description	Queues the payload of a delivery again as a new delivery, e.g. after the receiver was down longer than the retries last. The payload keeps its event id.
+// @Tags			webhooks
+// @Produce		json
+// @Param			name		path		string	true	"UID Name"
+// @Param			id			path		int		true	"Webhook id"
+// @Param			delivery	path		int		true	"Delivery id"
+// @Success		202			{object}	WebhookDeliveryType
+// @Failure		404			{object}	ErrorType	"UID, webhook or delivery not found"
+// @Router			/api/v1/ids/{name}/webhooks/{id}/deliveries/{delivery}/replay [post]
+// @Router			/api/v1/webhooks/{id}/deliveries/{delivery}/replay [post]
+func ReplayWebhookDelivery(c *fiber.Ctx) error {
+	owner, err := webhookOwner(c)
+	if err != nil {
+		return err
+	}
+	id, err := pathID(c, "id", "webhook_not_found")
+	if err != nil {
+		return err
+	}
+	deliveryID, err := pathID(c, "delivery", "delivery_not_found")
+	if err != nil {
+		return err
+	}
+	delivery, err := ReplayDelivery(owner, id, deliveryID)
+	if err == sql.ErrNoRows {
+		return notFound("delivery_not_found", "delivery %d of webhook %d not found", deliveryID, id)
+	}
+	if err != nil {
+		return storeError(err, "Webhook Failed")
+	}
+	wakeWebhooks()
+	return c.Status(fiber.StatusAccepted).JSON(delivery)
+}
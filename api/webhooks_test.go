@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useConfig sets the configuration for one test.
+func useConfig(t *testing.T, cfg ConfigType) {
+	t.Helper()
+	old := config
+	config = cfg
+	t.Cleanup(func() { config = old })
+}
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		{"secret", `{"event":"snapshot.created"}`, "sha256=2bcbd258c84607403d3f1059483bbdd7978f685e0640d87a1d24a4978ba713c2"},
+		{"", "", "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+	}
+	for _, tt := range tests {
+		if got := signWebhook(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("signWebhook(%q, %q) = %s, want %s", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	useConfig(t, DefaultConfig())
+	d := claimedDelivery{id: 7, event: EventSnapshotCreated, payload: `{"id":"abc"}`, secret: "s3cret"}
+
+	var got http.Header
+	var body []byte
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	d.url = srv.URL
+
+	code, err := sendWebhook(context.Background(), newWebhookClient(nil), d)
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("sendWebhook = %d, %v", code, err)
+	}
+	if string(body) != d.payload {
+		t.Errorf("body = %s, want %s", body, d.payload)
+	}
+	if !hmac.Equal([]byte(got.Get("X-AxisGTD-Signature")), []byte(signWebhook(d.secret, body))) {
+		t.Errorf("X-AxisGTD-Signature = %s doesn't match the body", got.Get("X-AxisGTD-Signature"))
+	}
+	for header, want := range map[string]string{
+		"Content-Type":       "application/json",
+		"X-AxisGTD-Event":    EventSnapshotCreated,
+		"X-AxisGTD-Delivery": "7",
+	} {
+		if got.Get(header) != want {
+			t.Errorf("%s = %q, want %q", header, got.Get(header), want)
+		}
+	}
+
+	// A receiver that fails leaves the delivery pending for a retry.
+	status = http.StatusServiceUnavailable
+	code, err = sendWebhook(context.Background(), newWebhookClient(nil), d)
+	if err != nil || code != http.StatusServiceUnavailable {
+		t.Fatalf("sendWebhook = %d, %v", code, err)
+	}
+	if result, _, _ := deliveryOutcome(1, code, err, time.Now()); result != DeliveryPending {
+		t.Errorf("outcome of a 503 = %s, want %s", result, DeliveryPending)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Webhooks.RetryDelay = 30 * time.Second
+	cfg.Webhooks.MaxRetryDelay = 6 * time.Hour
+	useConfig(t, cfg)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{10, 4*time.Hour + 16*time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestPublicOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"[fd00::1]:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"224.0.0.1:80", false},
+		{"example.com:80", false},
+	}
+	for _, tt := range tests {
+		err := publicOnly("tcp", tt.address, nil)
+		if tt.public && err != nil {
+			t.Errorf("publicOnly(%s) = %v, want nil", tt.address, err)
+		}
+		if !tt.public && err == nil {
+			t.Errorf("publicOnly(%s) = nil, want an error", tt.address)
+		}
+	}
+}
+
+func TestPublicOnlyClient(t *testing.T) {
+	useConfig(t, DefaultConfig())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	d := claimedDelivery{id: 1, event: EventSnapshotCreated, payload: "{}", url: srv.URL}
+
+	// The test server listens on loopback, like a service next to the
+	// sync server would.
+	_, err := sendWebhook(context.Background(), newWebhookClient(publicOnly), d)
+	if !errors.Is(err, errPrivateAddress) {
+		t.Errorf("sendWebhook to %s = %v, want %v", srv.URL, err, errPrivateAddress)
+	}
+	if code, err := sendWebhook(context.Background(), newWebhookClient(nil), d); err != nil || code != http.StatusOK {
+		t.Errorf("sendWebhook with private addresses allowed = %d, %v", code, err)
+	}
+}
+
+func TestDeliveryOutcome(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Webhooks.MaxAttempts = 3
+	cfg.Webhooks.RetryDelay = time.Minute
+	cfg.Webhooks.MaxRetryDelay = time.Hour
+	useConfig(t, cfg)
+	now := time.UnixMilli(1725000000000)
+
+	tests := []struct {
+		name     string
+		attempts int
+		status   int
+		err      error
+		want     string
+		next     time.Duration
+		message  string
+	}{
+		{"ok", 1, 200, nil, DeliveryDelivered, 0, ""},
+		{"no content", 2, 204, nil, DeliveryDelivered, 0, ""},
+		{"server error", 1, 500, nil, DeliveryPending, time.Minute, "unexpected status 500"},
+		{"redirect", 2, 302, nil, DeliveryPending, 2 * time.Minute, "unexpected status 302"},
+		{"unreachable", 1, 0, errPrivateAddress, DeliveryPending, time.Minute, errPrivateAddress.Error()},
+		{"last attempt", 3, 500, nil, DeliveryFailed, 0, "unexpected status 500"},
+		{"last attempt delivered", 3, 200, nil, DeliveryDelivered, 0, ""},
+		// A replay is a new delivery, so it gets every attempt again even
+		// though the one it copies has failed.
+		{"replayed", 1, 503, nil, DeliveryPending, time.Minute, "unexpected status 503"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, next, message := deliveryOutcome(tt.attempts, tt.status, tt.err, now)
+			if result != tt.want || message != tt.message {
+				t.Errorf("deliveryOutcome = %s, %q, want %s, %q", result, message, tt.want, tt.message)
+			}
+			if tt.next == 0 && next.Valid {
+				t.Errorf("next attempt = %d, want none", next.Int64)
+			}
+			if want := now.Add(tt.next).UnixMilli(); tt.next != 0 && (!next.Valid || next.Int64 != want) {
+				t.Errorf("next attempt = %v, want %d", next, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"AxisGTDSync/api"
+)
+
+const usage = `Usage: AxisGTDSync <command> [flags] [arguments]
+
+Commands:
+  serve                              start the sync server (default)
+  migrate                            apply pending database migrations
+  id create                          create a new UID
+  id list                            list every UID
+  id disable|enable|delete <name>    change or delete a UID
+  id quota [-bytes n] [-snapshots n] <name>
+                                     set the quota of a UID, 0 uses the limits.max_uid_* defaults
+  id token [-revoke] <name>          create or revoke the read token of the calendar feed
+  id share [-revoke] <name>          create or revoke the token of the shared task list
+  id caldav [-revoke] <name>         create or revoke the CalDAV password
+  snapshot list <name>               list the snapshots of a UID
+  snapshot show <name> <time>        print one snapshot
+  snapshot delete <name> <time>      delete one snapshot
+  snapshot restore <name> <time>     store a copy of a snapshot as the newest one
+  export [-o file] [name...]         write an export archive, of every UID when no name is given
+  import [-mode m] [-name uid] file  import an archive or client backup, "-" reads stdin
+  prune [-keep n] [-before t] [name] delete old snapshots, of every UID when no name is given
+  config print [-secrets]            print the effective configuration and validate it
+  healthcheck                        ask /healthz of the server on this host, for container health checks
+
+Every command reads the config file given by -config (or $configFile), then
+the environment, then -listen and -set key=value flags.
+
+Flags go before the arguments. Every command except serve, export and
+healthcheck accepts -json to print JSON instead of text.
+`
+
+func run(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return serve(args)
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "serve":
+		return serve(args)
+	case "migrate":
+		return migrateCommand(args)
+	case "id":
+		return idCommand(args)
+	case "snapshot":
+		return snapshotCommand(args)
+	case "export":
+		return exportCommand(args)
+	case "import":
+		return importCommand(args)
+	case "prune":
+		return pruneCommand(args)
+	case "config":
+		return configCommand(args)
+	case "healthcheck":
+		return healthcheckCommand(args)
+	case "help":
+		fmt.Print(usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", cmd, usage)
+}
+
+// errFlags is returned for flags that don't parse. The flag set has printed
+// the error and the usage already.
+var errFlags = errors.New("invalid flags")
+
+// newFlagSet returns a flag set with the -json and config flags every
+// command shares.
+func newFlagSet(name string) (*flag.FlagSet, *bool, *configFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fmt.Fprintf(fs.Output(), "\nFlags of %s:\n", name)
+		fs.PrintDefaults()
+	}
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	return fs, asJSON, addConfigFlags(fs)
+}
+
+// parseFlags parses the flags of a command. -h and -help come back as
+// flag.ErrHelp.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && err != flag.ErrHelp {
+		return fmt.Errorf("%w: %v", errFlags, err)
+	}
+	return err
+}
+
+// openDB loads the configuration and opens the database.
+func openDB(cf *configFlags) error {
+	cfg, err := cf.load()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := api.SetupLogging(cfg.Log); err != nil {
+		return err
+	}
+	api.Configure(cfg)
+	return api.OpenDB()
+}
+
+// openReader opens the database for a command that only reads, which leaves
+// migrating to the ones that write. It refuses a schema behind this release,
+// whose missing columns would fail with SQL errors.
+func openReader(cf *configFlags) error {
+	if err := openDB(cf); err != nil {
+		return err
+	}
+	version, err := api.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	return schemaError(version, api.LatestSchemaVersion())
+}
+
+func schemaError(version int, latest int) error {
+	if version < latest {
+		return fmt.Errorf("database schema is at %d, run `migrate`", version)
+	}
+	return nil
+}
+
+// openStore opens the database and brings the schema up to date, exactly
+// like serve does on startup.
+func openStore(cf *configFlags) error {
+	if err := openDB(cf); err != nil {
+		return err
+	}
+	_, err := api.Migrate()
+	return err
+}
+
+// printResult writes v as JSON or calls text for the human-readable form.
+func printResult(asJSON bool, v any, text func(w io.Writer)) error {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	text(w)
+	return w.Flush()
+}
+
+func subcommand(args []string, name string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("%s needs a subcommand\n\n%s", name, usage)
+	}
+	return args[0], args[1:], nil
+}
+
+func parseTime(s string) (int64, error) {
+	t, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snapshot time %q", s)
+	}
+	return t, nil
+}
+
+func migrateCommand(args []string) error {
+	fs, asJSON, cf := newFlagSet("migrate")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := openDB(cf); err != nil {
+		return err
+	}
+	applied, err := api.Migrate()
+	if err != nil {
+		return err
+	}
+	version, err := api.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	result := map[string]int{"applied": applied, "version": version}
+	return printResult(*asJSON, result, func(w io.Writer) {
+		fmt.Fprintf(w, "applied %d migrations, schema version %d\n", applied, version)
+	})
+}
+
+func idCommand(args []string) error {
+	sub, args, err := subcommand(args, "id")
+	if err != nil {
+		return err
+	}
+	fs, asJSON, cf := newFlagSet("id " + sub)
+	var quota api.QuotaType
+	if sub == "quota" {
+		fs.Int64Var(&quota.MaxBytes, "bytes", 0, "maximum bytes stored across all snapshots")
+		fs.IntVar(&quota.MaxSnapshots, "snapshots", 0, "maximum number of snapshots")
+	}
+	revoke := false
+	if sub == "token" {
+		fs.BoolVar(&revoke, "revoke", false, "remove the read token, which disables the calendar feed")
+	}
+	if sub == "share" {
+		fs.BoolVar(&revoke, "revoke", false, "remove the share token, which ends the sharing")
+	}
+	if sub == "caldav" {
+		fs.BoolVar(&revoke, "revoke", false, "remove the CalDAV password, which signs out every CalDAV client")
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	switch sub {
+	case "create":
+		if err := openStore(cf); err != nil {
+			return err
+		}
+		name, err := api.CreateUID()
+		if err != nil {
+			return err
+		}
+		return printResult(*asJSON, map[string]string{"name": name}, func(w io.Writer) {
+			fmt.Fprintln(w, name)
+		})
+	case "list":
+		if err := openReader(cf); err != nil {
+			return err
+		}
+		ids, err := api.ListIDs()
+		if err != nil {
+			return err
+		}
+		return printResult(*asJSON, ids, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tNAME\tSTATUS\tSNAPSHOTS\tBYTES\tLARGEST\tLAST SYNC\tQUOTA")
+			for _, id := range ids {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%s\t%s\n", id.Id, id.Name, statusText(id.Status), id.Count,
+					id.Bytes, id.LargestBytes, lastSyncText(id.LastSync), quotaText(id.Quota))
+			}
+		})
+	case "quota":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("id quota needs exactly one UID name")
+		}
+		if quota.MaxBytes < 0 || quota.MaxSnapshots < 0 {
+			return fmt.Errorf("-bytes and -snapshots must not be negative")
+		}
+		name := fs.Arg(0)
+		if err := openStore(cf); err != nil {
+			return err
+		}
+		if err := api.SetUIDQuota(name, quota); err != nil {
+			return fmt.Errorf("quota %s: %v", name, err)
+		}
+		id, err := api.LookupID(name)
+		if err != nil {
+			return err
+		}
+		return printResult(*asJSON, id, func(w io.Writer) {
+			fmt.Fprintf(w, "%s: %s, using %d bytes in %d snapshots\n", name, quotaText(id.Quota), id.Bytes, id.Count)
+		})
+	case "token":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("id token needs exactly one UID name")
+		}
+		name := fs.Arg(0)
+		if err := openStore(cf); err != nil {
+			return err
+		}
+		token := ""
+		if !revoke {
+			if token, err = api.GenerateRandomHex(32); err != nil {
+				return err
+			}
+		}
+		if err := api.SetReadToken(name, token); err != nil {
+			return fmt.Errorf("token %s: %v", name, err)
+		}
+		return printResult(*asJSON, map[string]string{"name": name, "token": token}, func(w io.Writer) {
+			if revoke {
+				fmt.Fprintf(w, "%s: read token revoked\n", name)
+				return
+			}
+			fmt.Fprintf(w, "%s\n/feeds/%s.ics\n", token, token)
+		})
+	case "share":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("id share needs exactly one UID name")
+		}
+		name := fs.Arg(0)
+		if err := openStore(cf); err != nil {
+			return err
+		}
+		token := ""
+		if !revoke {
+			if token, err = api.GenerateRandomHex(32); err != nil {
+				return err
+			}
+		}
+		if err := api.SetShareToken(name, token); err != nil {
+			return fmt.Errorf("share %s: %v", name, err)
+		}
+		return printResult(*asJSON, map[string]string{"name": name, "token": token}, func(w io.Writer) {
+			if revoke {
+				fmt.Fprintf(w, "%s: share token revoked\n", name)
+				return
+			}
+			fmt.Fprintf(w, "%s\n/share/%s\n/share/%s.md\n", token, token, token)
+		})
+	case "caldav":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("id caldav needs exactly one UID name")
+		}
+		name := fs.Arg(0)
+		if err := openStore(cf); err != nil {
+			return err
+		}
+		secret := ""
+		if !revoke {
+			if secret, err = api.GenerateRandomHex(32); err != nil {
+				return err
+			}
+		}
+		if err := api.SetDAVSecret(name, secret); err != nil {
+			return fmt.Errorf("caldav %s: %v", name, err)
+		}
+		return printResult(*asJSON, map[string]string{"name": name, "secret": secret}, func(w io.Writer) {
+			if revoke {
+				fmt.Fprintf(w, "%s: CalDAV password revoked\n", name)
+				return
+			}
+			fmt.Fprintf(w, "%s\n/dav/%s/\n", secret, name)
+		})
+	case "disable", "enable", "delete":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("id %s needs exactly one UID name", sub)
+		}
+		name := fs.Arg(0)
+		if err := openStore(cf); err != nil {
+			return err
+		}
+		if sub == "delete" {
+			err = api.DeleteUIDAndAxisGtdByUID(name)
+		} else {
+			err = api.SetUIDStatus(name, sub == "enable")
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %v", sub, name, err)
+		}
+		return printResult(*asJSON, map[string]string{"name": name, "action": sub}, func(w io.Writer) {
+			fmt.Fprintf(w, "%s: %sd\n", name, sub)
+		})
+	}
+	return fmt.Errorf("unknown id subcommand %q\n\n%s", sub, usage)
+}
+
+func snapshotCommand(args []string) error {
+	sub, args, err := subcommand(args, "snapshot")
+	if err != nil {
+		return err
+	}
+	fs, asJSON, cf := newFlagSet("snapshot " + sub)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if sub == "list" {
+		if fs.NArg() != 1 {
+			return fmt.Errorf("snapshot list needs exactly one UID name")
+		}
+		if err := openReader(cf); err != nil {
+			return err
+		}
+		snapshots, err := api.ListSnapshots(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return printResult(*asJSON, snapshots, func(w io.Writer) {
+			fmt.Fprintln(w, "TIME\tDATE\tTODOLIST BYTES\tCONFIG BYTES\tDEVICE")
+			for _, s := range snapshots {
+				fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\n", s.Time, formatTime(s.Time), s.TodolistBytes, s.ConfigBytes, s.Device)
+			}
+		})
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("snapshot %s needs a UID name and a snapshot time", sub)
+	}
+	name := fs.Arg(0)
+	at, err := parseTime(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "show":
+		if err := openReader(cf); err != nil {
+			return err
+		}
+		snapshot, err := api.GetSnapshot(name, at)
+		if err != nil {
+			return fmt.Errorf("snapshot %d of %s: %v", at, name, err)
+		}
+		return printResult(*asJSON, snapshot, func(w io.Writer) {
+			fmt.Fprintf(w, "name:\t%s\ntime:\t%d (%s)\nstatus:\t%s\n", snapshot.Name, snapshot.Time,
+				formatTime(snapshot.Time), statusText(snapshot.Status))
+			fmt.Fprintf(w, "todolist:\n%s\nconfig:\n%s\n", snapshot.Todolist, snapshot.Config)
+		})
+	case "delete":
+		if err := openStore(cf); err != nil {
+			return err
+		}
+		if err := api.DeleteDBRecord(name, at); err != nil {
+			return err
+		}
+		return printResult(*asJSON, map[string]any{"name": name, "deleted": at}, func(w io.Writer) {
+			fmt.Fprintf(w, "deleted snapshot %d of %s\n", at, name)
+		})
+	case "restore":
+		if err := openStore(cf); err != nil {
+			return err
+		}
+		restored, err := api.RestoreSnapshot(name, at, "command line")
+		if err != nil {
+			return fmt.Errorf("snapshot %d of %s: %v", at, name, err)
+		}
+		return printResult(*asJSON, map[string]any{"name": name, "from": at, "time": restored}, func(w io.Writer) {
+			fmt.Fprintf(w, "restored snapshot %d of %s as %d\n", at, name, restored)
+		})
+	}
+	return fmt.Errorf("unknown snapshot subcommand %q\n\n%s", sub, usage)
+}
+
+func exportCommand(args []string) error {
+	fs, _, cf := newFlagSet("export")
+	out := fs.String("o", "-", "write the archive to this file, - is stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := openReader(cf); err != nil {
+		return err
+	}
+
+	for _, name := range fs.Args() {
+		if _, err := api.GetUID(name); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if *out == "-" {
+		return api.WriteExport(os.Stdout, fs.Args())
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := api.WriteExport(f, fs.Args()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func importCommand(args []string) error {
+	fs, asJSON, cf := newFlagSet("import")
+	mode := fs.String("mode", api.ImportSkip, "conflict mode: skip, overwrite or new")
+	name := fs.String("name", "", "import into this UID instead of the names in the archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("import needs exactly one file, - reads stdin")
+	}
+	if *mode != api.ImportSkip && *mode != api.ImportOverwrite && *mode != api.ImportNew {
+		return fmt.Errorf("mode must be skip, overwrite or new")
+	}
+
+	in := os.Stdin
+	if fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if err := openStore(cf); err != nil {
+		return err
+	}
+	if err := api.LoadSchemas(); err != nil {
+		return err
+	}
+	result, err := api.ImportArchive(in, api.ImportOptions{Mode: *mode, Name: *name, Create: true})
+	if err != nil {
+		return err
+	}
+	return printResult(*asJSON, result, func(w io.Writer) {
+		fmt.Fprintf(w, "imported %d, skipped %d, overwritten %d snapshots into %s\n",
+			result.Imported, result.Skipped, result.Overwritten, strings.Join(result.UIDs, ", "))
+	})
+}
+
+func pruneCommand(args []string) error {
+	fs, asJSON, cf := newFlagSet("prune")
+	keep := fs.Int("keep", 0, "keep this many of the newest snapshots per UID")
+	before := fs.String("before", "", "delete snapshots older than a duration (e.g. 720h, 30d) or a unix time in milliseconds")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		return fmt.Errorf("prune takes at most one UID name")
+	}
+	var cutoff int64
+	if *before != "" {
+		var err error
+		if cutoff, err = parseBefore(*before); err != nil {
+			return err
+		}
+	}
+
+	if err := openStore(cf); err != nil {
+		return err
+	}
+	deleted, err := api.PruneSnapshots(fs.Arg(0), *keep, cutoff)
+	if err != nil {
+		return err
+	}
+	return printResult(*asJSON, map[string]int64{"deleted": deleted}, func(w io.Writer) {
+		fmt.Fprintf(w, "deleted %d snapshots\n", deleted)
+	})
+}
+
+// healthcheckCommand asks the server at the configured listen address,
+// so the health check of a container follows listen or $listenAddr.
+func healthcheckCommand(args []string) error {
+	fs, _, cf := newFlagSet("healthcheck")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := cf.load()
+	if err != nil {
+		return err
+	}
+	url, err := healthURL(cfg.Listen)
+	if err != nil {
+		return err
+	}
+	return checkHealth(url)
+}
+
+// healthURL is the /healthz URL of a server listening on listen. A server
+// listening on every interface is asked on the loopback one.
+func healthURL(listen string) (string, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("listen %q: %v", listen, err)
+	}
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/healthz", nil
+}
+
+func checkHealth(url string) error {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// parseBefore accepts a unix time in milliseconds or a duration before now,
+// with "d" allowed as a unit of days.
+func parseBefore(s string) (int64, error) {
+	if t, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Now().AddDate(0, 0, -n).UnixMilli(), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return time.Now().Add(-d).UnixMilli(), nil
+}
+
+func formatTime(ms int64) string {
+	return time.UnixMilli(ms).Format(time.DateTime)
+}
+
+func statusText(status bool) string {
+	if status {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func lastSyncText(ms int64) string {
+	if ms == 0 {
+		return "never"
+	}
+	return formatTime(ms)
+}
+
+func quotaText(quota api.QuotaType) string {
+	limit := func(n int64, unit string) string {
+		if n == 0 {
+			return "unlimited " + unit
+		}
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return limit(quota.MaxBytes, "bytes") + ", " + limit(int64(quota.MaxSnapshots), "snapshots")
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"AxisGTDSync/api"
+)
+
+// The cases fail before a command opens the database.
+func TestRun(t *testing.T) {
+	stderr := os.Stderr
+	t.Cleanup(func() { os.Stderr = stderr })
+	if null, err := os.Open(os.DevNull); err == nil {
+		os.Stderr = null
+		defer null.Close()
+	}
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"other"}, `unknown command "other"`},
+		{[]string{"id"}, "id needs a subcommand"},
+		{[]string{"id", "rename", "abc"}, `unknown id subcommand "rename"`},
+		{[]string{"id", "quota", "-bytes", "5"}, "id quota needs exactly one UID name"},
+		{[]string{"id", "quota", "-bytes", "-5", "abc"}, "must not be negative"},
+		{[]string{"id", "token", "abc", "def"}, "id token needs exactly one UID name"},
+		{[]string{"id", "delete"}, "id delete needs exactly one UID name"},
+		{[]string{"snapshot", "list"}, "snapshot list needs exactly one UID name"},
+		{[]string{"snapshot", "show", "abc"}, "needs a UID name and a snapshot time"},
+		{[]string{"snapshot", "show", "abc", "yesterday"}, `invalid snapshot time "yesterday"`},
+		{[]string{"import"}, "import needs exactly one file"},
+		{[]string{"import", "-mode", "merge", "backup.json"}, "mode must be skip, overwrite or new"},
+		{[]string{"prune", "abc", "def"}, "prune takes at most one UID name"},
+		{[]string{"prune", "-before", "soon"}, `invalid duration "soon"`},
+		{[]string{"config", "show"}, `unknown config subcommand "show"`},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			err := run(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("run = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"-json", "abc"}, nil},
+		{[]string{"-h"}, flag.ErrHelp},
+		{[]string{"-other"}, errFlags},
+		{[]string{"-json=maybe"}, errFlags},
+	}
+	for _, tt := range tests {
+		fs, _, _ := newFlagSet("test")
+		fs.SetOutput(io.Discard)
+		if err := parseFlags(fs, tt.args); !errors.Is(err, tt.err) {
+			t.Errorf("parseFlags(%q) = %v, want %v", tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestSchemaError(t *testing.T) {
+	if err := schemaError(3, 12); err == nil || err.Error() != "database schema is at 3, run `migrate`" {
+		t.Errorf("schemaError(3, 12) = %v", err)
+	}
+	// A newer release may have migrated further, its columns don't hurt.
+	for _, version := range []int{12, 13} {
+		if err := schemaError(version, 12); err != nil {
+			t.Errorf("schemaError(%d, 12) = %v, want nil", version, err)
+		}
+	}
+}
+
+func TestParseBefore(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		before string
+		want   time.Time
+	}{
+		{"1725000000000", time.UnixMilli(1725000000000)},
+		{"30d", now.AddDate(0, 0, -30)},
+		{"720h", now.Add(-720 * time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := parseBefore(tt.before)
+		if err != nil {
+			t.Fatalf("parseBefore(%q) = %v", tt.before, err)
+		}
+		if d := time.UnixMilli(got).Sub(tt.want); d < -time.Minute || d > time.Minute {
+			t.Errorf("parseBefore(%q) = %s, want %s", tt.before, time.UnixMilli(got), tt.want)
+		}
+	}
+	for _, before := range []string{"", "xd", "soon"} {
+		if _, err := parseBefore(before); err == nil {
+			t.Errorf("parseBefore(%q) = nil, want an error", before)
+		}
+	}
+}
+
+func TestQuotaText(t *testing.T) {
+	tests := []struct {
+		quota api.QuotaType
+		want  string
+	}{
+		{api.QuotaType{}, "unlimited bytes, unlimited snapshots"},
+		{api.QuotaType{MaxBytes: 1024, MaxSnapshots: 10}, "1024 bytes, 10 snapshots"},
+	}
+	for _, tt := range tests {
+		if got := quotaText(tt.quota); got != tt.want {
+			t.Errorf("quotaText(%+v) = %q, want %q", tt.quota, got, tt.want)
+		}
+	}
+}
+
+func TestHealthURL(t *testing.T) {
+	tests := []struct {
+		listen string
+		want   string
+	}{
+		{":8080", "http://127.0.0.1:8080/healthz"},
+		{"0.0.0.0:9000", "http://127.0.0.1:9000/healthz"},
+		{"[::]:9000", "http://[::1]:9000/healthz"},
+		{"10.0.0.5:80", "http://10.0.0.5:80/healthz"},
+		{"localhost:3000", "http://localhost:3000/healthz"},
+	}
+	for _, tt := range tests {
+		got, err := healthURL(tt.listen)
+		if err != nil || got != tt.want {
+			t.Errorf("healthURL(%q) = %q, %v, want %q", tt.listen, got, err, tt.want)
+		}
+	}
+	if _, err := healthURL("8080"); err == nil {
+		t.Error("healthURL(8080) = nil error, want missing port")
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	if err := checkHealth(srv.URL + "/healthz"); err != nil {
+		t.Errorf("checkHealth = %v, want nil", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := checkHealth(srv.URL + "/healthz"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("checkHealth = %v, want 503", err)
+	}
+	srv.Close()
+	if err := checkHealth(srv.URL + "/healthz"); err == nil {
+		t.Error("checkHealth of a stopped server = nil, want an error")
+	}
+}
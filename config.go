@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"AxisGTDSync/api"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFlags are the flags every command accepts to locate and override
+// the configuration. They are applied after the config file and the
+// environment.
+type configFlags struct {
+	path   string
+	listen string
+	sets   [][2]string
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	f := &configFlags{}
+	fs.StringVar(&f.path, "config", os.Getenv("configFile"), "YAML config file, defaults to $configFile")
+	fs.StringVar(&f.listen, "listen", "", "address to listen on, e.g. :8080")
+	fs.Func("set", "override a config key, e.g. -set db.max_open_conns=20, can be repeated", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", s)
+		}
+		f.sets = append(f.sets, [2]string{key, value})
+		return nil
+	})
+	return f
+}
+
+func (f *configFlags) load() (api.ConfigType, error) {
+	cfg, err := api.LoadConfig(f.path)
+	if err != nil {
+		return cfg, err
+	}
+	if f.listen != "" {
+		cfg.Listen = f.listen
+	}
+	for _, set := range f.sets {
+		if err := cfg.Set(set[0], set[1]); err != nil {
+			return cfg, fmt.Errorf("-set %s: %v", set[0], err)
+		}
+	}
+	return cfg, nil
+}
+
+func configCommand(args []string) error {
+	sub, args, err := subcommand(args, "config")
+	if err != nil {
+		return err
+	}
+	if sub != "print" {
+		return fmt.Errorf("unknown config subcommand %q\n\n%s", sub, usage)
+	}
+
+	fs, asJSON, cf := newFlagSet("config print")
+	secrets := fs.Bool("secrets", false, "print psql_url and admin_token instead of masking them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := cf.load()
+	if err != nil {
+		return err
+	}
+	printed := cfg.Redacted()
+	if *secrets {
+		printed = cfg
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(printed)
+	} else {
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		err = enc.Encode(printed)
+	}
+	if err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
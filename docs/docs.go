@@ -43,38 +43,46 @@ const docTemplate = `{
                 }
             }
         },
-        "/create": {
-            "put": {
-                "description": "Creates a new UID with a generated name and sets up the axisgtd table.",
-                "consumes": [
-                    "application/json"
+        "/api/v1/export": {
+            "get": {
+                "security": [
+                    {
+                        "APIKeyAuth": []
+                    }
                 ],
+                "description": "Streams all UIDs and their snapshots as a single NDJSON archive.",
                 "produces": [
-                    "application/json"
+                    "application/x-ndjson"
                 ],
                 "tags": [
-                    "id"
+                    "export"
                 ],
-                "summary": "Create a new UID and axisgtd table",
+                "summary": "Export every UID",
                 "responses": {
                     "200": {
-                        "description": "Create ID successful! Your ID is {uidName}",
+                        "description": "NDJSON archive",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     }
                 }
             }
         },
-        "/delete/{name}/{time}": {
-            "delete": {
-                "description": "Deletes a record from the database based on UID name and time.",
+        "/api/v1/ids": {
+            "get": {
+                "description": "Retrieves the count of axisgtd entries associated with each UID.",
                 "consumes": [
                     "application/json"
                 ],
@@ -82,34 +90,42 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "delete"
-                ],
-                "summary": "Delete a record by UID name and time",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "UID Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "The record's time",
-                        "name": "time",
-                        "in": "path",
-                        "required": true
-                    }
+                    "id"
                 ],
+                "summary": "Get counts of axisgtd per UID",
                 "responses": {
                     "200": {
-                        "description": "Record deleted successfully",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.IDSType"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Record not found",
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a new UID with a generated name.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "id"
+                ],
+                "summary": "Create a new UID",
+                "responses": {
+                    "200": {
+                        "description": "Create ID successful! Your ID is {uidName}",
                         "schema": {
                             "type": "string"
                         }
@@ -117,25 +133,22 @@ const docTemplate = `{
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     }
                 }
             }
         },
-        "/id/{name}": {
+        "/api/v1/ids/{name}": {
             "get": {
-                "description": "Retrieves a list of AxisGTD records associated with the given UID name.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Retrieves the status and snapshot count of a UID.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "id"
                 ],
-                "summary": "Get AxisGTD records by UID name",
+                "summary": "Get a UID",
                 "parameters": [
                     {
                         "type": "string",
@@ -149,22 +162,19 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.AxisGTDJsonType"
-                            }
+                            "$ref": "#/definitions/api.IDSType"
                         }
                     },
                     "404": {
-                        "description": "No records found",
+                        "description": "UID not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     }
                 }
@@ -197,18 +207,22 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     }
                 }
-            }
-        },
-        "/ids": {
-            "get": {
-                "description": "Retrieves the count of axisgtd entries associated with each UID.",
+            },
+            "patch": {
+                "description": "Sets the status of a UID, disabled UIDs cannot sync.",
                 "consumes": [
                     "application/json"
                 ],
@@ -216,41 +230,65 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "id"
+                    "status"
+                ],
+                "summary": "Enable or disable a UID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New status",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.UIDPatch"
+                        }
+                    }
                 ],
-                "summary": "Get counts of axisgtd per UID",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.IDSType"
-                            }
+                            "$ref": "#/definitions/api.IDSType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     }
                 }
             }
         },
-        "/status/{name}": {
-            "get": {
-                "description": "Updates the status field of a UID to the opposite value.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/v1/ids/{name}/caldav-secret": {
+            "post": {
+                "description": "Creates or replaces the password CalDAV clients sign in with, with any user name. Replacing it signs out the clients that use the old one.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "status"
+                    "caldav"
                 ],
-                "summary": "Toggle the status of a UID",
+                "summary": "Create a CalDAV secret",
                 "parameters": [
                     {
                         "type": "string",
@@ -262,39 +300,69 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Status toggled successfully",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.DAVSecretType"
                         }
                     },
                     "404": {
                         "description": "UID not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the CalDAV password of a UID, which signs out every CalDAV client.",
+                "tags": [
+                    "caldav"
+                ],
+                "summary": "Revoke the CalDAV secret",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Revoked"
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     }
                 }
             }
         },
-        "/sync/{name}": {
+        "/api/v1/ids/{name}/export": {
             "get": {
-                "description": "Retrieves the latest AxisGTD record associated with the specified UID name, ordered by time in descending order.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Streams the UID metadata and every snapshot as an NDJSON archive.",
                 "produces": [
-                    "application/json"
+                    "application/x-ndjson"
                 ],
                 "tags": [
-                    "sync"
+                    "export"
                 ],
-                "summary": "Get the latest AxisGTD record by UID name",
+                "summary": "Export a UID",
                 "parameters": [
                     {
                         "type": "string",
@@ -306,37 +374,36 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "The latest AxisGTD record",
+                        "description": "NDJSON archive",
                         "schema": {
-                            "$ref": "#/definitions/api.AxisGTDJsonType"
+                            "type": "string"
                         }
                     },
                     "404": {
-                        "description": "UID not found or no records available",
+                        "description": "UID not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Inserts a new AxisGTD record into the database for the given UID name.",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/api/v1/ids/{name}/export.txt": {
+            "get": {
+                "description": "Writes the tasks of the newest todolist in the todo.txt format, with the same filters as the task list.",
                 "produces": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "tags": [
-                    "sync"
+                    "tasks"
                 ],
-                "summary": "Create a new AxisGTD record",
+                "summary": "Export tasks as todo.txt",
                 "parameters": [
                     {
                         "type": "string",
@@ -346,98 +413,3014 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "AxisGTD record to create",
-                        "name": "todo_data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.AxisGTDType"
-                        }
+                        "type": "string",
+                        "description": "all (default), open or done",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks of this project",
+                        "name": "project",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Record created successfully",
+                        "description": "todo.txt",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid filter",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     },
                     "404": {
-                        "description": "UID not found or UID is disabled",
+                        "description": "UID not found or no records available",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/history": {
+            "get": {
+                "description": "Retrieves a list of AxisGTD records associated with the given UID name, oldest first. The array is streamed, a truncated response means the server failed midway.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "id"
+                ],
+                "summary": "Get AxisGTD records by UID name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.AxisGTDJsonType"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "No records found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorType"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.AxisGTDJsonType": {
+        },
+        "/api/v1/ids/{name}/import": {
+            "post": {
+                "description": "Imports an export archive or an AxisGTD client backup into an existing UID in one transaction.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "import"
+                ],
+                "summary": "Import into a UID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Conflict mode: skip (default) or overwrite",
+                        "name": "mode",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid archive or backup",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/import.txt": {
+            "post": {
+                "description": "Applies a todo.txt file to the newest todolist and stores the result as a new snapshot. Lines with an id: update that task, the others are added; mode=replace also removes the tasks the file doesn't list.",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Import todo.txt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "merge (default) or replace",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only if the todolist is still at this ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.TodoTxtResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid mode or task",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "409": {
+                        "description": "Another client synced meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "412": {
+                        "description": "If-Match does not match",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "413": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/quota": {
+            "put": {
+                "security": [
+                    {
+                        "APIKeyAuth": []
+                    }
+                ],
+                "description": "Limits the bytes and snapshots a UID may store, 0 uses the server defaults. Needs the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "status"
+                ],
+                "summary": "Set the quota of a UID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New quota",
+                        "name": "quota",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaType"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.IDSType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid quota",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/read-token": {
+            "post": {
+                "description": "Creates or replaces the read token of a UID, which the calendar feed requires. Replacing it stops the old feed URLs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "calendar"
+                ],
+                "summary": "Create a read token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadTokenType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the read token of a UID, which disables its calendar feed.",
+                "tags": [
+                    "calendar"
+                ],
+                "summary": "Revoke the read token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Revoked"
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/search": {
+            "get": {
+                "description": "Finds the tasks in every snapshot of a UID that have a word starting with each word of q, in the title, notes, project or tags. Each task lists the snapshots it appeared and disappeared in, so deleted tasks can be found and restored. Newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Search the task history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Words to search for",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of tasks, 50 by default",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SearchResultType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/share-token": {
+            "post": {
+                "description": "Creates or replaces the token of the shared task list. It is separate from the read token of the calendar feed, so either can be revoked alone. Replacing it stops the old links.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "share"
+                ],
+                "summary": "Create a share token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ShareTokenType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the share token of a UID, which ends the sharing.",
+                "tags": [
+                    "share"
+                ],
+                "summary": "Revoke the share token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Revoked"
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/snapshots": {
+            "get": {
+                "description": "Lists the time and size of every snapshot of a UID, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "snapshot"
+                ],
+                "summary": "List the snapshots of a UID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.SnapshotInfo"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Inserts a new AxisGTD record into the database for the given UID name.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "Create a new AxisGTD record",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "AxisGTD record to create",
+                        "name": "todo_data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.AxisGTDType"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "gzip, deflate, br or zstd",
+                        "name": "Content-Encoding",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Record created successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "413": {
+                        "description": "A field or the UID's quota is too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Encoding",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "todolist or config is not valid JSON, or time is out of range",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "503": {
+                        "description": "Database unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/snapshots/latest": {
+            "get": {
+                "description": "Retrieves the latest AxisGTD record associated with the specified UID name, ordered by time in descending order. With wait, the request blocks until a newer snapshot is stored and answers 304 on timeout.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "Get the latest AxisGTD record by UID name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the copy the client has",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified of the copy the client has",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Wait up to this long (e.g. 30s) for a snapshot newer than after",
+                        "name": "wait",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time or ETag of the snapshot the client has, defaults to If-None-Match",
+                        "name": "after",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The latest AxisGTD record",
+                        "schema": {
+                            "$ref": "#/definitions/api.AxisGTDJsonType"
+                        }
+                    },
+                    "304": {
+                        "description": "The client's copy is current",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "503": {
+                        "description": "Database unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/snapshots/{time}": {
+            "get": {
+                "description": "Retrieves the snapshot of a UID stored at the given time.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "snapshot"
+                ],
+                "summary": "Get one snapshot",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The snapshot's time",
+                        "name": "time",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.AxisGTDJsonType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid time",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Snapshot not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes a record from the database based on UID name and time.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "delete"
+                ],
+                "summary": "Delete a record by UID name and time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The record's time",
+                        "name": "time",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Record deleted successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid time",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Record not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/snapshots/{time}/restore": {
+            "post": {
+                "description": "Stores a copy of an old snapshot as the newest one, so clients pick it up on their next sync.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "snapshot"
+                ],
+                "summary": "Restore a snapshot",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The snapshot's time",
+                        "name": "time",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The restored copy",
+                        "schema": {
+                            "$ref": "#/definitions/api.SnapshotInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid time",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Snapshot not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/stats": {
+            "get": {
+                "description": "Computes statistics from the snapshots of a UID: tasks created and completed per day and week, the average time to complete a task, overdue tasks and how often each device syncs. Days are UTC and weeks start on Monday. The statistics are cached and only updated with the snapshots stored since the last request.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Productivity statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of days, 30 by default",
+                        "name": "days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of weeks, 12 by default",
+                        "name": "weeks",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.StatsType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid days or weeks",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/tasks": {
+            "get": {
+                "description": "Parses the todolist of the newest snapshot into tasks, optionally filtered. Dates are Unix milliseconds or dates like 2024-09-01.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "List tasks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "all (default), open or done",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks of this project",
+                        "name": "project",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks due before this time",
+                        "name": "due_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks due at or after this time",
+                        "name": "due_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only tasks with a due date",
+                        "name": "has_due",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.TaskType"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds a task to the todolist and stores the result as a new snapshot, which clients pick up on their next sync.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Add a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The task, title is required",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TaskPatch"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only if the todolist is still at this ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.TaskType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid task",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "409": {
+                        "description": "Another client synced meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "412": {
+                        "description": "If-Match does not match",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "413": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/tasks/{id}": {
+            "get": {
+                "description": "Returns one task of the newest snapshot's todolist.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Get a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Task id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.TaskType"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID or task not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Changes the given fields of a task and stores the result as a new snapshot. Set due to \"\" to remove it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Edit a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Task id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TaskPatch"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only if the todolist is still at this ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.TaskType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid task",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID or task not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "409": {
+                        "description": "Another client synced meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "412": {
+                        "description": "If-Match does not match",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/tasks/{id}/complete": {
+            "post": {
+                "description": "Marks a task as done and stores the result as a new snapshot.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Complete a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Task id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only if the todolist is still at this ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.TaskType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID or task not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "409": {
+                        "description": "Another client synced meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "412": {
+                        "description": "If-Match does not match",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/tasks/{id}/history": {
+            "get": {
+                "description": "Reconstructs the history of a task from the snapshots of a UID: when it was created, edited, completed, reopened, deleted and restored, each with the snapshot and the device that stored the change. Oldest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Task timeline",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Task id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.TaskTimelineType"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID or task not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/webhooks": {
+            "get": {
+                "description": "Lists the webhooks of a UID, or with the admin token the global ones. Secrets are only shown when a webhook is created.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.WebhookType"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Subscribes a URL to the events of a UID, or with the admin token to the events of every UID. Events are snapshot.created, snapshot.deleted, uid.enabled, uid.disabled and uid.deleted, no events means all of them. Every request is a JSON POST signed with the returned secret as X-AxisGTD-Signature: sha256=\u003chex HMAC-SHA256 of the body\u003e.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Create a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "URL and events",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.WebhookType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid URL or event",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "409": {
+                        "description": "Too many webhooks",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/webhooks/{id}": {
+            "delete": {
+                "description": "Deletes a webhook with its delivery log, pending deliveries are dropped.",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "UID or webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Lists the newest deliveries of a webhook with their payload and the outcome of the last attempt.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Webhook delivery log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of deliveries, 50 by default",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.WebhookDeliveryType"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID or webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ids/{name}/webhooks/{id}/deliveries/{delivery}/replay": {
+            "post": {
+                "description": "Queues the payload of a delivery again as a new delivery, e.g. after the receiver was down longer than the retries last. The payload keeps its event id.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Replay a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Delivery id",
+                        "name": "delivery",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.WebhookDeliveryType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID, webhook or delivery not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/import": {
+            "post": {
+                "security": [
+                    {
+                        "APIKeyAuth": []
+                    }
+                ],
+                "description": "Imports an export archive, creating or merging every UID it contains in one transaction. A client backup needs a target name or mode=new.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "import"
+                ],
+                "summary": "Import archives and backups",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Conflict mode: skip (default), overwrite or new",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target UID, required for client backups unless mode=new",
+                        "name": "name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid archive or backup",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "403": {
+                        "description": "A UID of the archive is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhooks": {
+            "get": {
+                "description": "Lists the webhooks of a UID, or with the admin token the global ones. Secrets are only shown when a webhook is created.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.WebhookType"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Subscribes a URL to the events of a UID, or with the admin token to the events of every UID. Events are snapshot.created, snapshot.deleted, uid.enabled, uid.disabled and uid.deleted, no events means all of them. Every request is a JSON POST signed with the returned secret as X-AxisGTD-Signature: sha256=\u003chex HMAC-SHA256 of the body\u003e.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Create a webhook",
+                "parameters": [
+                    {
+                        "description": "URL and events",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.WebhookType"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid URL or event",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "409": {
+                        "description": "Too many webhooks",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhooks/{id}": {
+            "delete": {
+                "description": "Deletes a webhook with its delivery log, pending deliveries are dropped.",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "UID or webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Lists the newest deliveries of a webhook with their payload and the outcome of the last attempt.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Webhook delivery log",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of deliveries, 50 by default",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.WebhookDeliveryType"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID or webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhooks/{id}/deliveries/{delivery}/replay": {
+            "post": {
+                "description": "Queues the payload of a delivery again as a new delivery, e.g. after the receiver was down longer than the retries last. The payload keeps its event id.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Replay a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Delivery id",
+                        "name": "delivery",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.WebhookDeliveryType"
+                        }
+                    },
+                    "404": {
+                        "description": "UID, webhook or delivery not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/feeds/{token}.ics": {
+            "get": {
+                "description": "Serves the tasks with a due date as an iCalendar feed for calendar apps to subscribe to. The URL only holds the read token, so it can be shared without the UID name. Tasks are events by default, type=vtodo makes them to-dos.",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "calendar"
+                ],
+                "summary": "Calendar feed",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Read token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "vevent (default) or vtodo",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include completed tasks",
+                        "name": "include_done",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "iCalendar feed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown read token or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Reports that the process is up, without touching the database.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadyType"
+                        }
+                    }
+                }
+            }
+        },
+        "/id/{name}/calendar.ics": {
+            "get": {
+                "description": "The calendar feed under the UID name, for apps that were set up with it. The read token is still required, without it or with another UID's token the answer is the same 404 as for an unknown token.",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "calendar"
+                ],
+                "summary": "Calendar feed of a UID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Read token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "vevent (default) or vtodo",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include completed tasks",
+                        "name": "include_done",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "iCalendar feed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown read token or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/id/{name}/view": {
+            "get": {
+                "description": "The shared HTML page under the UID name, for links made with it. The share token is still required, without it or with another UID's token the answer is the same 404 as for an unknown token.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "share"
+                ],
+                "summary": "Shared task list of a UID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Share token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "open (default), done or all",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks of this project",
+                        "name": "project",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "HTML page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown share token or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/id/{name}/view.md": {
+            "get": {
+                "description": "The shared Markdown list under the UID name. The share token is still required, as for the HTML page.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "share"
+                ],
+                "summary": "Shared task list of a UID as Markdown",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Share token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "open (default), done or all",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks of this project",
+                        "name": "project",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Markdown",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown share token or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "Exposes request, sync, payload, storage and database pool metrics. Needs the admin token when metrics.auth is set.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Prometheus metrics",
+                "responses": {
+                    "200": {
+                        "description": "Prometheus text format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Pings the database and checks that every migration has been applied.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadyType"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadyType"
+                        }
+                    }
+                }
+            }
+        },
+        "/share/{token}": {
+            "get": {
+                "description": "Renders the open tasks of the newest snapshot as a read-only HTML page, grouped by project. Takes the filters of the task list, status=all also shows done tasks.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "share"
+                ],
+                "summary": "Shared task list",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Share token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "open (default), done or all",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks of this project",
+                        "name": "project",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "HTML page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown share token or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/share/{token}.md": {
+            "get": {
+                "description": "Renders the same list as the shared HTML page as Markdown, with task list items.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "share"
+                ],
+                "summary": "Shared task list as Markdown",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Share token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "open (default), done or all",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks of this project",
+                        "name": "project",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Markdown",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "UID is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown share token or no records available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "422": {
+                        "description": "The todolist format is not understood",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/status/{name}": {
+            "get": {
+                "description": "Updates the status field of a UID to the opposite value. Use PATCH /api/v1/ids/{name} instead.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "status"
+                ],
+                "summary": "Toggle the status of a UID",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Status toggled successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "UID not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorType"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Reports the build version, commit and database schema version.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Build and schema version",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.VersionType"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.AxisGTDJsonType": {
+            "type": "object",
+            "properties": {
+                "config": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "boolean"
+                },
+                "time": {
+                    "type": "integer"
+                },
+                "todolist": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.AxisGTDType": {
+            "type": "object",
+            "properties": {
+                "config": {
+                    "type": "string"
+                },
+                "device": {
+                    "description": "Device names the client that pushed the snapshot, the X-Device or\nUser-Agent header when empty.",
+                    "type": "string"
+                },
+                "time": {
+                    "type": "integer"
+                },
+                "todolist": {
+                    "type": "string"
+                },
+                "uidname": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.DAVSecretType": {
+            "type": "object",
+            "properties": {
+                "secret": {
+                    "description": "Secret is the password of the CalDAV account, any user name works.",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL is the account URL to add to the client.",
+                    "type": "string"
+                }
+            }
+        },
+        "api.ErrorType": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.IDSType": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "description": "Bytes and LargestBytes count the todolist and config of the stored\nsnapshots, LastSync is the time of the newest one.",
+                    "type": "integer"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "largest_bytes": {
+                    "type": "integer"
+                },
+                "last_sync": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "quota": {
+                    "$ref": "#/definitions/api.QuotaType"
+                },
+                "status": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.ImportResult": {
+            "type": "object",
+            "properties": {
+                "imported": {
+                    "type": "integer"
+                },
+                "overwritten": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "uids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.QuotaType": {
+            "type": "object",
+            "properties": {
+                "max_bytes": {
+                    "type": "integer"
+                },
+                "max_snapshots": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ReadTokenType": {
+            "type": "object",
+            "properties": {
+                "calendar": {
+                    "description": "Calendar is the feed URL to subscribe to.",
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ReadyType": {
             "type": "object",
             "properties": {
-                "config": {
-                    "type": "string"
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
                 },
-                "name": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SearchChangeType": {
+            "type": "object",
+            "properties": {
+                "change": {
+                    "description": "Change is \"appeared\" or \"disappeared\".",
                     "type": "string"
                 },
-                "status": {
+                "time": {
+                    "description": "Time is the snapshot the task appeared or disappeared in.",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.SearchHitType": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SearchChangeType"
+                    }
+                },
+                "first_seen": {
+                    "type": "integer"
+                },
+                "last_seen": {
+                    "type": "integer"
+                },
+                "present": {
                     "type": "boolean"
                 },
-                "time": {
+                "task": {
+                    "$ref": "#/definitions/api.TaskType"
+                }
+            }
+        },
+        "api.SearchResultType": {
+            "type": "object",
+            "properties": {
+                "query": {
+                    "type": "string"
+                },
+                "snapshots": {
+                    "description": "Snapshots is the number of snapshots containing a match.",
                     "type": "integer"
                 },
-                "todolist": {
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SearchHitType"
+                    }
+                }
+            }
+        },
+        "api.ShareTokenType": {
+            "type": "object",
+            "properties": {
+                "markdown": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "view": {
+                    "description": "View and Markdown are the URLs of the shared task list.",
                     "type": "string"
                 }
             }
         },
-        "api.AxisGTDType": {
+        "api.SnapshotInfo": {
             "type": "object",
             "properties": {
-                "config": {
+                "config_bytes": {
+                    "type": "integer"
+                },
+                "device": {
                     "type": "string"
                 },
                 "time": {
                     "type": "integer"
                 },
-                "todolist": {
+                "todolist_bytes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.StatsDeviceType": {
+            "type": "object",
+            "properties": {
+                "device": {
                     "type": "string"
                 },
-                "uidname": {
+                "first_sync": {
+                    "type": "integer"
+                },
+                "last_sync": {
+                    "type": "integer"
+                },
+                "per_day": {
+                    "description": "PerDay is the average number of snapshots per day between the first\nand the last.",
+                    "type": "number"
+                },
+                "snapshots": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.StatsPeriodType": {
+            "type": "object",
+            "properties": {
+                "completed": {
+                    "type": "integer"
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "start": {
+                    "description": "Start is the first day, like 2024-09-02.",
                     "type": "string"
+                },
+                "syncs": {
+                    "type": "integer"
                 }
             }
         },
-        "api.IDSType": {
+        "api.StatsType": {
             "type": "object",
             "properties": {
-                "count": {
+                "average_completion": {
+                    "description": "AverageCompletion is the mean time from creating to completing a task\nin milliseconds.",
+                    "type": "integer"
+                },
+                "completed": {
+                    "type": "integer"
+                },
+                "completed_late": {
+                    "type": "integer"
+                },
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.StatsPeriodType"
+                    }
+                },
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.StatsDeviceType"
+                    }
+                },
+                "open": {
+                    "description": "Open and Overdue count the tasks of the newest snapshot.",
+                    "type": "integer"
+                },
+                "overdue": {
+                    "type": "integer"
+                },
+                "snapshots": {
+                    "type": "integer"
+                },
+                "weeks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.StatsPeriodType"
+                    }
+                }
+            }
+        },
+        "api.TaskChangeType": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "from": {},
+                "to": {}
+            }
+        },
+        "api.TaskEventType": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.TaskChangeType"
+                    }
+                },
+                "device": {
+                    "type": "string"
+                },
+                "event": {
+                    "description": "Event is \"created\", \"edited\", \"completed\", \"reopened\", \"deleted\" or\n\"restored\".",
+                    "type": "string"
+                },
+                "task": {
+                    "description": "Task is the task after the event, before it for \"deleted\".",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.TaskType"
+                        }
+                    ]
+                },
+                "time": {
                     "type": "integer"
+                }
+            }
+        },
+        "api.TaskPatch": {
+            "type": "object",
+            "properties": {
+                "done": {
+                    "type": "boolean"
+                },
+                "due": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "project": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.TaskTimelineType": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.TaskEventType"
+                    }
                 },
                 "id": {
+                    "type": "string"
+                },
+                "present": {
+                    "description": "Present tells whether the newest snapshot still has the task.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.TaskType": {
+            "type": "object",
+            "properties": {
+                "completed_at": {
                     "type": "integer"
                 },
-                "name": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "done": {
+                    "type": "boolean"
+                },
+                "due": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "notes": {
                     "type": "string"
                 },
+                "project": {
+                    "type": "string"
+                },
+                "raw": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.TodoTxtResult": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "integer"
+                },
+                "removed": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.UIDPatch": {
+            "type": "object",
+            "properties": {
                 "status": {
                     "type": "boolean"
                 }
             }
+        },
+        "api.VersionType": {
+            "type": "object",
+            "properties": {
+                "commit": {
+                    "type": "string"
+                },
+                "go_version": {
+                    "type": "string"
+                },
+                "latest_schema_version": {
+                    "type": "integer"
+                },
+                "schema_version": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.WebhookDeliveryType": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_attempt": {
+                    "type": "integer"
+                },
+                "next_attempt": {
+                    "description": "NextAttempt is 0 once the delivery is finished.",
+                    "type": "integer"
+                },
+                "payload": {
+                    "$ref": "#/definitions/api.WebhookEventType"
+                },
+                "response_status": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.WebhookEventType": {
+            "type": "object",
+            "properties": {
+                "data": {},
+                "event": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "integer"
+                },
+                "uid": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.WebhookRequest": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.WebhookType": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "uid": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
 	"AxisGTDSync/api"
 
 	"github.com/gofiber/contrib/swagger"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/template/html/v2"
 )
@@ -32,45 +43,143 @@ import (
 // @scope.write				Write access
 // @scope.read					Read access
 func main() {
+	err := run(os.Args[1:])
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errFlags):
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-	corsUrl := api.GetConfig().CorsURL
-
-	api.InitDB()
-	engine := html.New("./views", ".html")
+func serve(args []string) error {
+	fs, _, cf := newFlagSet("serve")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := cf.load()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := api.SetupLogging(cfg.Log); err != nil {
+		return err
+	}
+	api.Configure(cfg)
+	if err := api.LoadSchemas(); err != nil {
+		return err
+	}
+
+	if err := api.OpenDB(); err != nil {
+		return err
+	}
+	if err := api.InitDB(); err != nil {
+		return err
+	}
+	if err := api.StartCache(); err != nil {
+		return fmt.Errorf("error listening for changes, set cache.size to 0 to run without the cache: %v", err)
+	}
+	api.StartWebhooks()
+
+	engine := html.New(cfg.Paths.Views, ".html")
 	engine.Delims("{[", "]}")
 
-	app := fiber.New(fiber.Config{Views: engine})
-
-	app.Static("/", "./public")
-
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: corsUrl,
-		AllowHeaders: "Origin,Content-Type,Accept",
-	}))
-
-	app.Get("/", api.Index)
+	app := fiber.New(fiber.Config{
+		Views:                 engine,
+		BodyLimit:             cfg.BodyLimit,
+		RequestMethods:        append(fiber.DefaultMethods, api.DAVMethods...),
+		ErrorHandler:          api.ErrorHandler,
+		DisableStartupMessage: true,
+	})
 
-	app.Put("/create", api.CreateID)
+	app.Use(api.RequestID)
+	app.Use(api.RequestLogger)
 
-	app.Get("/id/:name", api.GetID)
+	if cfg.Metrics.Enabled {
+		api.InitMetrics()
+		app.Use(api.Metrics)
+	}
 
-	app.Delete("/id/:name", api.DeleteID)
+	if cfg.Features.Compression {
+		app.Use(compress.New())
+	}
 
-	app.Get("/ids", api.GetAllID)
+	if cfg.Features.ManagePage {
+		app.Static("/", cfg.Paths.Public)
+	}
 
-	app.Get("/status/:name", api.ToggleStatus)
-
-	app.Get("/sync/:name", api.SyncGet)
-
-	app.Post("/sync/:name", api.SyncPost)
-
-	app.Delete("/delete/:name/:time", api.DeleteRecord)
-
-	app.Use(swagger.New(swagger.Config{
-		BasePath: "/",
-		FilePath: "./docs/swagger.json",
-		Path:     "docs",
+	app.Use(cors.New(cors.Config{
+		AllowOrigins:  corsOrigins(cfg.CORS.Origins),
+		AllowHeaders:  "Origin,Content-Type,Content-Encoding,Accept,X-Request-ID,X-Device,If-None-Match,If-Modified-Since",
+		ExposeHeaders: "X-Request-ID,ETag",
 	}))
 
-	app.Listen(":8080")
+	if cfg.Features.ManagePage {
+		app.Get("/", api.Index)
+	}
+
+	app.Get("/healthz", api.Healthz)
+
+	app.Get("/readyz", api.Readyz)
+
+	app.Get("/version", api.GetVersion)
+
+	if cfg.Metrics.Enabled && cfg.Metrics.Auth {
+		app.Get("/metrics", api.AdminAuth, api.MetricsHandler())
+	} else if cfg.Metrics.Enabled {
+		app.Get("/metrics", api.MetricsHandler())
+	}
+
+	api.Routes(app)
+
+	if cfg.Features.Swagger {
+		app.Use(swagger.New(swagger.Config{
+			BasePath: "/",
+			FilePath: cfg.Paths.Swagger,
+			Path:     "docs",
+		}))
+	}
+
+	slog.Info("listening", "address", cfg.Listen, "version", api.Version)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(cfg.Listen)
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-listenErr:
+		return fmt.Errorf("listen on %s: %v", cfg.Listen, err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", cfg.ShutdownTimeout)
+	api.CloseHub()
+	shutdownErr := app.ShutdownWithTimeout(cfg.ShutdownTimeout)
+	api.StopWebhooks()
+	if err := api.CloseDB(); err != nil {
+		slog.Error("error closing database", "error", err)
+	}
+	if shutdownErr != nil {
+		return fmt.Errorf("shutdown: %v", shutdownErr)
+	}
+	return nil
+}
+
+// corsOrigins joins the configured origins for the cors middleware. Browsers
+// never send a trailing slash, so one is dropped instead of never matching.
+func corsOrigins(origins []string) string {
+	trimmed := make([]string, len(origins))
+	for i, origin := range origins {
+		trimmed[i] = strings.TrimSuffix(origin, "/")
+	}
+	return strings.Join(trimmed, ",")
 }